@@ -1,5 +1,11 @@
 module safereplace
 
-go 1.25
+go 1.25.0
 
-require github.com/spf13/pflag v1.0.10
+require (
+	github.com/klauspost/compress v1.20.1
+	github.com/spf13/pflag v1.0.10
+	github.com/tetratelabs/wazero v1.12.0
+	golang.org/x/sys v0.44.0
+	golang.org/x/term v0.42.0
+)
@@ -6,93 +6,274 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+
+	"safereplace/internal/trash"
 )
 
 // Options controls how file application is performed.
 // BackupSuffix is used only when Backup is true; if empty, ".bak" is used.
 // Writes are done to a temp file in the same directory and then atomically renamed.
-// File mode (permissions) of the original is preserved on the new file.
+// File mode (permissions plus setuid, setgid and sticky bits) of the original is
+// preserved on the new file; backups get the permission bits only. Extended
+// attributes, including POSIX ACLs, are copied to both where supported (Linux).
 // The parent directory is fsynced on platforms that support it (best-effort on Windows).
+//
+// Read-only and immutable files are refused with ErrReadOnly or ErrImmutable
+// before anything is written. ForcePerm lifts the restriction for the duration
+// of the write and restores it on the replaced file.
+//
+// BackupCompress ("gzip" or "zstd") stores backups compressed, appending ".gz" or
+// ".zst" to the backup name; ReadBackup decompresses them again.
+//
+// BackupReflink makes backups copy-on-write clones of the original instead of
+// copies, which is instant and shares the data blocks until either file
+// changes. Where the filesystem cannot clone (only btrfs, XFS and similar on
+// Linux, and APFS on macOS can) the backup is a plain copy.
+//
+// Archive, when set, receives the original instead of a per-file backup.
+// Trash copies the original into the OS trash instead (see package trash).
+//
+// TempDir, when set, holds temp files instead of the target's directory. It is
+// only used for targets on the same filesystem, so the final rename stays
+// atomic; otherwise the temp file is staged next to the target as usual.
+//
+// Temp files are named TempPrefix + name + ".tmp-" + digits + TempSuffix (see
+// IsTempName); both default to empty.
+//
+// ModePolicy overrides the preserved mode of rewritten files: ModeUmask gives
+// them the mode of a newly created file (0666 minus the umask), ModeExplicit
+// gives them Mode. Setuid, setgid and sticky bits are kept only by
+// ModePreserve (the default) or when included in Mode.
 type Options struct {
-	Backup       bool
-	BackupSuffix string
+	Backup         bool
+	BackupSuffix   string
+	BackupCompress string
+	BackupReflink  bool
+	Archive        *Archive
+	Trash          bool
+	ForcePerm      bool
+	TempDir        string
+	TempPrefix     string
+	TempSuffix     string
+	ModePolicy     string
+	Mode           os.FileMode
+}
+
+// Values for Options.ModePolicy.
+const (
+	ModePreserve = "preserve"
+	ModeUmask    = "umask"
+	ModeExplicit = "explicit"
+)
+
+// newMode returns the mode a rewritten file gets, given the original's.
+func (o Options) newMode(orig os.FileMode) os.FileMode {
+	switch o.ModePolicy {
+	case ModeUmask:
+		return 0o666 &^ umask()
+	case ModeExplicit:
+		return o.Mode & (os.ModePerm | specialBits)
+	}
+	return orig
 }
 
+// Errors returned (wrapped) when the target cannot be replaced without --force-perm.
+var (
+	ErrReadOnly  = errors.New("file is read-only")
+	ErrImmutable = errors.New("file is immutable or append-only")
+)
+
+// errReflinkUnsupported is returned (wrapped) by cloneFile when the
+// filesystem or platform cannot clone files.
+var errReflinkUnsupported = errors.New("filesystem does not support reflinks")
+
 // WriteAtomic writes data to path safely:
 //  1. stat the existing file (required)
 //  2. optionally create a backup (unique name)
-//  3. write to a temp file in the same dir, fsync, close
+//  3. write to a temp file in the same dir (or Options.TempDir), fsync, close
 //  4. atomic rename over the original
 //  5. fsync the parent directory (best-effort)
 func WriteAtomic(path string, data []byte, opts Options) error {
+	_, err := WriteAtomicWithBackup(path, data, opts)
+	return err
+}
+
+// WriteAtomicWithBackup behaves like WriteAtomic and additionally returns the
+// path of the backup it created, or "" when no per-file backup was made.
+func WriteAtomicWithBackup(path string, data []byte, opts Options) (string, error) {
 	// 1) stat original (must exist; preserving mode)
 	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("apply: stat: %w", err)
+		return "", fmt.Errorf("apply: stat: %w", err)
+	}
+	mode := info.Mode() & (os.ModePerm | specialBits)
+	readOnly := mode&0o200 == 0
+	immutable := isImmutable(path)
+	if !opts.ForcePerm {
+		if immutable {
+			return "", fmt.Errorf("apply: %w", ErrImmutable)
+		}
+		if readOnly {
+			return "", fmt.Errorf("apply: %w", ErrReadOnly)
+		}
 	}
-	mode := info.Mode().Perm()
 
 	dir := filepath.Dir(path)
 	base := filepath.Base(path)
 
 	// 2) optional backup
-	if opts.Backup {
+	var backupPath string
+	if opts.Archive != nil {
+		if _, err := opts.Archive.Add(path); err != nil {
+			return "", err
+		}
+	} else if opts.Trash {
+		tp, terr := trash.Put(path)
+		if terr != nil {
+			return "", fmt.Errorf("apply: backup: %w", terr)
+		}
+		backupPath = tp
+	} else if opts.Backup {
 		bak := opts.BackupSuffix
 		if bak == "" {
 			bak = ".bak"
 		}
-		backupPath, berr := uniqueBackupPath(dir, base, bak)
+		ext, cerr := compressExt(opts.BackupCompress)
+		if cerr != nil {
+			return "", cerr
+		}
+		bp, berr := uniqueBackupPath(dir, base, bak, ext)
 		if berr != nil {
-			return berr
+			return "", berr
+		}
+		var err error
+		if opts.BackupReflink {
+			err = cloneFile(path, bp, mode.Perm())
+		}
+		if !opts.BackupReflink || errors.Is(err, errReflinkUnsupported) {
+			err = copyFile(path, bp, mode.Perm(), opts.BackupCompress)
 		}
-		if err := copyFile(path, backupPath, mode); err != nil {
-			return fmt.Errorf("apply: backup: %w", err)
+		if err != nil {
+			return "", fmt.Errorf("apply: backup: %w", err)
 		}
+		if err := copyXattrs(path, bp); err != nil {
+			return "", fmt.Errorf("apply: backup xattrs: %w", err)
+		}
+		backupPath = bp
 	}
 
-	// 3) write temp in same dir
-	tf, err := os.CreateTemp(dir, base+".tmp-*")
+	// 3) write temp in same dir, or in TempDir when it shares the filesystem
+	tmpDir := dir
+	if opts.TempDir != "" && SameFilesystem(opts.TempDir, dir) {
+		tmpDir = opts.TempDir
+	}
+	newMode := opts.newMode(mode)
+	pattern := tempPattern(base, opts.TempPrefix, opts.TempSuffix)
+	tmp, err := writeTemp(tmpDir, pattern, path, data, newMode)
 	if err != nil {
-		return fmt.Errorf("apply: temp: %w", err)
+		return "", err
 	}
 	renamed := false
-	defer func(name string) {
+	defer func() {
 		if !renamed {
-			_ = os.Remove(name)
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	// 4) atomic replace, temporarily lifting read-only/immutable protection if forced
+	var restoreFlags func(string) error
+	if immutable {
+		restoreFlags, err = liftImmutable(path)
+		if err != nil {
+			return "", fmt.Errorf("apply: lift immutable: %w", err)
+		}
+	}
+	if readOnly {
+		// Some platforms (Windows) refuse to rename over a read-only file.
+		_ = os.Chmod(path, mode|0o200)
+	}
+	err = os.Rename(tmp, path)
+	if isCrossDevice(err) && tmpDir != dir {
+		// TempDir turned out to be elsewhere (e.g. a bind mount): copy the
+		// content next to the target and rename within its directory instead.
+		_ = os.Remove(tmp)
+		if tmp, err = writeTemp(dir, pattern, path, data, newMode); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		if readOnly {
+			_ = os.Chmod(path, mode)
+		}
+		if restoreFlags != nil {
+			_ = restoreFlags(path)
 		}
-	}(tf.Name())
+		return "", fmt.Errorf("apply: rename: %w", err)
+	}
+	renamed = true
+	if restoreFlags != nil {
+		if err := restoreFlags(path); err != nil {
+			return backupPath, fmt.Errorf("apply: restore immutable: %w", err)
+		}
+	}
+
+	// 5) fsync parent dir (best effort; may not work on Windows)
+	_ = syncDir(dir) // best-effort; ignore error
+
+	return backupPath, nil
+}
+
+// writeTemp writes data to a new temp file in dir named after pattern, with
+// mode and the extended attributes of path, fsyncs and closes it, and returns
+// its name.
+func writeTemp(dir, pattern, path string, data []byte, mode os.FileMode) (string, error) {
+	tf, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("apply: temp: %w", err)
+	}
+	fail := func(err error) (string, error) {
+		_ = os.Remove(tf.Name())
+		return "", errors.Join(err, tf.Close())
+	}
 	if _, err := tf.Write(data); err != nil {
-		return errors.Join(fmt.Errorf("apply: write temp: %w", err), tf.Close())
+		return fail(fmt.Errorf("apply: write temp: %w", err))
 	}
 	if err := tf.Chmod(mode); err != nil {
-		return errors.Join(fmt.Errorf("apply: chmod temp: %w", err), tf.Close())
+		return fail(fmt.Errorf("apply: chmod temp: %w", err))
+	}
+	if err := copyXattrs(path, tf.Name()); err != nil {
+		return fail(fmt.Errorf("apply: xattrs temp: %w", err))
 	}
 	if err := tf.Sync(); err != nil {
-		return errors.Join(fmt.Errorf("apply: fsync temp: %w", err), tf.Close())
+		return fail(fmt.Errorf("apply: fsync temp: %w", err))
 	}
 	if err := tf.Close(); err != nil {
-		return fmt.Errorf("apply: close temp: %w", err)
+		_ = os.Remove(tf.Name())
+		return "", fmt.Errorf("apply: close temp: %w", err)
 	}
+	return tf.Name(), nil
+}
 
-	// 4) atomic replace
-	if err := os.Rename(tf.Name(), path); err != nil {
-		return fmt.Errorf("apply: rename: %w", err)
-	}
-	renamed = true
-
-	// 5) fsync parent dir (best effort; may not work on Windows)
-	_ = syncDir(dir) // best-effort; ignore error
+const specialBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
 
-	return nil
+// SpecialBits returns the setuid, setgid and sticky bits set on path, so callers
+// can warn before rewriting such files.
+func SpecialBits(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Mode() & specialBits, nil
 }
 
-func uniqueBackupPath(dir, base, suffix string) (string, error) {
-	cand := filepath.Join(dir, base+suffix)
+// uniqueBackupPath picks base+suffix+ext, or base+suffix+".N"+ext if taken.
+func uniqueBackupPath(dir, base, suffix, ext string) (string, error) {
+	cand := filepath.Join(dir, base+suffix+ext)
 	if _, err := os.Lstat(cand); os.IsNotExist(err) {
 		return cand, nil
 	}
 	for i := 1; i < 1000; i++ {
-		p := filepath.Join(dir, fmt.Sprintf("%s%s.%d", base, suffix, i))
+		p := filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", base, suffix, i, ext))
 		if _, err := os.Lstat(p); os.IsNotExist(err) {
 			return p, nil
 		}
@@ -100,7 +281,8 @@ func uniqueBackupPath(dir, base, suffix string) (string, error) {
 	return "", fmt.Errorf("apply: backup: too many existing backups for %s", base)
 }
 
-func copyFile(src, dst string, mode os.FileMode) error {
+// copyFile copies src to dst, compressing the stream when compress is set.
+func copyFile(src, dst string, mode os.FileMode, compress string) error {
 	r, err := os.Open(src)
 	if err != nil {
 		return err
@@ -111,7 +293,20 @@ func copyFile(src, dst string, mode os.FileMode) error {
 		return err
 	}
 	defer func() { _ = w.Close() }()
-	if _, err := io.Copy(w, r); err != nil {
+	if compress == CompressNone {
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		return w.Sync()
+	}
+	zw, err := compressWriter(w, compress)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		return errors.Join(err, zw.Close())
+	}
+	if err := zw.Close(); err != nil {
 		return err
 	}
 	return w.Sync()
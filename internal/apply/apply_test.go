@@ -1,8 +1,10 @@
 package apply
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -79,3 +81,245 @@ func TestWriteAtomic_NoSuchFile(t *testing.T) {
 		t.Fatalf("expected stat error, got %v", err)
 	}
 }
+
+func TestWriteAtomic_ReadOnlyRefused(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "ro.txt")
+	if err := os.WriteFile(p, []byte("orig"), 0o444); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	err := WriteAtomic(p, []byte("new"), Options{Backup: true})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	got, _ := os.ReadFile(p)
+	if string(got) != "orig" {
+		t.Fatalf("read-only file modified: %q", got)
+	}
+	if _, serr := os.Stat(p + ".bak"); !os.IsNotExist(serr) {
+		t.Fatalf("no backup expected for refused write, stat err=%v", serr)
+	}
+}
+
+func TestWriteAtomic_ForcePermKeepsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "ro.txt")
+	if err := os.WriteFile(p, []byte("orig"), 0o444); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := WriteAtomic(p, []byte("new"), Options{ForcePerm: true}); err != nil {
+		t.Fatalf("WriteAtomic: %v", err)
+	}
+	got, _ := os.ReadFile(p)
+	if string(got) != "new" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm()&0o200 != 0 {
+		t.Fatalf("read-only mode not restored: %v", info.Mode())
+	}
+}
+
+func TestWriteAtomic_PreservesSpecialBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no setgid on Windows")
+	}
+	dir := t.TempDir()
+	p := filepath.Join(dir, "tool.sh")
+	if err := os.WriteFile(p, []byte("echo old"), 0o755); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := os.Chmod(p, 0o755|os.ModeSetgid); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	bits, err := SpecialBits(p)
+	if err != nil || bits != os.ModeSetgid {
+		t.Skipf("filesystem does not keep setgid here (bits=%v, err=%v)", bits, err)
+	}
+	if err := WriteAtomic(p, []byte("echo new"), Options{}); err != nil {
+		t.Fatalf("WriteAtomic: %v", err)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode()&os.ModeSetgid == 0 || info.Mode().Perm() != 0o755 {
+		t.Fatalf("mode not preserved: %v", info.Mode())
+	}
+}
+
+func TestWriteAtomic_BackupCompressed(t *testing.T) {
+	for _, tc := range []struct{ format, ext string }{{CompressGzip, ".gz"}, {CompressZstd, ".zst"}} {
+		t.Run(tc.format, func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "a.txt")
+			orig := strings.Repeat("original line\n", 100)
+			if err := os.WriteFile(p, []byte(orig), 0o644); err != nil {
+				t.Fatalf("setup: %v", err)
+			}
+			bak, err := WriteAtomicWithBackup(p, []byte("new"), Options{Backup: true, BackupCompress: tc.format})
+			if err != nil {
+				t.Fatalf("WriteAtomic: %v", err)
+			}
+			if want := p + ".bak" + tc.ext; bak != want {
+				t.Fatalf("backup path: got %q want %q", bak, want)
+			}
+			raw, _ := os.ReadFile(bak)
+			if len(raw) >= len(orig) {
+				t.Fatalf("backup not compressed: %d bytes", len(raw))
+			}
+			got, err := ReadBackup(bak)
+			if err != nil {
+				t.Fatalf("ReadBackup: %v", err)
+			}
+			if string(got) != orig {
+				t.Fatalf("round trip mismatch")
+			}
+		})
+	}
+}
+
+func TestWriteAtomic_BackupReflink(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(p, []byte("orig"), 0o640); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	// Filesystems that cannot clone (tmpfs, ext4) fall back to a plain copy.
+	bp, err := WriteAtomicWithBackup(p, []byte("new"), Options{Backup: true, BackupReflink: true})
+	if err != nil {
+		t.Fatalf("WriteAtomicWithBackup: %v", err)
+	}
+	if data, _ := os.ReadFile(bp); string(data) != "orig" {
+		t.Fatalf("backup: got %q", data)
+	}
+	if data, _ := os.ReadFile(p); string(data) != "new" {
+		t.Fatalf("target: got %q", data)
+	}
+	if info, serr := os.Stat(bp); serr != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("backup mode: %v %v", info, serr)
+	}
+}
+
+func TestWriteAtomic_BackupCompressUnknown(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := WriteAtomic(p, []byte("y"), Options{Backup: true, BackupCompress: "lz4"}); err == nil {
+		t.Fatalf("expected error for unsupported compression")
+	}
+	got, _ := os.ReadFile(p)
+	if string(got) != "x" {
+		t.Fatalf("file modified despite error: %q", got)
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	d, err := DiskSpace(dir)
+	if errors.Is(err, ErrSpaceUnknown) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Free == 0 {
+		t.Fatalf("no free space reported for %s", dir)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if d2, err := DiskSpace(sub); err != nil || d2.Device != d.Device {
+		t.Fatalf("subdirectory on another device: %+v vs %+v, %v", d2, d, err)
+	}
+}
+
+func TestWriteAtomic_TempDir(t *testing.T) {
+	dir, tmp := t.TempDir(), t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(p, []byte("old"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteAtomic(p, []byte("new"), Options{TempDir: tmp}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p); string(data) != "new" {
+		t.Fatalf("content: %q", data)
+	}
+	for _, d := range []string{dir, tmp} {
+		entries, _ := os.ReadDir(d)
+		for _, e := range entries {
+			if strings.Contains(e.Name(), ".tmp-") {
+				t.Fatalf("temp file left behind: %s", filepath.Join(d, e.Name()))
+			}
+		}
+	}
+}
+
+func TestWriteAtomic_ModePolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.sh")
+	if err := os.WriteFile(p, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(p, 0o700|os.ModeSetuid); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts Options
+		want os.FileMode
+	}{
+		{Options{}, 0o700 | os.ModeSetuid},
+		{Options{ModePolicy: ModeExplicit, Mode: 0o644}, 0o644},
+		{Options{ModePolicy: ModeUmask}, 0o666 &^ umask()},
+	} {
+		if err := WriteAtomic(p, []byte("new"), tc.opts); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() & (os.ModePerm | specialBits); got != tc.want {
+			t.Fatalf("%q: mode %v, want %v", tc.opts.ModePolicy, got, tc.want)
+		}
+	}
+}
+
+func TestIsTempName(t *testing.T) {
+	for _, tc := range []struct {
+		name, prefix, suffix string
+		want                 bool
+	}{
+		{"a.txt.tmp-123456", "", "", true},
+		{".a.txt.tmp-42~", ".", "~", true},
+		{"a.txt.tmp-", "", "", false},
+		{"a.txt.tmp-12x", "", "", false},
+		{".tmp-123", "", "", false},
+		{"a.txt", "", "", false},
+		{"a.txt.tmp-42", ".", "~", false},
+	} {
+		if got := IsTempName(tc.name, tc.prefix, tc.suffix); got != tc.want {
+			t.Errorf("IsTempName(%q, %q, %q) = %v", tc.name, tc.prefix, tc.suffix, got)
+		}
+	}
+	pattern := tempPattern("a.txt", ".", "~")
+	f, err := os.CreateTemp(t.TempDir(), pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if !IsTempName(filepath.Base(f.Name()), ".", "~") {
+		t.Fatalf("%s not recognized as a temp file", f.Name())
+	}
+}
@@ -0,0 +1,157 @@
+package apply
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive collects the originals of one run into a single .tar.gz file, as an
+// alternative to per-file backups. Each member is flushed and synced as soon as
+// it is added, so a crash mid-run still leaves every earlier original readable.
+type Archive struct {
+	path string
+	root string
+	// f, zw and tw are opened by the first Add, so a run that writes nothing
+	// leaves no archive behind.
+	f       *os.File
+	zw      *gzip.Writer
+	tw      *tar.Writer
+	members int
+}
+
+// CreateArchive prepares a new archive at path, which must not exist yet, so
+// the originals kept by an earlier run are never overwritten. The file is
+// created by the first Add. Members are named relative to root when they
+// live under it.
+func CreateArchive(path, root string) (*Archive, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("apply: archive: %w", err)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fmt.Errorf("apply: archive: %s: %w", path, fs.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("apply: archive: %w", err)
+	}
+	return &Archive{path: path, root: absRoot}, nil
+}
+
+// open creates the archive file, failing if it appeared since CreateArchive.
+func (a *Archive) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("apply: archive: %w", err)
+	}
+	a.f = f
+	a.zw = gzip.NewWriter(f)
+	a.tw = tar.NewWriter(a.zw)
+	return nil
+}
+
+// Path returns the archive file path.
+func (a *Archive) Path() string { return a.path }
+
+// MemberName returns the name under which src is (or would be) stored.
+func (a *Archive) MemberName(src string) string {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		abs = src
+	}
+	if rel, err := filepath.Rel(a.root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	// Outside root: keep the absolute path without volume and leading separator.
+	abs = strings.TrimPrefix(abs, filepath.VolumeName(abs))
+	return strings.TrimLeft(filepath.ToSlash(abs), "/")
+}
+
+// Add stores the current content and mode of src and returns its member name.
+func (a *Archive) Add(src string) (string, error) {
+	if a.f == nil {
+		if err := a.open(); err != nil {
+			return "", err
+		}
+	}
+	r, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("apply: archive: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+	info, err := r.Stat()
+	if err != nil {
+		return "", fmt.Errorf("apply: archive: %w", err)
+	}
+	name := a.MemberName(src)
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  info.ModTime().Truncate(time.Second),
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return "", fmt.Errorf("apply: archive: %w", err)
+	}
+	if _, err := io.Copy(a.tw, r); err != nil {
+		return "", fmt.Errorf("apply: archive: %s: %w", name, err)
+	}
+	if err := a.tw.Flush(); err != nil {
+		return "", fmt.Errorf("apply: archive: %w", err)
+	}
+	if err := a.zw.Flush(); err != nil {
+		return "", fmt.Errorf("apply: archive: %w", err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return "", fmt.Errorf("apply: archive: fsync: %w", err)
+	}
+	a.members++
+	return name, nil
+}
+
+// Close finishes the tar and gzip streams and closes the file, removing it if
+// no member was stored.
+func (a *Archive) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	err := errors.Join(a.tw.Close(), a.zw.Close(), a.f.Close())
+	if a.members == 0 {
+		err = errors.Join(err, os.Remove(a.path))
+	}
+	return err
+}
+
+// ReadArchiveMember returns the content stored under name in the archive at path.
+func ReadArchiveMember(path, name string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("apply: archive %s: %w", path, err)
+	}
+	defer func() { _ = zr.Close() }()
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("apply: archive %s: no member %q", path, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("apply: archive %s: %w", path, err)
+		}
+		if hdr.Name == name {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package apply
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchive_AddAndReadMember(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "sub", "a.txt")
+	if err := os.MkdirAll(filepath.Dir(a), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(a, []byte("original"), 0o640); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	arPath := filepath.Join(dir, "run.tar.gz")
+	ar, err := CreateArchive(arPath, dir)
+	if err != nil {
+		t.Fatalf("CreateArchive: %v", err)
+	}
+	name, err := ar.Add(a)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if name != "sub/a.txt" {
+		t.Fatalf("member name: got %q want sub/a.txt", name)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err := ReadArchiveMember(arPath, name)
+	if err != nil {
+		t.Fatalf("ReadArchiveMember: %v", err)
+	}
+	if string(got) != "original" {
+		t.Fatalf("member content: %q", got)
+	}
+	if _, err := ReadArchiveMember(arPath, "missing.txt"); err == nil {
+		t.Fatalf("expected error for missing member")
+	}
+}
+
+func TestArchive_CreatedLazilyAndNeverOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	arPath := filepath.Join(dir, "run.tar.gz")
+	ar, err := CreateArchive(arPath, dir)
+	if err != nil {
+		t.Fatalf("CreateArchive: %v", err)
+	}
+	if err := ar.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(arPath); !os.IsNotExist(err) {
+		t.Fatalf("unused archive left behind: %v", err)
+	}
+
+	if err := os.WriteFile(arPath, []byte("earlier run"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateArchive(arPath, dir); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("existing archive: expected fs.ErrExist, got %v", err)
+	}
+	if data, _ := os.ReadFile(arPath); string(data) != "earlier run" {
+		t.Fatalf("existing archive modified: %q", data)
+	}
+}
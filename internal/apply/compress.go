@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Backup compression formats accepted in Options.BackupCompress.
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// compressExt returns the file name extension appended to compressed backups.
+func compressExt(format string) (string, error) {
+	switch format {
+	case CompressNone:
+		return "", nil
+	case CompressGzip:
+		return ".gz", nil
+	case CompressZstd:
+		return ".zst", nil
+	}
+	return "", fmt.Errorf("apply: unsupported backup compression %q (want gzip or zstd)", format)
+}
+
+// compressWriter wraps w so that writes are compressed in format.
+func compressWriter(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("apply: unsupported backup compression %q", format)
+}
+
+// ReadBackup returns the original content stored in a backup file, transparently
+// decompressing .gz and .zst backups.
+func ReadBackup(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("apply: backup %s: %w", path, err)
+		}
+		defer func() { _ = zr.Close() }()
+		return io.ReadAll(zr)
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("apply: backup %s: %w", path, err)
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	}
+	return data, nil
+}
@@ -0,0 +1,50 @@
+//go:build darwin || freebsd
+
+package apply
+
+import "syscall"
+
+// File flags from <sys/stat.h>.
+const (
+	ufImmutable = 0x00000002
+	ufAppend    = 0x00000004
+	sfImmutable = 0x00020000
+	sfAppend    = 0x00040000
+
+	immutableMask = ufImmutable | ufAppend | sfImmutable | sfAppend
+)
+
+func fileFlags(path string) (uint32, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint32(st.Flags), nil
+}
+
+// isImmutable reports whether path carries a user or system immutable or
+// append-only flag (chflags uchg/schg/uappnd/sappnd).
+func isImmutable(path string) bool {
+	flags, err := fileFlags(path)
+	return err == nil && flags&immutableMask != 0
+}
+
+// liftImmutable clears the immutable and append-only flags of path and
+// returns a function that sets them again on the replacement file.
+func liftImmutable(path string) (func(string) error, error) {
+	flags, err := fileFlags(path)
+	if err != nil {
+		return nil, err
+	}
+	held := flags & immutableMask
+	if err := syscall.Chflags(path, int(flags&^held)); err != nil {
+		return nil, err
+	}
+	return func(p string) error {
+		cur, err := fileFlags(p)
+		if err != nil {
+			return err
+		}
+		return syscall.Chflags(p, int(cur|held))
+	}, nil
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package apply
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Inode flags from <linux/fs.h>.
+const (
+	fsImmutableFL = 0x00000010
+	fsAppendFL    = 0x00000020
+)
+
+// _IOR('f', 1, long) and _IOW('f', 2, long); the size field follows the word size.
+var (
+	fsIocGetFlags = uintptr(2<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 1)
+	fsIocSetFlags = uintptr(1<<30 | unsafe.Sizeof(uintptr(0))<<16 | 'f'<<8 | 2)
+)
+
+func inodeFlags(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	var flags int
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocGetFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return 0, errno
+	}
+	return flags, nil
+}
+
+func setInodeFlags(path string, flags int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), fsIocSetFlags, uintptr(unsafe.Pointer(&flags))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isImmutable reports whether path carries the immutable or append-only
+// attribute (chattr +i / +a). Filesystems without inode flags report false.
+func isImmutable(path string) bool {
+	flags, err := inodeFlags(path)
+	return err == nil && flags&(fsImmutableFL|fsAppendFL) != 0
+}
+
+// liftImmutable clears the immutable and append-only attributes of path and
+// returns a function that sets them again on the replacement file.
+func liftImmutable(path string) (func(string) error, error) {
+	flags, err := inodeFlags(path)
+	if err != nil {
+		return nil, err
+	}
+	held := flags & (fsImmutableFL | fsAppendFL)
+	if err := setInodeFlags(path, flags&^held); err != nil {
+		return nil, err
+	}
+	return func(p string) error {
+		cur, err := inodeFlags(p)
+		if err != nil {
+			return err
+		}
+		return setInodeFlags(p, cur|held)
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+
+package apply
+
+import "errors"
+
+// isImmutable always reports false where no immutable attribute is known.
+func isImmutable(string) bool { return false }
+
+func liftImmutable(string) (func(string) error, error) {
+	return nil, errors.New("immutable attributes are not supported on this platform")
+}
@@ -0,0 +1,24 @@
+//go:build darwin
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src with clonefile(2)
+// (APFS).
+func cloneFile(src, dst string, mode os.FileMode) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	switch {
+	case err == nil:
+		return os.Chmod(dst, mode)
+	case errors.Is(err, unix.ENOTSUP), errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.EXDEV):
+		return fmt.Errorf("%w (%v)", errReflinkUnsupported, err)
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as a copy-on-write clone of src sharing its data
+// blocks (btrfs, XFS with reflink=1, bcachefs).
+func cloneFile(src, dst string, mode os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if err = unix.IoctlFileClone(int(w.Fd()), int(r.Fd())); err == nil {
+		err = w.Close()
+	} else {
+		_ = w.Close()
+		switch {
+		case errors.Is(err, unix.EOPNOTSUPP), errors.Is(err, unix.EXDEV), errors.Is(err, unix.EINVAL), errors.Is(err, unix.ENOTTY), errors.Is(err, unix.ENOSYS):
+			err = fmt.Errorf("%w (%v)", errReflinkUnsupported, err)
+		}
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
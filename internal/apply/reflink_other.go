@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package apply
+
+import "os"
+
+func cloneFile(string, string, os.FileMode) error {
+	return errReflinkUnsupported
+}
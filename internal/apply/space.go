@@ -0,0 +1,27 @@
+package apply
+
+import "errors"
+
+// ErrSpaceUnknown is returned by DiskSpace on platforms where free space
+// cannot be queried.
+var ErrSpaceUnknown = errors.New("free disk space unknown on this platform")
+
+// Disk describes the filesystem holding a directory.
+type Disk struct {
+	// Device identifies the filesystem; directories on the same one share it.
+	Device uint64
+	// Free is the number of bytes available to unprivileged users.
+	Free uint64
+}
+
+// SameFilesystem reports whether directories a and b are known to be on the
+// same filesystem, so a file can be renamed from one to the other atomically.
+// It reports false when either cannot be queried.
+func SameFilesystem(a, b string) bool {
+	da, err := DiskSpace(a)
+	if err != nil {
+		return false
+	}
+	db, err := DiskSpace(b)
+	return err == nil && da.Device == db.Device
+}
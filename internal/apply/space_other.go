@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package apply
+
+// DiskSpace always fails with ErrSpaceUnknown where no free space query is known.
+func DiskSpace(string) (Disk, error) { return Disk{}, ErrSpaceUnknown }
+
+// isCrossDevice reports false: without DiskSpace, TempDir is never used.
+func isCrossDevice(error) bool { return false }
@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// DiskSpace reports the filesystem holding dir and its free space.
+func DiskSpace(dir string) (Disk, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return Disk{}, fmt.Errorf("apply: stat %s: %w", dir, err)
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return Disk{}, fmt.Errorf("apply: statfs %s: %w", dir, err)
+	}
+	return Disk{Device: uint64(st.Dev), Free: uint64(fs.Bavail) * uint64(fs.Bsize)}, nil
+}
+
+// isCrossDevice reports whether err is a rename failing across filesystems.
+func isCrossDevice(err error) bool { return errors.Is(err, syscall.EXDEV) }
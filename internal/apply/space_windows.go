@@ -0,0 +1,40 @@
+//go:build windows
+
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, returned by moves across volumes.
+const errNotSameDevice = syscall.Errno(17)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// DiskSpace reports the volume holding dir and its free space.
+func DiskSpace(dir string) (Disk, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Disk{}, fmt.Errorf("apply: %w", err)
+	}
+	p, err := syscall.UTF16PtrFromString(abs)
+	if err != nil {
+		return Disk{}, fmt.Errorf("apply: %w", err)
+	}
+	var free uint64
+	if r, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0); r == 0 {
+		return Disk{}, fmt.Errorf("apply: free space of %s: %w", dir, err)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToUpper(filepath.VolumeName(abs))))
+	return Disk{Device: h.Sum64(), Free: free}, nil
+}
+
+// isCrossDevice reports whether err is a rename failing across volumes.
+func isCrossDevice(err error) bool { return errors.Is(err, errNotSameDevice) }
@@ -0,0 +1,46 @@
+package apply
+
+import (
+	"errors"
+	"strings"
+)
+
+// tempMarker separates the target's name from the random part of temp file
+// names: PREFIX + name + ".tmp-" + digits + SUFFIX.
+const tempMarker = ".tmp-"
+
+// tempPattern returns the os.CreateTemp pattern for temp files of base.
+func tempPattern(base, prefix, suffix string) string {
+	return prefix + base + tempMarker + "*" + suffix
+}
+
+// CheckTempAffix reports whether s can be used as Options.TempPrefix or TempSuffix.
+func CheckTempAffix(s string) error {
+	if strings.ContainsAny(s, `*/\`) {
+		return errors.New("must not contain '*' or path separators")
+	}
+	return nil
+}
+
+// IsTempName reports whether name looks like a temp file WriteAtomic creates
+// with the given prefix and suffix, such as "a.txt.tmp-123456".
+func IsTempName(name, prefix, suffix string) bool {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) || len(name) < len(prefix)+len(suffix) {
+		return false
+	}
+	name = name[len(prefix) : len(name)-len(suffix)]
+	i := strings.LastIndex(name, tempMarker)
+	if i <= 0 {
+		return false
+	}
+	digits := name[i+len(tempMarker):]
+	if digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
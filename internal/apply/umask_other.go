@@ -0,0 +1,8 @@
+//go:build !unix
+
+package apply
+
+import "os"
+
+// umask returns the conventional 022 where the platform has no umask.
+func umask() os.FileMode { return 0o022 }
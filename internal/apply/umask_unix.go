@@ -0,0 +1,19 @@
+//go:build unix
+
+package apply
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+var umaskOnce = sync.OnceValue(func() os.FileMode {
+	// The umask can only be read by setting it; restore it right away.
+	m := syscall.Umask(0)
+	syscall.Umask(m)
+	return os.FileMode(m)
+})
+
+// umask returns the process file mode creation mask.
+func umask() os.FileMode { return umaskOnce() }
@@ -0,0 +1,75 @@
+//go:build linux
+
+package apply
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// copyXattrs copies extended attributes from src to dst. POSIX ACLs live in the
+// system.posix_acl_* attributes, so this carries ACLs over as well. Attributes
+// the destination filesystem or caller cannot set (ENOTSUP, EPERM) are skipped.
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if ignorableXattrErr(err) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		val, err := getXattr(src, name)
+		if err != nil {
+			if ignorableXattrErr(err) || errors.Is(err, syscall.ENODATA) {
+				continue
+			}
+			return err
+		}
+		if err := syscall.Setxattr(dst, name, val, 0); err != nil && !ignorableXattrErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func ignorableXattrErr(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES)
+}
+
+func listXattrs(path string) ([]string, error) {
+	buf, err := readSized(func(b []byte) (int, error) { return syscall.Listxattr(path, b) })
+	if err != nil || len(buf) == 0 {
+		return nil, err
+	}
+	var names []string
+	for _, n := range bytes.Split(bytes.TrimRight(buf, "\x00"), []byte{0}) {
+		names = append(names, string(n))
+	}
+	return names, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return readSized(func(b []byte) (int, error) { return syscall.Getxattr(path, name, b) })
+}
+
+// readSized calls fn once with a nil buffer to learn the size, then again to fill it,
+// retrying if the value grew in between.
+func readSized(fn func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := fn(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = fn(buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
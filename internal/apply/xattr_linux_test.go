@@ -0,0 +1,35 @@
+//go:build linux
+
+package apply
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteAtomic_BackupKeepsXattrs(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(p, []byte("orig"), 0o644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := syscall.Setxattr(p, "user.safereplace.test", []byte("v1"), 0); err != nil {
+		t.Skipf("user xattrs unsupported here: %v", err)
+	}
+
+	bak, err := WriteAtomicWithBackup(p, []byte("new"), Options{Backup: true})
+	if err != nil {
+		t.Fatalf("WriteAtomic: %v", err)
+	}
+	for _, target := range []string{bak, p} {
+		val, err := getXattr(target, "user.safereplace.test")
+		if err != nil {
+			t.Fatalf("%s: getxattr: %v", target, err)
+		}
+		if string(val) != "v1" {
+			t.Fatalf("%s: xattr value: got %q want v1", target, val)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package apply
+
+// copyXattrs is a no-op on platforms where the standard library exposes no
+// extended attribute or ACL API; only the mode is carried over there.
+func copyXattrs(src, dst string) error { return nil }
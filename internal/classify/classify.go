@@ -0,0 +1,97 @@
+// Package classify sorts changed files into coarse kinds (code, docs,
+// config, generated) so a run's summary shows where its changes land.
+package classify
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Kind is the bucket a file falls into.
+type Kind string
+
+// Kinds, in the order summaries list them.
+const (
+	Code      Kind = "code"
+	Docs      Kind = "docs"
+	Config    Kind = "config"
+	Generated Kind = "generated"
+	Other     Kind = "other"
+)
+
+// Kinds lists every Kind in summary order.
+var Kinds = []Kind{Code, Docs, Config, Generated, Other}
+
+// headerSize is how much of the content is searched for generated markers.
+const headerSize = 1024
+
+var (
+	codeExts = set(".go", ".c", ".h", ".cc", ".cpp", ".hpp", ".cs", ".java", ".kt", ".kts", ".scala",
+		".swift", ".m", ".rs", ".py", ".rb", ".php", ".pl", ".lua", ".r", ".js", ".jsx", ".mjs", ".cjs",
+		".ts", ".tsx", ".vue", ".svelte", ".html", ".htm", ".css", ".scss", ".sass", ".less", ".sql",
+		".sh", ".bash", ".zsh", ".fish", ".ps1", ".bat", ".cmd", ".proto", ".graphql", ".tf")
+	docsExts   = set(".md", ".markdown", ".rst", ".adoc", ".asciidoc", ".txt", ".org", ".tex", ".pod")
+	configExts = set(".json", ".yaml", ".yml", ".toml", ".ini", ".cfg", ".conf", ".env", ".properties",
+		".xml", ".plist", ".hcl", ".editorconfig", ".gitignore", ".gitattributes", ".dockerignore")
+	// names are matched case-insensitively without extension.
+	codeNames   = set("makefile", "rakefile", "gemfile", "justfile")
+	docsNames   = set("readme", "license", "licence", "changelog", "changes", "authors", "contributing", "notice", "copying")
+	configNames = set("dockerfile", "containerfile", "procfile", "codeowners", "go.mod", ".npmrc")
+	// lockNames are written by package managers.
+	lockNames = set("go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "cargo.lock", "poetry.lock",
+		"gemfile.lock", "composer.lock", "pipfile.lock", "flake.lock")
+	generatedSuffixes = []string{".pb.go", "_generated.go", ".gen.go", "_string.go", ".min.js", ".min.css", ".map"}
+	generatedDirs     = []string{"vendor", "node_modules", "third_party", "dist", "gen", "generated"}
+	// generatedMarkers appear near the top of generated files.
+	generatedMarkers = [][]byte{[]byte("DO NOT EDIT"), []byte("@generated"), []byte("<auto-generated")}
+)
+
+func set(items ...string) map[string]bool {
+	m := make(map[string]bool, len(items))
+	for _, s := range items {
+		m[s] = true
+	}
+	return m
+}
+
+// Classify returns the kind of the file at path with content data.
+// Generated files are recognized first, by lock file names, suffixes such as
+// .pb.go, directories such as vendor/, or a marker like "Code generated ...
+// DO NOT EDIT." or "@generated" near the top; the rest go by extension or
+// well-known names.
+func Classify(path string, data []byte) Kind {
+	base := strings.ToLower(filepath.Base(path))
+	if lockNames[base] {
+		return Generated
+	}
+	for _, s := range generatedSuffixes {
+		if strings.HasSuffix(base, s) {
+			return Generated
+		}
+	}
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		for _, g := range generatedDirs {
+			if dir == g {
+				return Generated
+			}
+		}
+	}
+	head := data[:min(len(data), headerSize)]
+	for _, m := range generatedMarkers {
+		if bytes.Contains(head, m) {
+			return Generated
+		}
+	}
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	switch {
+	case configNames[base] || configExts[ext]:
+		return Config
+	case codeNames[base] || codeExts[ext]:
+		return Code
+	case docsNames[name] || docsNames[base] || docsExts[ext]:
+		return Docs
+	}
+	return Other
+}
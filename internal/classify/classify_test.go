@@ -0,0 +1,30 @@
+package classify
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		path, content string
+		want          Kind
+	}{
+		{"cmd/main.go", "package main\n", Code},
+		{"Makefile", "all:\n", Code},
+		{"docs/guide.md", "# Guide\n", Docs},
+		{"README", "hello\n", Docs},
+		{"LICENSE.txt", "MIT\n", Docs},
+		{"config/app.yaml", "a: 1\n", Config},
+		{".gitignore", "bin/\n", Config},
+		{"Dockerfile", "FROM scratch\n", Config},
+		{"go.mod", "module x\n", Config},
+		{"api/v1/api.pb.go", "package v1\n", Generated},
+		{"zz.go", "// Code generated by stringer; DO NOT EDIT.\n\npackage x\n", Generated},
+		{"go.sum", "x v1 h1:\n", Generated},
+		{"vendor/github.com/x/y.go", "package y\n", Generated},
+		{"schema.graphql", "# @generated\n", Generated},
+		{"photo.xyz", "", Other},
+	} {
+		if got := Classify(tc.path, []byte(tc.content)); got != tc.want {
+			t.Errorf("%s: got %s want %s", tc.path, got, tc.want)
+		}
+	}
+}
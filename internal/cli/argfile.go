@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// expandArgFiles replaces each standalone "@FILE" argument with the arguments
+// listed in FILE, one per line, taken literally: no quoting, escaping or
+// further @ expansion. Blank lines and lines starting with "#" are skipped,
+// and a CRLF line ending is the same as LF. The value of a flag, as in
+// "--pattern @Override", is never expanded; elsewhere "@@x" stands for a
+// literal "@x". Arguments after "--" are left alone.
+func expandArgFiles(args []string) ([]string, error) {
+	fs := newFlagSet(new(Config))
+	var out []string
+	// value is set when the next argument is the value of the previous flag.
+	value := false
+	add := func(a string) {
+		out = append(out, a)
+		value = !value && takesValue(fs, a)
+	}
+	for i, a := range args {
+		switch {
+		case value:
+			add(a)
+			continue
+		case a == "--":
+			return append(out, args[i:]...), nil
+		case strings.HasPrefix(a, "@@"):
+			add(a[1:])
+			continue
+		}
+		name, ok := strings.CutPrefix(a, "@")
+		if !ok || name == "" {
+			add(a)
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("argument file: %w (write @@ for an argument starting with @)", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			add(line)
+		}
+	}
+	return out, nil
+}
+
+// takesValue reports whether a is a flag of fs, given without "=", that
+// takes the next argument as its value, such as "--pattern"; boolean flags
+// such as "-0" do not.
+func takesValue(fs *pflag.FlagSet, a string) bool {
+	var f *pflag.Flag
+	switch {
+	case strings.HasPrefix(a, "--"):
+		if strings.Contains(a, "=") {
+			return false
+		}
+		f = fs.Lookup(a[2:])
+	case len(a) == 2 && a[0] == '-':
+		f = fs.ShorthandLookup(a[1:])
+	}
+	return f != nil && f.NoOptDefVal == ""
+}
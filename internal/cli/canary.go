@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"safereplace/internal/journal"
+	"safereplace/internal/plan"
+)
+
+// parseCanary parses --canary as a percentage in (0, 100], with or without "%".
+func parseCanary(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 || math.IsNaN(pct) {
+		return 0, fmt.Errorf("--canary: want a percentage in (0, 100], got %q", s)
+	}
+	return pct, nil
+}
+
+// deferCanary keeps pct percent of the changed results (at least one) for
+// this run and marks the rest skipped, returning them. The canary files are
+// those with the lowest hash of their slash-separated path relative to the
+// working directory, so repeated runs pick the same files.
+func deferCanary(results []fileResult, pct float64) []fileResult {
+	type ranked struct {
+		i    int
+		hash uint64
+	}
+	var changed []ranked
+	for i, r := range results {
+		if r.err == nil && r.skip == "" {
+			h := fnv.New64a()
+			h.Write([]byte(filepath.ToSlash(displayPath(r.row.Path))))
+			changed = append(changed, ranked{i, h.Sum64()})
+		}
+	}
+	slices.SortFunc(changed, func(a, b ranked) int { return cmp.Compare(a.hash, b.hash) })
+	keep := max(1, int(math.Ceil(float64(len(changed))*pct/100)))
+	var deferred []fileResult
+	for _, c := range changed[min(keep, len(changed)):] {
+		deferred = append(deferred, results[c.i])
+		results[c.i].skip = "deferred by --canary"
+		results[c.i].row.Status = "deferred"
+	}
+	return deferred
+}
+
+// planFiles converts results into plan entries, in order.
+func planFiles(results []fileResult) []plan.File {
+	files := make([]plan.File, 0, len(results))
+	for _, r := range results {
+		f := plan.File{
+			Path:         filepath.ToSlash(displayPath(r.row.Path)),
+			Matches:      r.res.Matches,
+			Replacements: r.res.Replacements,
+			BeforeSHA256: journal.Hash(r.res.Before),
+			AfterSHA256:  journal.Hash(r.res.After),
+		}
+		for _, fd := range findings(r.res) {
+			f.Edits = append(f.Edits, plan.Edit{Line: fd.line, Col: fd.col, Old: string(fd.old), New: string(fd.new)})
+		}
+		files = append(files, f)
+	}
+	return files
+}
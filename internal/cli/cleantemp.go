@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"safereplace/internal/apply"
+)
+
+// runCleanTemp removes temp files orphaned by crashed runs:
+//
+//	safereplace clean-temp [--root DIR] [--older-than 10m] [--temp-prefix P] [--temp-suffix S] [--dry-run]
+//
+// Only regular files named like apply's temp files and last modified before
+// --older-than are removed, so temp files of a run in progress are left alone.
+func runCleanTemp(args []string, stdout, stderr io.Writer) int {
+	var root, prefix, suffix string
+	var olderThan time.Duration
+	var dryRun bool
+	fs := pflag.NewFlagSet("safereplace clean-temp", pflag.ContinueOnError)
+	fs.StringVar(&root, "root", ".", "Directory tree to search")
+	fs.DurationVar(&olderThan, "older-than", 10*time.Minute, "Only remove temp files last modified at least this long ago")
+	fs.StringVar(&prefix, "temp-prefix", "", "Temp file prefix the runs used (--temp-prefix)")
+	fs.StringVar(&suffix, "temp-suffix", "", "Temp file suffix the runs used (--temp-suffix)")
+	fs.BoolVar(&dryRun, "dry-run", false, "List the files that would be removed")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, a := range []string{prefix, suffix} {
+		if err := apply.CheckTempAffix(a); err != nil {
+			fmt.Fprintf(stderr, "clean-temp: --temp-prefix/--temp-suffix: %v\n", err)
+			return 2
+		}
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var hadErrors, removed bool
+	werr := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(stderr, "warn: %s: %v\n", p, err)
+			hadErrors = true
+			return nil
+		}
+		if !d.Type().IsRegular() || !apply.IsTempName(d.Name(), prefix, suffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if !dryRun {
+			if err := os.Remove(p); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				hadErrors = true
+				return nil
+			}
+		}
+		fmt.Fprintf(stdout, "removed: %s\n", p)
+		removed = true
+		return nil
+	})
+	if werr != nil {
+		fmt.Fprintf(stderr, "error: %v\n", werr)
+		return 2
+	}
+
+	if hadErrors {
+		return 2
+	}
+	if removed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"safereplace/internal/processor"
+)
+
+// cqIssue is one entry of a GitLab Code Quality report.
+type cqIssue struct {
+	Description string     `json:"description"`
+	CheckName   string     `json:"check_name"`
+	Fingerprint string     `json:"fingerprint"`
+	Severity    string     `json:"severity"`
+	Location    cqLocation `json:"location"`
+}
+
+type cqLocation struct {
+	Path  string  `json:"path"`
+	Lines cqLines `json:"lines"`
+}
+
+type cqLines struct {
+	Begin int `json:"begin"`
+}
+
+// codeQualityIssues converts the replacements of path p into report entries.
+// Fingerprints hash the path, position and message, so an unchanged finding
+// keeps its identity across pipelines.
+func codeQualityIssues(p string, res processor.Result) []cqIssue {
+	name := filepath.ToSlash(displayPath(p))
+	var issues []cqIssue
+	for _, f := range findings(res) {
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s:%d:%d:%s", name, f.line, f.col, f.msg))
+		issues = append(issues, cqIssue{
+			Description: f.msg,
+			CheckName:   "safereplace",
+			Fingerprint: hex.EncodeToString(sum[:16]),
+			Severity:    "minor",
+			Location:    cqLocation{Path: name, Lines: cqLines{Begin: f.line}},
+		})
+	}
+	return issues
+}
+
+// writeCodeQuality writes issues as a GitLab Code Quality report to path; an
+// empty run writes an empty list so the report artifact always exists.
+func writeCodeQuality(path string, issues []cqIssue) error {
+	if issues == nil {
+		issues = []cqIssue{}
+	}
+	data, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
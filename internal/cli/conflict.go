@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"safereplace/internal/diff"
+	"safereplace/internal/processor"
+)
+
+// drift re-reads p just before it is written and returns its content when it
+// no longer matches the previewed res.Before. Read errors are left to the
+// write that follows.
+func drift(p string, res processor.Result) ([]byte, bool) {
+	current, err := processor.ReadFile(p)
+	if err != nil || bytes.Equal(current, res.Before) {
+		return nil, false
+	}
+	return current, true
+}
+
+// reportDrift shows how the on-disk content of p diverged from the preview,
+// as a diff3-style view of the proposed edit, the previewed base and the file
+// now, then re-plans just p so the change that would now be made is visible.
+// Nothing is written: the re-planned change was never confirmed.
+func reportDrift(stdout, stderr io.Writer, p string, res processor.Result, current []byte, replan func(string) (fileResult, bool)) {
+	fmt.Fprintf(stderr, "conflict: %s changed since it was previewed; not applied\n", p)
+	if !res.Binary && !processor.IsBinary(current) {
+		fmt.Fprint(stdout, diff.Diff3(res.Before, res.After, current, "proposed", "base (previewed)", "on disk"))
+	}
+	fr, ok := replan(p)
+	switch {
+	case !ok:
+		return
+	case fr.err != nil:
+		fmt.Fprintf(stderr, "warn: re-plan %s: %v\n", p, fr.err)
+	case fr.skip != "":
+		fmt.Fprintf(stdout, "re-planned: %s: %s\n", p, fr.skip)
+	default:
+		fmt.Fprintf(stdout, "re-planned: %s  (matches: %d, replacements: %d)\n", p, fr.res.Matches, fr.res.Replacements)
+		fmt.Fprint(stdout, fr.preview)
+		fmt.Fprintf(stderr, "hint: re-run with --files %s to apply the re-planned change\n", displayPath(p))
+	}
+}
+
+// mergeDrift merges the proposed edit of p onto its current content for
+// --merge and returns the result to write instead, with the number of
+// conflicts left marked in it. Binary content cannot be merged by lines;
+// callers report such drift as a conflict instead.
+func mergeDrift(stderr io.Writer, p string, res processor.Result, current []byte) (processor.Result, int) {
+	merged, conflicts := diff.Merge3(res.Before, res.After, current, "safereplace", "base (previewed)", "on disk")
+	if conflicts > 0 {
+		fmt.Fprintf(stderr, "conflict: %s changed since it was previewed; %d conflict(s) marked in the file\n", p, conflicts)
+	} else {
+		fmt.Fprintf(stderr, "merge: %s changed since it was previewed; edits merged onto the current content\n", p)
+	}
+	return rebase(res, current, merged), conflicts
+}
+
+// rebase turns res into the change from current to after, which replaces a
+// previewed change once p drifted. The previewed edits point into the
+// previewed content, so the edits and counts are recomputed from the lines
+// that differ: each changed region counts as one replacement.
+func rebase(res processor.Result, current, after []byte) processor.Result {
+	res.Before, res.After = current, after
+	res.Changed = !bytes.Equal(current, after)
+	res.Edits = nil
+	a, b := splitLines(current), splitLines(after)
+	offA, offB := lineOffsets(a), lineOffsets(b)
+	ops := diff.LineOps(a, b)
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == diff.OpEqual {
+			i++
+			continue
+		}
+		a1, a2, b1, b2 := ops[i].A1, ops[i].A2, ops[i].B1, ops[i].B2
+		for i++; i < len(ops) && ops[i].Kind != diff.OpEqual; i++ {
+			a2, b2 = max(a2, ops[i].A2), max(b2, ops[i].B2)
+		}
+		// Narrow the region to the bytes that differ.
+		start, end := offA[a1], offA[a2]
+		text := after[offB[b1]:offB[b2]]
+		for start < end && len(text) > 0 && current[start] == text[0] {
+			start, text = start+1, text[1:]
+		}
+		for start < end && len(text) > 0 && current[end-1] == text[len(text)-1] {
+			end, text = end-1, text[:len(text)-1]
+		}
+		res.Edits = append(res.Edits, processor.Edit{Start: start, End: end, Text: text})
+	}
+	res.Matches, res.Replacements = len(res.Edits), len(res.Edits)
+	return res
+}
+
+// lineOffsets returns the byte offset of each line and of the end.
+func lineOffsets(lines []string) []int {
+	offs := make([]int, 1, len(lines)+1)
+	for _, l := range lines {
+		offs = append(offs, offs[len(offs)-1]+len(l))
+	}
+	return offs
+}
+
+// splitLines splits data after each newline, keeping a final line without one.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// Answers to the --interactive conflict prompt.
+const (
+	resolveMine   = "m" // write the proposed edit over the file's new content
+	resolveTheirs = "t" // keep the file as it is on disk
+	resolveEdit   = "e" // edit the three-way merge in $EDITOR and write that
+	resolveSkip   = "s" // leave the conflict unresolved
+)
+
+// promptDrift asks how to resolve a file that changed since its preview and
+// returns the answer with the content to write for resolveMine and
+// resolveEdit. End of input answers resolveSkip.
+func promptDrift(in *bufio.Reader, stdout, stderr io.Writer, p string, res processor.Result, current []byte) (string, []byte) {
+	merged, _ := diff.Merge3(res.Before, res.After, current, "safereplace", "base (previewed)", "on disk")
+	if !res.Binary && !processor.IsBinary(current) {
+		fmt.Fprint(stdout, diff.Diff3(res.Before, res.After, current, "proposed", "base (previewed)", "on disk"))
+	}
+	for {
+		fmt.Fprintf(stdout, "conflict: %s changed since it was previewed: [m]ine, [t]heirs, [e]dit merge, [s]kip? ", p)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(stdout)
+			return resolveSkip, nil
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(line)); answer {
+		case resolveMine:
+			return answer, res.After
+		case resolveTheirs, resolveSkip:
+			return answer, nil
+		case resolveEdit:
+			edited, err := editMerge(p, merged)
+			if err != nil {
+				fmt.Fprintf(stderr, "warn: %s: editor: %v\n", p, err)
+				continue
+			}
+			if hasConflictMarkers(edited) {
+				// Keep the edits so the next [e] continues from them.
+				merged = edited
+				fmt.Fprintf(stderr, "warn: %s: conflict markers remain\n", p)
+				continue
+			}
+			return answer, edited
+		}
+	}
+}
+
+// editMerge opens content in $VISUAL or $EDITOR (vi when neither is set) and
+// returns it as saved. The temp file keeps p's extension for syntax
+// highlighting.
+func editMerge(p string, content []byte) ([]byte, error) {
+	editor := strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi"))
+	f, err := os.CreateTemp("", "safereplace-merge-*"+filepath.Ext(p))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, werr := f.Write(content)
+	if err := errors.Join(werr, f.Close()); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(f.Name())
+}
+
+// hasConflictMarkers reports whether data still holds a conflict block
+// written by diff.Merge3.
+func hasConflictMarkers(data []byte) bool {
+	for _, marker := range []string{"<<<<<<< ", "=======\n", ">>>>>>> "} {
+		if !bytes.HasPrefix(data, []byte(marker)) && !bytes.Contains(data, []byte("\n"+marker)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffFilePath returns where --diff-dir stores the preview for p:
+// dir/<relpath>.diff. Paths outside the working directory keep their absolute
+// layout below dir, with the volume name and leading separator dropped.
+func diffFilePath(dir, p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	rel := displayPath(p)
+	if filepath.IsAbs(rel) {
+		rel = strings.TrimLeft(strings.TrimPrefix(rel, filepath.VolumeName(rel)), `/\`)
+	}
+	return filepath.Join(dir, rel+".diff")
+}
+
+// writeDiffFile stores preview for p under dir and returns the file written.
+func writeDiffFile(dir, p, preview string) (string, error) {
+	out := diffFilePath(dir, p)
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return "", fmt.Errorf("diff-dir: %w", err)
+	}
+	if err := os.WriteFile(out, []byte(preview), 0o644); err != nil {
+		return "", fmt.Errorf("diff-dir: %w", err)
+	}
+	return out, nil
+}
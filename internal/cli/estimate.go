@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Rough costs of an atomic rewrite beyond processing the file again, used to
+// project apply time from a dry run.
+const (
+	estWriteRate = 200 << 20 // bytes per second written to a temp file
+	estSyncCost  = 2 * time.Millisecond
+)
+
+// estimate is the projected cost of applying a dry run, printed by --estimate.
+type estimate struct {
+	files   int
+	rewrite int64         // bytes written by atomic rewrites
+	backup  int64         // bytes stored as backup copies
+	process time.Duration // measured processing time of the changed files
+}
+
+// estimateRun sums the changed results. Backup space counts originals when
+// backups are copied (--backup, --backup-archive or --backup-to-trash),
+// before any compression; reflinked backups share the originals' blocks.
+func estimateRun(cfg Config, results []fileResult) estimate {
+	var e estimate
+	backups := cfg.Backup && !cfg.BackupReflink || cfg.BackupArchive != "" || cfg.BackupToTrash
+	for _, r := range results {
+		if r.err != nil || r.skip != "" {
+			continue
+		}
+		e.files++
+		e.rewrite += int64(len(r.res.After))
+		if backups {
+			e.backup += int64(len(r.res.Before))
+		}
+		e.process += r.elapsed
+	}
+	return e
+}
+
+// apply projects the apply time: processing again, writing and syncing each file.
+func (e estimate) apply() time.Duration {
+	return e.process + time.Duration(e.rewrite*int64(time.Second)/estWriteRate) + time.Duration(e.files)*estSyncCost
+}
+
+func writeEstimate(w io.Writer, e estimate) {
+	fmt.Fprintf(w, "estimate: %d files, %s to rewrite, %s of backups, apply ~%s (processing took %s)\n",
+		e.files, formatBytes(e.rewrite), formatBytes(e.backup), e.apply().Round(time.Millisecond), e.process.Round(time.Microsecond))
+}
+
+// formatBytes renders n with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Lifecycle event names emitted with --events ndjson.
+const (
+	evFileDiscovered = "file-discovered"
+	evFileSkipped    = "file-skipped"
+	evFilePreviewed  = "file-previewed"
+	evFileApplied    = "file-applied"
+	evError          = "error"
+)
+
+// event is a single NDJSON record. Zero-valued optional fields are omitted.
+type event struct {
+	Time         string `json:"time"`
+	RunID        string `json:"run_id"`
+	Event        string `json:"event"`
+	Path         string `json:"path,omitempty"`
+	Matches      int    `json:"matches,omitempty"`
+	Replacements int    `json:"replacements,omitempty"`
+	BeforeSHA256 string `json:"before_sha256,omitempty"`
+	AfterSHA256  string `json:"after_sha256,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// eventSink writes events as newline-delimited JSON. A nil sink discards events,
+// so call sites do not need to check whether --events was given.
+type eventSink struct {
+	enc   *json.Encoder
+	runID string
+	// local stamps events in local time instead of UTC (--local-time).
+	local bool
+}
+
+func newEventSink(w io.Writer, runID string, local bool) *eventSink {
+	return &eventSink{enc: json.NewEncoder(w), runID: runID, local: local}
+}
+
+func (s *eventSink) emit(e event) {
+	if s == nil {
+		return
+	}
+	// Nanoseconds keep events of one run in order.
+	e.Time = inZone(time.Now(), s.local).Format(time.RFC3339Nano)
+	e.RunID = s.runID
+	// Encoder.Encode appends the newline; write errors are not fatal to the run.
+	_ = s.enc.Encode(e)
+}
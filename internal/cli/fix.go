@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"safereplace/internal/processor"
+)
+
+// defaultRetabWidth is the tab width of --retab tabs.
+const defaultRetabWidth = 4
+
+// Operations accepted by --normalize.
+const (
+	normalizeSort   = "sort-lines"
+	normalizeUnique = "unique-lines"
+)
+
+// fixesFor returns the built-in fixes cfg asks for, run instead of a pattern.
+func fixesFor(cfg Config) []processor.Fix {
+	var fixes []processor.Fix
+	if width, tabs, _ := parseRetab(cfg.Retab); width > 0 { // validated in parseArgs
+		fixes = append(fixes, processor.Retab(width, tabs))
+	}
+	if len(cfg.Normalize) > 0 {
+		fixes = append(fixes, processor.NormalizeLines(slices.Contains(cfg.Normalize, normalizeSort), slices.Contains(cfg.Normalize, normalizeUnique)))
+	}
+	if cfg.FixTrailingWhitespace {
+		fixes = append(fixes, processor.TrailingWhitespace)
+	}
+	if cfg.EnsureFinalNewline {
+		fixes = append(fixes, processor.FinalNewline)
+	}
+	return fixes
+}
+
+// parseRetab parses a --retab value, "spaces=N", "tabs" or "tabs=N". The
+// width is 0 for an empty value.
+func parseRetab(s string) (width int, tabs bool, err error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	kind, n, hasWidth := strings.Cut(s, "=")
+	switch kind {
+	case "tabs":
+		tabs = true
+		if !hasWidth {
+			return defaultRetabWidth, true, nil
+		}
+	case "spaces":
+		if !hasWidth {
+			return 0, false, fmt.Errorf("--retab: want spaces=N, got %q", s)
+		}
+	default:
+		return 0, false, fmt.Errorf("--retab: want spaces=N or tabs[=N], got %q", s)
+	}
+	width, err = strconv.Atoi(n)
+	if err != nil || width < 1 || width > 16 {
+		return 0, false, fmt.Errorf("--retab: width must be 1 to 16, got %q", n)
+	}
+	return width, tabs, nil
+}
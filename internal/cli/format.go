@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"safereplace/internal/processor"
+)
+
+// Values accepted by --format.
+const (
+	formatDiff     = "diff"
+	formatQuickfix = "quickfix"
+	formatGitHub   = "github"
+	formatMbox     = "mbox"
+)
+
+// maxQuoted bounds the text quoted in a finding message.
+const maxQuoted = 60
+
+// finding is one replacement located in the original content, 1-based.
+type finding struct {
+	line, col int
+	old, new  []byte
+	msg       string
+}
+
+// findings lists the replacements of res by position in res.Before.
+func findings(res processor.Result) []finding {
+	out := make([]finding, 0, len(res.Edits))
+	line, lineStart, off := 1, 0, 0
+	for _, e := range res.Edits {
+		for ; off < e.Start; off++ {
+			if res.Before[off] == '\n' {
+				line++
+				lineStart = off + 1
+			}
+		}
+		old := res.Before[e.Start:e.End]
+		msg := fmt.Sprintf("replace %s with %s", quoteShort(old), quoteShort(e.Text))
+		out = append(out, finding{line: line, col: e.Start - lineStart + 1, old: old, new: e.Text, msg: msg})
+	}
+	return out
+}
+
+// quoteShort quotes b, shortened to maxQuoted bytes.
+func quoteShort(b []byte) string {
+	if len(b) > maxQuoted {
+		return strconv.Quote(string(b[:maxQuoted])) + "..."
+	}
+	return strconv.Quote(string(b))
+}
+
+// writeFindings prints the replacements of path p in the --format style:
+// quickfix lines "file:line:col: message" read by Vim's quickfix list and
+// Emacs compilation-mode (columns count bytes, as both editors expect), or
+// GitHub Actions workflow commands that become pull request annotations.
+func writeFindings(w io.Writer, format, p string, res processor.Result) {
+	name := displayPath(p)
+	for _, f := range findings(res) {
+		switch format {
+		case formatQuickfix:
+			fmt.Fprintf(w, "%s:%d:%d: %s\n", name, f.line, f.col, f.msg)
+		case formatGitHub:
+			fmt.Fprintf(w, "::warning file=%s,line=%d,col=%d,title=safereplace::%s\n",
+				githubProperty(filepath.ToSlash(name)), f.line, f.col, githubData(f.msg))
+		}
+	}
+}
+
+// githubData escapes a workflow command message.
+var githubData = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace
+
+// githubProperty escapes a workflow command property value.
+var githubProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace
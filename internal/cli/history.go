@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"safereplace/internal/history"
+)
+
+// recordHistory appends the finished run to the user's history. Failing to
+// record is only a warning: the run itself is done.
+func recordHistory(e history.Entry, args []string, code int, stderr io.Writer) {
+	path, err := history.Path()
+	if err != nil {
+		fmt.Fprintf(stderr, "warn: history: %v\n", err)
+		return
+	}
+	e.Time = timestamp(time.Now(), false)
+	e.Dir, _ = os.Getwd()
+	e.Args = args
+	e.Exit = code
+	if err := history.Append(path, e); err != nil {
+		fmt.Fprintf(stderr, "warn: %v\n", err)
+	}
+}
+
+// changeTotals counts the files and replacements a run changed, or would
+// change in a dry run.
+func changeTotals(rows []fileSummary) (files, replacements int) {
+	for _, r := range rows {
+		if r.Status == "preview" || r.Status == "applied" {
+			files++
+			replacements += r.Replacements
+		}
+	}
+	return files, replacements
+}
+
+// runHistory lists past runs, oldest first:
+//
+//	safereplace history [--limit N]
+//
+// Each line shows the number to pass to rerun, the time, exit code, totals,
+// directory and arguments.
+func runHistory(args []string, stdout, stderr io.Writer) int {
+	var limit int
+	fs := pflag.NewFlagSet("safereplace history", pflag.ContinueOnError)
+	fs.IntVar(&limit, "limit", 0, "Show only the last N runs (0 = all)")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if fs.NArg() > 0 || limit < 0 {
+		fmt.Fprintln(stderr, "usage: safereplace history [--limit N]")
+		return 2
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	first := 0
+	if limit > 0 && len(entries) > limit {
+		first = len(entries) - limit
+	}
+	for i, e := range entries[first:] {
+		fmt.Fprintf(stdout, "%4d  %s  exit %d  %d files, %d replacements  %s  safereplace %s\n",
+			first+i+1, e.Time, e.Exit, e.Files, e.Replacements, e.Dir, quoteArgs(e.Args))
+	}
+	return 0
+}
+
+// runRerun repeats run N of the history in its directory, with any further
+// arguments appended so they override the recorded ones:
+//
+//	safereplace rerun N [FLAGS...]
+//
+// e.g. "rerun 12 --dry-run=false" applies a run that was previewed. The rerun
+// is recorded as a new entry and exits with its exit code.
+func runRerun(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: safereplace rerun N [FLAGS...]")
+		return 2
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		fmt.Fprintf(stderr, "rerun: want a run number from safereplace history, got %q\n", args[0])
+		return 2
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if n > len(entries) {
+		fmt.Fprintf(stderr, "rerun: no run %d in history (%d recorded)\n", n, len(entries))
+		return 2
+	}
+	e := entries[n-1]
+	if wd, _ := os.Getwd(); e.Dir != "" && e.Dir != wd {
+		if err := os.Chdir(e.Dir); err != nil {
+			fmt.Fprintf(stderr, "error: rerun: %v\n", err)
+			return 2
+		}
+		defer func() { _ = os.Chdir(wd) }()
+	}
+	rerun := append(append([]string(nil), e.Args...), args[1:]...)
+	fmt.Fprintf(stderr, "rerun: %s: safereplace %s\n", e.Dir, quoteArgs(rerun))
+	return RunWithStdin(rerun, stdin, stdout, stderr)
+}
+
+// loadHistory reads the user's history file.
+func loadHistory() ([]history.Entry, error) {
+	path, err := history.Path()
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return history.Load(path)
+}
+
+// plainArg matches arguments shown without quotes.
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9_./=:,+@%-]+$`)
+
+// quoteArgs joins args for display, quoting those that need it for the shell.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if plainArg.MatchString(a) {
+			quoted[i] = a
+		} else {
+			quoted[i] = shellQuote(a)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Values accepted by --hyperlinks.
+const (
+	linksAuto   = "auto"
+	linksAlways = "always"
+	linksNever  = "never"
+)
+
+// supportsHyperlinks guesses whether w is a terminal that renders OSC 8
+// hyperlinks, from the variables set by terminals known to.
+func supportsHyperlinks(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	for _, env := range []string{"WT_SESSION", "KITTY_WINDOW_ID", "KONSOLE_VERSION"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	switch os.Getenv("TERM") {
+	case "xterm-kitty", "foot", "alacritty", "xterm-ghostty":
+		return true
+	}
+	return false
+}
+
+// hyperlink wraps text in an OSC 8 escape sequence linking it to target.
+func hyperlink(target, text string) string {
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// linkTarget returns the URL a file header links to: a file:// URL for p, or
+// tmpl with {path} (relative to the working directory, with forward
+// slashes), {abspath} and {line} (the first changed line) filled in.
+func linkTarget(tmpl, p string, line int) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		abs = p
+	}
+	slashAbs := filepath.ToSlash(abs)
+	if !strings.HasPrefix(slashAbs, "/") {
+		slashAbs = "/" + slashAbs // C:/x on Windows
+	}
+	if tmpl == "" {
+		host, _ := os.Hostname()
+		return (&url.URL{Scheme: "file", Host: host, Path: slashAbs}).String()
+	}
+	return strings.NewReplacer(
+		"{path}", escapePath(filepath.ToSlash(displayPath(p))),
+		"{abspath}", escapePath(slashAbs),
+		"{line}", strconv.Itoa(line),
+	).Replace(tmpl)
+}
+
+// escapePath URL-escapes each segment of a slash-separated path.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// firstChangedLine returns the 1-based line of before where after first
+// differs from it.
+func firstChangedLine(before, after []byte) int {
+	i := 0
+	for i < len(before) && i < len(after) && before[i] == after[i] {
+		i++
+	}
+	return bytes.Count(before[:i], []byte("\n")) + 1
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"safereplace/internal/processor"
+)
+
+// errNotIdempotent marks files a second pass of the same replacement would
+// change again, for --assert-idempotent.
+var errNotIdempotent = errors.New("not idempotent")
+
+// substituteAgain runs the matcher that produced a file's result over data,
+// its new content, in memory.
+func substituteAgain(ctx context.Context, cfg Config, transform processor.Transformer, re *regexp.Regexp, p string, set fileSettings, data []byte) (processor.Result, error) {
+	switch fixes := fixesFor(cfg); {
+	case len(fixes) > 0:
+		return processor.ApplyFixes(data, fixes), nil
+	case len(set.rules) > 0:
+		return substituteLiteralsAgain(p, data, set.rules, set.proc)
+	case transform != nil:
+		return processor.SubstituteCommand(ctx, transform, p, data, cfg.Pattern, set.replace)
+	case cfg.Mode == modeEnvKey:
+		return processor.SubstituteEnvKey(data, cfg.Key, set.replace)
+	case cfg.Mode == modeGoIdent && strings.EqualFold(filepath.Ext(p), ".go"):
+		return processor.SubstituteGoIdent(p, data, cfg.Pattern, cfg.Replace)
+	case re != nil && set.proc.Scope != nil:
+		spans, err := set.proc.Scope(data)
+		if err != nil {
+			return processor.Result{}, fmt.Errorf("%s: %w", p, err)
+		}
+		return processor.SubstituteRegexInSpans(data, re, []byte(set.replace), !cfg.NoExpand, spans), nil
+	case re != nil:
+		return processor.SubstituteRegex(data, re, []byte(set.replace), !cfg.NoExpand), nil
+	}
+	return substituteLiteralsAgain(p, data, []processor.Replacement{{Pattern: []byte(cfg.Pattern), Replace: []byte(set.replace)}}, set.proc)
+}
+
+// substituteLiteralsAgain is processor.SubstituteLiteralsWithOptions with
+// scope errors naming p.
+func substituteLiteralsAgain(p string, data []byte, reps []processor.Replacement, opts processor.Options) (processor.Result, error) {
+	res, err := processor.SubstituteLiteralsWithOptions(data, reps, opts)
+	if err != nil {
+		return processor.Result{}, fmt.Errorf("%s: %w", p, err)
+	}
+	return res, nil
+}
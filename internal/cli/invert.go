@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+
+	"safereplace/internal/rules"
+)
+
+// runInvert writes the rule set undoing a rule file, so a migration can ship
+// with its rollback:
+//
+//	safereplace invert --rules rules.yaml --out undo-rules.yaml
+//
+// Rules that cannot be inverted are left out and reported on stderr. It
+// exits 0 when every rule was inverted, 1 when some were left out, and 2 on
+// errors.
+func runInvert(args []string, stdout, stderr io.Writer) int {
+	var in, out string
+	fs := pflag.NewFlagSet("safereplace invert", pflag.ContinueOnError)
+	fs.StringVar(&in, "rules", "", "Rule file to invert (required)")
+	fs.StringVar(&out, "out", "", "Write the inverse rules to this file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if in == "" {
+		fmt.Fprintln(stderr, "invert: --rules is required")
+		return 2
+	}
+	rs, err := rules.Fetcher{}.Load(context.Background(), in)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+
+	inv, skipped := rules.Invert(rs)
+	for _, s := range skipped {
+		fmt.Fprintf(stderr, "skip: %s\n", s)
+	}
+	if len(inv) == 0 {
+		fmt.Fprintln(stderr, "error: invert: no rule can be inverted")
+		return 2
+	}
+	var buf bytes.Buffer
+	_ = rules.Write(&buf, inv,
+		"Inverse of "+filepath.Base(in)+", generated by safereplace invert.",
+		"It also rewrites text that matched a replacement before the migration.")
+	if out == "" {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: invert: %v\n", err)
+		return 2
+	}
+	if len(skipped) > 0 {
+		return 1
+	}
+	return 0
+}
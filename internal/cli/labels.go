@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// labelsGit is the --diff-labels shorthand for git's a/ and b/ prefixes.
+const labelsGit = "git"
+
+// parseDiffLabels splits a --diff-labels value into the old and new label
+// templates.
+func parseDiffLabels(s string) (before, after string, err error) {
+	if s == labelsGit {
+		return "a/{path}", "b/{path}", nil
+	}
+	before, after, ok := strings.Cut(s, ",")
+	if !ok || before == "" || after == "" || strings.Contains(after, ",") {
+		return "", "", fmt.Errorf("--diff-labels: want git or OLD,NEW (e.g. a/{path},b/{path}), got %q", s)
+	}
+	return before, after, nil
+}
+
+// diffLabels returns the diff header labels --diff-labels gives file p, with
+// {path} standing for p relative to the working directory, using forward
+// slashes.
+func diffLabels(s, p string) (before, after string) {
+	before, after, _ = parseDiffLabels(s) // validated in parseArgs
+	path := filepath.ToSlash(displayPath(p))
+	return strings.ReplaceAll(before, "{path}", path), strings.ReplaceAll(after, "{path}", path)
+}
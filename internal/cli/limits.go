@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// checkLimits enforces --max-files and --max-total-replacements over the
+// planned changes, before anything is written. Zero disables a limit.
+func checkLimits(cfg Config, results []fileResult) error {
+	files, replacements := 0, 0
+	for _, r := range results {
+		if r.err == nil && r.skip == "" {
+			files++
+			replacements += r.res.Replacements
+		}
+	}
+	if cfg.MaxFiles > 0 && files > cfg.MaxFiles {
+		return fmt.Errorf("run would change %d files, more than --max-files %d", files, cfg.MaxFiles)
+	}
+	if cfg.MaxTotalReplacements > 0 && replacements > cfg.MaxTotalReplacements {
+		return fmt.Errorf("run would make %d replacements, more than --max-total-replacements %d", replacements, cfg.MaxTotalReplacements)
+	}
+	return nil
+}
+
+// sizeUnits are the --max-size suffixes: SI multiples of 1000 and binary
+// ones of 1024. A bare number counts bytes.
+var sizeUnits = map[string]int64{
+	"": 1, "B": 1,
+	"K": 1e3, "KB": 1e3, "M": 1e6, "MB": 1e6, "G": 1e9, "GB": 1e9,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30,
+}
+
+// parseSize parses a --max-size value such as "5MB", "512KiB" or "1.5G".
+func parseSize(s string) (int64, error) {
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(s[len(num):]))]
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("--max-size: want a positive size such as 5MB or 512KiB, got %q", s)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which no int64 holds.
+	b := n * float64(unit)
+	if b >= math.MaxInt64 {
+		return 0, fmt.Errorf("--max-size: %q is too large", s)
+	}
+	return int64(b), nil
+}
+
+// largeFile is a discovered file skipped by --max-size.
+type largeFile struct {
+	path string
+	size int64
+}
+
+// dropLarge removes the files larger than max bytes from paths before they
+// are read, returning them separately. Files that cannot be stat'ed are
+// kept, so reading them reports the error.
+func dropLarge(paths []string, max int64) (kept []string, large []largeFile) {
+	kept = paths[:0]
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.Size() > max {
+			large = append(large, largeFile{p, info.Size()})
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, large
+}
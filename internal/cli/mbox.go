@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"safereplace/internal/diff"
+	"safereplace/internal/processor"
+)
+
+// defaultMboxSubject is the --mbox-subject used when none is given.
+const defaultMboxSubject = "Replace {pattern} with {replace} in {path}"
+
+// mboxFrom is the author of generated patches; git am uses it unless the
+// applier overrides it.
+const mboxFrom = "safereplace <safereplace@localhost>"
+
+// mboxPatch is one email of a --format mbox series: the changed files of a
+// single path, or of a --group-by group.
+type mboxPatch struct {
+	name  string // path or group key, for the subject
+	files []mboxFile
+}
+
+type mboxFile struct {
+	path string
+	res  processor.Result
+}
+
+// addMboxFile appends path p to the series, starting a new patch unless
+// group is set and p belongs to the same group as the previous file.
+func addMboxFile(series []mboxPatch, p string, res processor.Result, group string) []mboxPatch {
+	name := filepath.ToSlash(displayPath(p))
+	if group != "" {
+		name = filepath.ToSlash(groupKey(p, group))
+		if n := len(series); n > 0 && series[n-1].name == name {
+			series[n-1].files = append(series[n-1].files, mboxFile{p, res})
+			return series
+		}
+	}
+	return append(series, mboxPatch{name: name, files: []mboxFile{{p, res}}})
+}
+
+// mboxSubject expands the placeholders of tmpl: {path} (the file, or the
+// group with --group-by), {files}, {matches}, {pattern} and {replace}.
+func mboxSubject(tmpl string, cfg Config, pt mboxPatch) string {
+	matches := 0
+	for _, f := range pt.files {
+		matches += f.res.Replacements
+	}
+	s := strings.NewReplacer(
+		"{path}", pt.name,
+		"{files}", strconv.Itoa(len(pt.files)),
+		"{matches}", strconv.Itoa(matches),
+		"{pattern}", cfg.Pattern,
+		"{replace}", cfg.Replace,
+	).Replace(tmpl)
+	// A header line cannot hold line breaks.
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// writeMbox writes series as an mbox of "git format-patch" style emails,
+// numbered [PATCH n/N], that git am applies in order. Diff paths are
+// relative to the working directory, which should be the repository root.
+func writeMbox(w io.Writer, series []mboxPatch, cfg Config, date time.Time) {
+	for i, pt := range series {
+		subject := mboxSubject(cfg.MboxSubject, cfg, pt)
+		if len(series) > 1 {
+			subject = fmt.Sprintf("[PATCH %d/%d] %s", i+1, len(series), subject)
+		} else {
+			subject = "[PATCH] " + subject
+		}
+		fmt.Fprintf(w, "From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n")
+		fmt.Fprintf(w, "From: %s\nDate: %s\nSubject: %s\n\n", mboxFrom, date.Format(time.RFC1123Z), subject)
+		fmt.Fprintf(w, "Generated by safereplace.\n---\n")
+		for _, f := range pt.files {
+			name := filepath.ToSlash(displayPath(f.path))
+			fmt.Fprintf(w, "diff --git a/%s b/%s\n", name, name)
+			fmt.Fprint(w, diff.Unified("a/"+name, "b/"+name, f.res.Before, f.res.After, 3))
+		}
+		fmt.Fprintf(w, "-- \nsafereplace\n\n")
+	}
+}
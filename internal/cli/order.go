@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"safereplace/internal/collate"
+	"safereplace/internal/processor"
+)
+
+// Values accepted by --sort and --group-by.
+const (
+	sortPath    = "path"
+	sortMatches = "matches"
+	sortSize    = "size"
+
+	groupDir = "dir"
+	groupExt = "ext"
+)
+
+// Values accepted by --sample-by.
+const (
+	sampleFirst       = "first"
+	sampleRandom      = "random"
+	sampleMostChanged = "most-changed"
+)
+
+// fileResult is a processed file waiting to be reported or applied. Files
+// that failed or need no change only carry err or skip (the event reason)
+// besides their row, so they are still reported in order.
+type fileResult struct {
+	res     processor.Result
+	preview string
+	// plain is the uncolored preview written by --diff-dir.
+	plain string
+	row   fileSummary
+	err   error
+	skip  string
+	// elapsed is the time taken to process the file, for --estimate.
+	elapsed time.Duration
+	// changed is set when the content differs, even if nothing is shown.
+	changed bool
+	// notes holds warnings about the file, printed in path order.
+	notes string
+	// passes holds the replacements of each --until-stable pass.
+	passes []int
+	// order is the file's config rank; lower ranks are output and applied first.
+	order int
+}
+
+// groupKey returns the --group-by bucket of path p: its directory or its
+// extension without the dot ("(none)" when it has none).
+func groupKey(p, by string) string {
+	switch by {
+	case groupDir:
+		return filepath.Dir(displayPath(p))
+	case groupExt:
+		if ext := strings.TrimPrefix(filepath.Ext(p), "."); ext != "" {
+			return ext
+		}
+		return "(none)"
+	}
+	return ""
+}
+
+// sortResults orders results by config order rank, then by group, then by the
+// --sort key. Matches and size sort largest first so the most impactful files
+// come first; ties keep path order. Groups and paths are compared with coll.
+func sortResults(results []fileResult, by, group string, coll collate.Collator) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.order != b.order {
+			return a.order < b.order
+		}
+		if ga, gb := groupKey(a.row.Path, group), groupKey(b.row.Path, group); ga != gb {
+			return coll.Less(ga, gb)
+		}
+		switch by {
+		case sortMatches:
+			if a.row.Matches != b.row.Matches {
+				return a.row.Matches > b.row.Matches
+			}
+		case sortSize:
+			if len(a.res.Before) != len(b.res.Before) {
+				return len(a.res.Before) > len(b.res.Before)
+			}
+		}
+		return coll.Less(a.row.Path, b.row.Path)
+	})
+}
+
+// sampleResults picks up to n changed results whose previews are shown with
+// --sample, returning their indices: the first n in output order, a random
+// selection, or those with the most replacements.
+func sampleResults(results []fileResult, n int, by string) map[int]bool {
+	var changed []int
+	for i, r := range results {
+		if r.err == nil && r.skip == "" {
+			changed = append(changed, i)
+		}
+	}
+	switch by {
+	case sampleRandom:
+		rand.Shuffle(len(changed), func(i, j int) { changed[i], changed[j] = changed[j], changed[i] })
+	case sampleMostChanged:
+		sort.SliceStable(changed, func(i, j int) bool {
+			return results[changed[i]].row.Replacements > results[changed[j]].row.Replacements
+		})
+	}
+	shown := make(map[int]bool, n)
+	for _, i := range changed[:min(n, len(changed))] {
+		shown[i] = true
+	}
+	return shown
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"safereplace/internal/config"
+	"safereplace/internal/diff"
+	"safereplace/internal/processor"
+)
+
+// loadConfig reads the file given with --config, or config.DefaultFile from
+// the working directory when it exists.
+func loadConfig(path string) (config.File, error) {
+	if path != "" {
+		return config.Load(path)
+	}
+	if _, err := os.Stat(config.DefaultFile); errors.Is(err, fs.ErrNotExist) {
+		return config.File{}, nil
+	}
+	return config.Load(config.DefaultFile)
+}
+
+// fileSettings is the per-file view of Config after config overrides.
+type fileSettings struct {
+	replace string
+	// rules are the replacements of --rules, with the same EOL handling as replace.
+	rules []processor.Replacement
+	// binary is the --binary handling of the file.
+	binary string
+	proc   processor.Options
+	diff   diff.Options
+}
+
+// withEOL rewrites the newlines of s in the line ending eol ("lf" or "crlf").
+func withEOL(s, eol string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if eol == "crlf" {
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	return s
+}
+
+// settingsFor applies the overrides in o to the command-line settings. base
+// holds the diff options derived from cfg.
+func settingsFor(cfg Config, base diff.Options, o config.Overrides) fileSettings {
+	s := fileSettings{
+		replace: cfg.Replace,
+		binary:  cfg.Binary,
+		proc: processor.Options{
+			IgnoreCase: cfg.IgnoreCase,
+			Word:       cfg.Word,
+			LineStart:  cfg.StartsWith,
+			LineEnd:    cfg.EndsWith,
+		},
+		diff: base,
+	}
+	if o.EOL != "" && !cfg.Hex {
+		s.replace = withEOL(s.replace, o.EOL)
+	}
+	for i, r := range cfg.RuleSet {
+		repl := r.Replace
+		if o.EOL != "" {
+			repl = withEOL(repl, o.EOL)
+		}
+		rep := processor.Replacement{Pattern: []byte(r.Pattern), Replace: []byte(repl), NoExpand: cfg.NoExpand}
+		if i < len(cfg.RuleRegexps) {
+			rep.Regex = cfg.RuleRegexps[i]
+		}
+		s.rules = append(s.rules, rep)
+	}
+	if o.Binary != nil {
+		s.binary = *o.Binary
+		if s.binary == binaryForce {
+			s.binary = binaryProcess
+		}
+	}
+	s.proc.AllowBinary = s.binary == binaryProcess
+	if o.Context != nil {
+		s.diff.Context = *o.Context
+	}
+	if o.StrictEOL != nil {
+		s.diff.StrictEOL = *o.StrictEOL
+	}
+	if o.Wrap != nil {
+		s.diff.Wrap, _ = wrapWidth(*o.Wrap) // validated by config.Parse
+	}
+	if o.MaxLineLength != nil {
+		s.diff.MaxLineLength = *o.MaxLineLength
+	}
+	return s
+}
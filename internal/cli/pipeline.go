@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// schedule holds the resolved --jobs, --read-ahead and --batch-size.
+type schedule struct {
+	jobs      int // files processed concurrently
+	readAhead int // files queued ahead of the workers
+	batch     int // files handed to a worker at a time
+}
+
+// resolveSchedule fills in the automatic (0) values for a run over n files:
+// one job per usable CPU (GOMAXPROCS, which follows CPU affinity and cgroup
+// quotas), read-ahead of two files per job, and batches that grow with n so
+// large runs on small files spend little time handing out work.
+func resolveSchedule(cfg Config, n int) schedule {
+	s := schedule{jobs: cfg.Jobs, readAhead: cfg.ReadAhead, batch: cfg.BatchSize}
+	if s.jobs == 0 {
+		s.jobs = runtime.GOMAXPROCS(0)
+	}
+	if s.readAhead == 0 {
+		s.readAhead = 2 * s.jobs
+	}
+	if s.batch == 0 {
+		s.batch = min(max(n/(s.jobs*16), 1), 64)
+	}
+	return s
+}
+
+// processAll runs process on every path using s.jobs workers and returns the
+// results in path order. Paths not started before ctx is done, or for which
+// process reports false, are returned as pending instead.
+func processAll(ctx context.Context, paths []string, s schedule, process func(p string) (fileResult, bool)) (results []fileResult, pending []string) {
+	out := make([]fileResult, len(paths))
+	done := make([]bool, len(paths))
+	batches := make(chan []int, max(s.readAhead/s.batch, 1))
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(paths) && ctx.Err() == nil; start += s.batch {
+			batch := make([]int, 0, s.batch)
+			for i := start; i < min(start+s.batch, len(paths)); i++ {
+				batch = append(batch, i)
+			}
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for range s.jobs {
+		wg.Go(func() {
+			for batch := range batches {
+				for _, i := range batch {
+					if ctx.Err() != nil {
+						break
+					}
+					out[i], done[i] = process(paths[i])
+				}
+			}
+		})
+	}
+	wg.Wait()
+	for i, p := range paths {
+		if done[i] {
+			results = append(results, out[i])
+		} else {
+			pending = append(pending, p)
+		}
+	}
+	return results, pending
+}
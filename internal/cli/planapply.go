@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"safereplace/internal/journal"
+	"safereplace/internal/plan"
+	"safereplace/internal/processor"
+)
+
+// runPlanApply writes the changes saved in a plan, such as the files deferred
+// by --canary:
+//
+//	safereplace plan apply PLAN.json [FLAGS...]
+//
+// It is a run with --from-plan PLAN.json --dry-run=false followed by FLAGS,
+// so backups, the journal, the organization policy and --post-check apply as
+// to any other run, and --dry-run previews the plan instead. Paths are
+// relative to the working directory of the run that saved the plan.
+func runPlanApply(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: safereplace plan apply PLAN.json [FLAGS...]")
+		return 2
+	}
+	p, err := plan.Load(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	if len(p.Files) == 0 {
+		fmt.Fprintf(stderr, "plan: %s: no files to apply\n", args[0])
+		return 0
+	}
+	return RunWithStdin(append([]string{"--from-plan", args[0], "--dry-run=false"}, args[1:]...), stdin, stdout, stderr)
+}
+
+// plannedResult rebuilds the change planned for p from its recorded edits,
+// checking that p still holds the content the plan was made against.
+func plannedResult(p string, files map[string]plan.File) (processor.Result, error) {
+	f, ok := files[filepath.ToSlash(displayPath(p))]
+	if !ok {
+		return processor.Result{}, errors.New("not in the plan")
+	}
+	before, err := processor.ReadFile(p)
+	if err != nil {
+		return processor.Result{}, err
+	}
+	if journal.Hash(before) != f.BeforeSHA256 {
+		return processor.Result{}, errors.New("file changed since the plan was made")
+	}
+	offs, err := f.Offsets(before)
+	if err != nil {
+		return processor.Result{}, err
+	}
+	res := processor.Result{Before: before, Matches: f.Matches, Replacements: f.Replacements, Binary: processor.IsBinary(before)}
+	off := 0
+	for i, e := range f.Edits {
+		res.After = append(append(res.After, before[off:offs[i]]...), e.New...)
+		res.Edits = append(res.Edits, processor.Edit{Start: offs[i], End: offs[i] + len(e.Old), Text: []byte(e.New)})
+		off = offs[i] + len(e.Old)
+	}
+	res.After = append(res.After, before[off:]...)
+	if journal.Hash(res.After) != f.AfterSHA256 {
+		return processor.Result{}, errors.New("recorded edits do not reproduce the planned content")
+	}
+	res.Changed = len(f.Edits) > 0
+	return res, nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"safereplace/internal/plan"
+)
+
+// runPlan runs the plan subcommands: diff (runPlanDiff) and apply
+// (runPlanApply).
+func runPlan(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "apply" {
+		return runPlanApply(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) != 3 || args[0] != "diff" {
+		fmt.Fprintln(stderr, "usage: safereplace plan diff OLD.json NEW.json | plan apply PLAN.json [FLAGS...]")
+		return 2
+	}
+	return runPlanDiff(args[1:], stdout, stderr)
+}
+
+// runPlanDiff compares two plans saved with --plan-out (or --canary):
+//
+//	safereplace plan diff OLD.json NEW.json
+//
+// Each file that differs is printed as "+ path" (only in NEW), "- path"
+// (only in OLD) or "~ path" followed by the edits only one plan has, then a
+// count of each. It exits 0 when the plans match, 1 when they differ, and 2
+// on errors.
+func runPlanDiff(args []string, stdout, stderr io.Writer) int {
+	from, err := plan.Load(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	to, err := plan.Load(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	diffs := plan.Diff(from, to)
+	counts := map[string]int{}
+	for _, d := range diffs {
+		counts[d.Kind]++
+		switch d.Kind {
+		case plan.Added:
+			fmt.Fprintf(stdout, "+ %s (%d replacements)\n", d.Path, d.New.Replacements)
+		case plan.Removed:
+			fmt.Fprintf(stdout, "- %s (%d replacements)\n", d.Path, d.Old.Replacements)
+		case plan.Changed:
+			fmt.Fprintf(stdout, "~ %s (%d -> %d replacements)\n", d.Path, d.Old.Replacements, d.New.Replacements)
+			for _, e := range d.OnlyOld {
+				fmt.Fprintf(stdout, "    - %d:%d %q -> %q\n", e.Line, e.Col, e.Old, e.New)
+			}
+			for _, e := range d.OnlyNew {
+				fmt.Fprintf(stdout, "    + %d:%d %q -> %q\n", e.Line, e.Col, e.Old, e.New)
+			}
+			if len(d.OnlyOld) == 0 && len(d.OnlyNew) == 0 {
+				fmt.Fprintln(stdout, "    same edits; file content differs")
+			}
+		}
+	}
+	fmt.Fprintf(stdout, "files: %d added, %d removed, %d changed\n", counts[plan.Added], counts[plan.Removed], counts[plan.Changed])
+	if len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
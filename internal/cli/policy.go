@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"safereplace/internal/policy"
+)
+
+// enforcePolicy checks cfg against the organization policy in effect (see
+// package policy) and adds its excludes. Violations are errors naming the
+// policy file, since flags cannot override it.
+func enforcePolicy(cfg *Config) error {
+	pol, err := policy.Find()
+	if err != nil {
+		return err
+	}
+	if pol.Path == "" {
+		return nil
+	}
+	violation := func(err error) error {
+		return fmt.Errorf("policy %s: %w", pol.Path, err)
+	}
+	if !cfg.DryRun {
+		if pol.CIDryRunOnly && policy.InCI() {
+			return violation(errors.New("changes cannot be applied in CI; run a dry run to check for them"))
+		}
+		if pol.RequireBackup && countTrue(cfg.Backup, cfg.BackupArchive != "", cfg.BackupToTrash, cfg.BackupDiff) == 0 {
+			return violation(errors.New("applying requires a backup: add --backup, --backup-archive, --backup-to-trash or --backup-diff"))
+		}
+	}
+	if cfg.Pattern != "" {
+		if err := pol.CheckPattern(cfg.Pattern); err != nil {
+			return violation(err)
+		}
+	}
+	for i, r := range cfg.RuleSet {
+		if err := pol.CheckPattern(r.Pattern); err != nil {
+			return violation(fmt.Errorf("rule %d: %w", i+1, err))
+		}
+	}
+	cfg.Exclude = append(cfg.Exclude, pol.Exclude...)
+	return nil
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"safereplace/internal/apply"
+	"safereplace/internal/journal"
+)
+
+// appliedFile is a file written by the run, kept so a failed --post-check
+// can restore it.
+type appliedFile struct {
+	path     string
+	before   []byte
+	afterSum string
+	row      int // index of the file's summary row
+}
+
+// postCheck runs the --post-check command once, with its output going to
+// stderr, and reports whether it succeeded.
+func postCheck(ctx context.Context, command string, stderr io.Writer) error {
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = stderr
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("--post-check %q: %w", command, err)
+	}
+	return nil
+}
+
+// rollback restores files to their content before the run, newest first,
+// recording each restore with record. A file whose content is no longer what
+// the run wrote (say, the check rewrote it) is left alone and reported.
+// It returns the indices of the rows of restored files and whether any file
+// could not be restored.
+func rollback(stderr io.Writer, files []appliedFile, opts apply.Options, record func(journal.Entry)) (restored []int, failed bool) {
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		cur, err := os.ReadFile(f.path)
+		if err == nil && journal.Hash(cur) != f.afterSum {
+			err = errors.New("changed since it was applied")
+		}
+		if err == nil {
+			err = apply.WriteAtomic(f.path, f.before, opts)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: rollback %s: %v\n", f.path, err)
+			record(journal.Entry{Action: journal.ActionError, Path: f.path, Error: "rollback: " + err.Error()})
+			failed = true
+			continue
+		}
+		fmt.Fprintf(stderr, "rolled back: %s\n", f.path)
+		record(journal.Entry{Action: journal.ActionRolledBack, Path: f.path, BeforeSHA256: f.afterSum, AfterSHA256: journal.Hash(f.before)})
+		restored = append(restored, f.row)
+	}
+	return restored, failed
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"safereplace/internal/apply"
+	"safereplace/internal/trash"
+)
+
+// diskNeed is the space a run needs on one filesystem.
+type diskNeed struct {
+	dir    string // first directory seen on it, for messages
+	free   uint64
+	growth uint64 // files getting larger
+	temp   uint64 // the largest temp file, alive until its rename
+	backup uint64
+}
+
+// preflightSpace checks, before anything is written, that each filesystem
+// the run writes to has room for what lands on it: grown files next to their
+// targets, temp files in --temp-dir or next to the targets, backup copies
+// (uncompressed, and counted for --backup-reflink too as clones may fall back
+// to copies, so the estimate errs high) in the trash, the archive or next to
+// the targets. Filesystems whose free space cannot be queried are not
+// checked.
+func preflightSpace(cfg Config, results []fileResult) error {
+	needs := map[uint64]*diskNeed{}
+	disks := map[string]*diskNeed{}
+	needFor := func(dir string) *diskNeed {
+		if n, ok := disks[dir]; ok {
+			return n
+		}
+		var n *diskNeed
+		if d, err := apply.DiskSpace(existingDir(dir)); err == nil {
+			if n = needs[d.Device]; n == nil {
+				n = &diskNeed{dir: dir, free: d.Free}
+				needs[d.Device] = n
+			}
+		}
+		disks[dir] = n
+		return n
+	}
+	// Backups not kept next to their targets all land in one place.
+	var backups *diskNeed
+	switch {
+	case cfg.BackupArchive != "":
+		backups = needFor(filepath.Dir(cfg.BackupArchive))
+	case cfg.BackupToTrash:
+		if dir, err := trash.Dir(); err == nil {
+			backups = needFor(dir)
+		}
+	}
+	tempIn := map[string]string{} // target dir -> temp dir
+	for _, r := range results {
+		if r.err != nil || r.skip != "" {
+			continue
+		}
+		before, after := uint64(len(r.res.Before)), uint64(len(r.res.After))
+		dir := filepath.Dir(r.row.Path)
+		if n := needFor(dir); n != nil && after > before {
+			n.growth += after - before
+		}
+		tmp, ok := tempIn[dir]
+		if !ok {
+			// apply stages temp files in --temp-dir only when it can rename
+			// them from there.
+			tmp = dir
+			if cfg.TempDir != "" && apply.SameFilesystem(cfg.TempDir, dir) {
+				tmp = cfg.TempDir
+			}
+			tempIn[dir] = tmp
+		}
+		if n := needFor(tmp); n != nil {
+			n.temp = max(n.temp, after)
+		}
+		switch {
+		case cfg.BackupArchive != "" || cfg.BackupToTrash:
+			if backups != nil {
+				backups.backup += before
+			}
+		case cfg.Backup || cfg.BackupDiff && !(utf8.Valid(r.res.Before) && utf8.Valid(r.res.After)):
+			if n := needFor(dir); n != nil {
+				n.backup += before
+			}
+		}
+	}
+	for _, n := range needs {
+		if need := n.growth + n.temp + n.backup; need > n.free {
+			return fmt.Errorf("not enough disk space on the filesystem of %s: need %s (%s of backups), %s free",
+				n.dir, formatBytes(int64(need)), formatBytes(int64(n.backup)), formatBytes(int64(n.free)))
+		}
+	}
+	return nil
+}
+
+// existingDir returns dir, or its nearest ancestor that exists when dir is
+// still to be created.
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"safereplace/internal/preset"
+)
+
+// runPreset lists the built-in presets with their parameters and defaults:
+//
+//	safereplace preset list
+//
+// A preset is run with --preset NAME [--param name=value ...].
+func runPreset(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 || args[0] != "list" {
+		fmt.Fprintln(stderr, "usage: safereplace preset list")
+		return 2
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, p := range preset.List(time.Now()) {
+		var params []string
+		for _, d := range p.Params {
+			params = append(params, d.Name+"="+d.Default)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, strings.Join(params, " "), p.Help)
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	return 0
+}
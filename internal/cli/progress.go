@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// progress tracks how far a run has got, for reporting on progressSignals
+// (SIGUSR1, plus SIGINFO / Ctrl-T on BSD and macOS).
+type progress struct {
+	mu      sync.Mutex
+	total   int
+	scanned int
+	changed int
+	current string
+}
+
+func (p *progress) start(path string) {
+	p.mu.Lock()
+	p.current = path
+	p.mu.Unlock()
+}
+
+// scannedFile records that the current file has been processed.
+func (p *progress) scannedFile(changed bool) {
+	p.mu.Lock()
+	p.scanned++
+	if changed {
+		p.changed++
+	}
+	p.mu.Unlock()
+}
+
+func (p *progress) report(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(w, "progress: scanned %d/%d files, changed %d, current: %s\n", p.scanned, p.total, p.changed, p.current)
+}
+
+// watchProgress reports p to w whenever a progress signal arrives, without
+// interrupting the run. The returned function stops watching.
+func watchProgress(p *progress, w io.Writer) (stop func()) {
+	if len(progressSignals) == 0 {
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, progressSignals...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				p.report(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// progressSignals also include SIGINFO, which the terminal sends on Ctrl-T.
+var progressSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGINFO}
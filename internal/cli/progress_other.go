@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// progressSignals is empty where SIGUSR1 does not exist; progress is not reported.
+var progressSignals []os.Signal
@@ -0,0 +1,10 @@
+//go:build unix && !darwin && !freebsd
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+var progressSignals = []os.Signal{syscall.SIGUSR1}
@@ -1,17 +1,55 @@
 package cli
 
 import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"go/token"
 	"io"
-	"sort"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/pflag"
 
 	"safereplace/internal/apply"
+	"safereplace/internal/classify"
+	"safereplace/internal/collate"
 	"safereplace/internal/diff"
 	"safereplace/internal/discovery"
+	"safereplace/internal/fdlimit"
+	"safereplace/internal/gitdiff"
+	"safereplace/internal/history"
+	"safereplace/internal/journal"
+	"safereplace/internal/patch"
+	"safereplace/internal/plan"
+	"safereplace/internal/preset"
 	"safereplace/internal/processor"
+	"safereplace/internal/retry"
+	"safereplace/internal/rules"
+)
+
+// Values accepted by --mode.
+const (
+	modeLiteral = "literal"
+	modeGoIdent = "go-ident"
+	modeEnvKey  = "env-key"
+)
+
+// Values accepted by --binary. "force" is the older name of "process".
+const (
+	binarySkip    = "skip"
+	binaryError   = "error"
+	binaryProcess = "process"
+	binaryForce   = "force"
 )
 
 type Config struct {
@@ -19,8 +57,8 @@ type Config struct {
 	Replace     string
 	Regex       bool
 	Literal     bool
-	Glob        string
-	Ext         string
+	Glob        []string
+	Ext         []string
 	Files       []string
 	Yes         bool
 	Interactive bool
@@ -29,109 +67,1524 @@ type Config struct {
 	NoColor     bool
 	Context     int
 	StrictEOL   bool
+	// Hyperlinks renders file headers as OSC 8 links: "auto" (when the
+	// terminal is known to support them), "always" or "never". They link
+	// to the file, or to LinkTemplate with {path}, {abspath} and {line}
+	// filled in.
+	Hyperlinks   string
+	LinkTemplate string
+	// DiffLabels names the sides in diff headers instead of "before" and
+	// "after": "git" (a/PATH and b/PATH) or "OLD,NEW" with {path}. Text
+	// previews are then unified diffs that patch and git apply accept.
+	DiffLabels string
+	// NoExpand inserts a --regex replacement as is instead of expanding $1
+	// and ${name} to the text of capture groups.
+	NoExpand bool
+	// LocalTime writes journal, event and mbox timestamps in local time
+	// instead of UTC.
+	LocalTime bool
+	// IgnoreCase matches --pattern (or rules) case-insensitively, in literal
+	// and regex mode.
+	IgnoreCase bool
+	// Word matches literal patterns only as whole words.
+	Word bool
+	// StartsWith and EndsWith match literal patterns only at the start or
+	// end of a line.
+	StartsWith bool
+	EndsWith   bool
+	// Multiline makes ^ and $ match at line breaks and DotAll lets . match
+	// a newline in a --regex pattern.
+	Multiline bool
+	DotAll    bool
+	// Since limits the selected files to those changed since this git ref.
+	Since string
+	// NewerThan and OlderThan limit the selected files to those last
+	// modified after or before a time: an RFC 3339 timestamp, or a duration
+	// before the start of the run such as "36h" or "7d".
+	NewerThan string
+	OlderThan string
+	// Shard ("K/N") keeps only the K-th of N disjoint parts of the selected
+	// files, to split a run across CI jobs.
+	Shard string
+	// Mode selects the matcher: "literal", "go-ident" (rename Go identifiers
+	// in .go files, literal replacement elsewhere) or "env-key" (replace the
+	// value of Key in .env/.properties/.ini files).
+	Mode string
+	Key  string
+	// Scope restricts replacements within Markdown files to fenced code
+	// ("markdown-code") or everything else ("markdown-prose"), or to the
+	// front matter ("frontmatter") or what follows it ("body").
+	Scope string
+	// SkipStrings leaves string literals alone in source files of known
+	// languages (see processor.OutsideStrings).
+	SkipStrings bool
+	// XPath restricts replacements to the attribute values or text nodes an
+	// XPath subset expression selects in XML/HTML files.
+	XPath string
+	// Rules names a rule file (see package rules) whose replacements are made
+	// together in one pass, instead of --pattern and --replace. RuleSet holds
+	// its rules once parsed. A URL pinned with @sha256: is fetched once and
+	// cached; Offline only uses the cache.
+	Rules   string
+	RuleSet []rules.Rule
+	// RuleRegexps holds the compiled pattern of each regex rule of RuleSet,
+	// with the flags of --ignore-case, --multiline and --dotall; nil for
+	// literal rules.
+	RuleRegexps []*regexp.Regexp
+	Offline     bool
+	// Exclude lists globs of files never processed, from the organization
+	// policy (see enforcePolicy).
+	Exclude []string
+	// Preset runs a built-in recipe of package preset by name instead of
+	// --pattern and --replace, with PresetParams ("name=value") overriding
+	// its defaults.
+	Preset       string
+	PresetParams []string
+	// FixTrailingWhitespace trims spaces and tabs at line ends and
+	// EnsureFinalNewline ends every non-empty file with a newline, instead of
+	// replacing a pattern.
+	FixTrailingWhitespace bool
+	EnsureFinalNewline    bool
+	// Retab converts leading indentation: "spaces=N" expands tabs to N
+	// columns, "tabs" or "tabs=N" turns each N columns into a tab (N is 4 by
+	// default).
+	Retab string
+	// Normalize lists the line operations applied instead of replacing a
+	// pattern: "sort-lines" and "unique-lines".
+	Normalize []string
+	// TransformCmd is an external program run once per file instead of the
+	// built-in matchers, speaking the JSON protocol of processor.SubstituteCommand.
+	// --pattern and --replace are optional and passed through to it.
+	TransformCmd string
+	// TransformWasm is a WASI module speaking the same protocol, run
+	// in-process with no filesystem or network access.
+	TransformWasm string
+	// TemplateGuard reports ("warn") or leaves alone ("skip") matches inside
+	// template expressions delimited by TemplateDelims ("OPEN...CLOSE" pairs,
+	// defaults {{ }}, ${ } and <% %>).
+	TemplateGuard  string
+	TemplateDelims []string
+	// SecretCheck warns when the replacement looks like a credential written
+	// into several files, or the pattern looks like one being rotated.
+	SecretCheck bool
+	// Config names a file of per-file option overrides; see package config.
+	// Defaults to .safereplace.conf in the working directory when present.
+	Config string
+	// PatternStdin, PatternFile and ReplaceFile supply --pattern/--replace
+	// verbatim, avoiding shell quoting. One trailing newline is dropped.
+	PatternStdin bool
+	PatternFile  string
+	ReplaceFile  string
+	// Roots are the directories --glob and --ext search, from positional
+	// arguments (default: the working directory).
+	Roots []string
+	// FollowSymlinks lets discovery follow symlinks to files and directories.
+	FollowSymlinks bool
+	// FilesFrom names a file listing paths to add to Files, one per line or
+	// NUL-separated with Null; "-" reads stdin.
+	FilesFrom string
+	Null      bool
+	// Wrap soft-wraps diff lines at N columns, or at the terminal width for "auto".
+	Wrap string
+	// MaxLineLength truncates long changed lines to an excerpt around the change.
+	MaxLineLength int
+	// Hex takes --pattern and --replace as hex byte strings (e.g. 0xDEADBEEF).
+	Hex bool
+	// Binary selects how files containing NUL bytes are handled: "skip",
+	// "error" or "process".
+	Binary string
+	// Sort orders per-file output and summary rows: "path", "matches" or "size".
+	Sort string
+	// SortLocale orders paths with the collation of a locale ("auto" for the
+	// environment's) instead of byte-wise; see package collate.
+	SortLocale string
+	// GroupBy groups per-file output and summary rows by "dir" or "ext".
+	GroupBy string
+	// Format selects per-file output: "diff" previews, "quickfix" lines
+	// (file:line:col: message) for editors, "github" workflow commands, or
+	// "mbox", a patch series for mailing-list review.
+	Format string
+	// MboxSubject is the subject template of --format mbox patches.
+	MboxSubject string
+	// ReportCodeQuality writes the replacements as a GitLab Code Quality report.
+	ReportCodeQuality string
+	// ListChanged prints only the paths of changed files, one per line.
+	ListChanged bool
+	// Print0 terminates listed paths with NUL instead of newline; it implies ListChanged.
+	Print0 bool
+	// Sample limits dry-run previews to N files picked by SampleBy
+	// ("first", "random" or "most-changed"); totals still cover every file.
+	Sample   int
+	SampleBy string
+	// DiffDir receives each file's preview as <dir>/<relpath>.diff instead of stdout.
+	DiffDir string
+	// Estimate prints the projected apply time and disk churn of a dry run.
+	Estimate bool
+	// SummaryTable replaces per-file output with an aligned table and totals.
+	SummaryTable bool
+	// Events selects a machine-readable event stream format ("ndjson").
+	Events     string
+	EventsFile string
+	// Journal is a directory receiving a per-run audit trail (<run id>.jsonl).
+	Journal string
+	// JournalChain writes the journal hash-chained and read-only, keyed with
+	// the key in JournalKey (journal.KeyPath() by default).
+	JournalChain bool
+	JournalKey   string
+	// BackupRunID embeds the run ID in backup file names.
+	BackupRunID bool
+	// MaxFiles and MaxTotalReplacements cap how many files a run may change
+	// and how many replacements it may make; a run over either is refused
+	// before anything is written. Zero means no limit.
+	MaxFiles             int
+	MaxTotalReplacements int
+	// MaxSize skips discovered files larger than this size (e.g. "5MB")
+	// without reading them.
+	MaxSize string
+	// Canary applies only a deterministic sample of this percentage of the
+	// changed files; the rest are deferred to the plan saved at PlanOut
+	// (safereplace-plan-<run id>.json by default).
+	Canary string
+	// PlanOut saves the planned changes as JSON; see package plan.
+	PlanOut string
+	// FromPlan applies the changes saved in this plan to the files it lists
+	// instead of matching a pattern; PlanFiles holds them by slash-separated
+	// path (see plan apply).
+	FromPlan  string
+	PlanFiles map[string]plan.File
+	// AssertIdempotent runs the replacement again over each new content in
+	// memory and fails files it would change again, such as foo -> foofoo.
+	AssertIdempotent bool
+	// UntilStable reruns the replacement over each new content in memory
+	// until a pass changes nothing, for rules whose output another rule
+	// matches; files still changing after MaxPasses passes fail.
+	UntilStable bool
+	MaxPasses   int
+	// ValidateCmd is a shell command run against each file's proposed new
+	// content, {} standing for a temporary copy; a failure rejects the file.
+	ValidateCmd string
+	// PostCheck is a shell command run once after every file was applied;
+	// when it fails, the applied files are restored.
+	PostCheck string
+	// Stamp writes a provenance comment naming the run into every changed
+	// file, refreshing the one a previous run wrote.
+	Stamp bool
+	// BackupCompress compresses backups ("gzip" or "zstd").
+	BackupCompress string
+	// System guards changes to system files (see system.go): package-managed
+	// files are refused, applying them needs confirmation, backups are always
+	// made and applied files are logged to syslog.
+	System bool
+	// YesSystem confirms the --system file list without a prompt; Yes does
+	// not.
+	YesSystem bool
+	// BackupReflink makes --backup copies copy-on-write clones; it implies
+	// Backup.
+	BackupReflink bool
+	// BackupArchive stores all originals of the run in one .tar.gz file.
+	BackupArchive string
+	// BackupToTrash copies originals into the OS trash / recycle bin.
+	BackupToTrash bool
+	// BackupDiff records reverse patches in the journal instead of backup copies.
+	BackupDiff bool
+	// CPUProfile, MemProfile and Trace write pprof CPU/heap profiles and an
+	// execution trace of the run to the given files.
+	CPUProfile string
+	MemProfile string
+	Trace      string
+	// Timeout stops the run once it has taken this long; 0 means no limit.
+	// Files not reached by then are reported as pending.
+	Timeout time.Duration
+	// Retries and RetryBackoff control retrying reads and writes that fail with
+	// transient errors (EAGAIN, EBUSY, Windows sharing violations).
+	Retries      int
+	RetryBackoff time.Duration
+	// Jobs, ReadAhead and BatchSize tune how files are processed concurrently
+	// before output and apply; 0 picks them automatically (see schedule).
+	Jobs      int
+	ReadAhead int
+	BatchSize int
+	// MaxOpenFiles bounds the file descriptors held for files at once; 0 derives
+	// it from the process's open file limit. Files wait for a free slot.
+	MaxOpenFiles int
+	// TempDir holds temp files while applying; targets on another filesystem
+	// get theirs next to them instead, with a warning, so renames stay atomic.
+	TempDir string
+	// TempPrefix and TempSuffix frame temp file names (NAME.tmp-DIGITS by
+	// default), e.g. to match ignore rules of watchers and editors.
+	TempPrefix string
+	TempSuffix string
+	// ModePolicy sets the permissions of rewritten files: "preserve" the
+	// original's, follow the "umask", or an explicit octal mode like "0644".
+	ModePolicy string
+	// ForcePerm temporarily lifts read-only/immutable protection to apply changes.
+	ForcePerm bool
+	// Merge three-way merges the edits of a file that changed since its
+	// preview onto its current content, instead of refusing to write it.
+	Merge bool
 }
 
-func parseArgs(args []string) (Config, error) {
+func parseArgs(args []string, stdin io.Reader) (Config, error) {
 	var cfg Config
-	fs := pflag.NewFlagSet("safereplace", pflag.ContinueOnError)
-
-	fs.StringVar(&cfg.Pattern, "pattern", "", "Search pattern (required)")
-	fs.StringVar(&cfg.Replace, "replace", "", "Replacement text (required)")
-	fs.BoolVar(&cfg.Regex, "regex", false, "Use regex mode (default: literal)")
-	fs.BoolVar(&cfg.Literal, "literal", false, "Force literal mode (default)")
-	fs.StringVar(&cfg.Glob, "glob", "", "File glob to match (e.g. \"*.go\")")
-	fs.StringVar(&cfg.Ext, "ext", "", "File extension filter without dot (e.g. \"txt\")")
-	fs.StringSliceVar(&cfg.Files, "files", nil, "Explicit list of files")
-	fs.BoolVar(&cfg.Yes, "yes", false, "Apply all changes without prompt")
-	fs.BoolVar(&cfg.Interactive, "interactive", false, "Confirm per file before applying")
-	fs.BoolVar(&cfg.Backup, "backup", false, "Create backups before modifying files")
-	fs.BoolVar(&cfg.DryRun, "dry-run", true, "Preview changes only (default)")
-	fs.BoolVar(&cfg.NoColor, "no-color", false, "Disable ANSI colors in output")
-	fs.IntVar(&cfg.Context, "context", 0, "Number of context lines in diff (reserved)")
-	fs.BoolVar(&cfg.StrictEOL, "strict-eol", false, "Treat a single trailing final newline difference as a change")
+	fs := newFlagSet(&cfg)
 
 	if err := fs.Parse(args); err != nil {
 		return cfg, err
 	}
 
+	if err := readPatternSources(&cfg, fs, stdin); err != nil {
+		return cfg, err
+	}
+	if err := readFilesFrom(&cfg, stdin); err != nil {
+		return cfg, err
+	}
+	cfg.Roots = fs.Args()
+	for _, root := range cfg.Roots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return cfg, fmt.Errorf("%s: not a directory (positional arguments are roots to search)", root)
+		}
+	}
+
 	// Validate minimal MVP constraints
-	if cfg.Pattern == "" || cfg.Replace == "" {
+	if len(cfg.PresetParams) > 0 && cfg.Preset == "" {
+		return cfg, errors.New("--param requires --preset")
+	}
+	if _, _, err := parseRetab(cfg.Retab); err != nil {
+		return cfg, err
+	}
+	for _, op := range cfg.Normalize {
+		if op != normalizeSort && op != normalizeUnique {
+			return cfg, fmt.Errorf("--normalize: want %s or %s, got %q", normalizeSort, normalizeUnique, op)
+		}
+	}
+	fixing := cfg.FixTrailingWhitespace || cfg.EnsureFinalNewline || cfg.Retab != "" || len(cfg.Normalize) > 0
+	if fixing && (cfg.Rules != "" || cfg.Preset != "") {
+		return cfg, errors.New("--fix-trailing-whitespace, --ensure-final-newline, --retab and --normalize cannot be combined with --rules or --preset")
+	}
+	if cfg.FromPlan != "" {
+		if fs.Changed("pattern") || fs.Changed("replace") || cfg.PatternStdin || cfg.PatternFile != "" || cfg.ReplaceFile != "" || cfg.Rules != "" || cfg.Preset != "" || fixing {
+			return cfg, errors.New("--from-plan cannot be combined with --pattern, --replace, --rules, --preset or the built-in fixes")
+		}
+		if cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Key != "" || cfg.Hex || cfg.Regex || cfg.Scope != "" || cfg.XPath != "" || cfg.SkipStrings {
+			return cfg, errors.New("--from-plan cannot be combined with --transform-cmd, --transform-wasm, --mode, --key, --hex, --regex, --scope, --xpath or --skip-strings")
+		}
+		if cfg.Canary != "" || cfg.UntilStable || cfg.Stamp || cfg.AssertIdempotent {
+			return cfg, errors.New("--from-plan cannot be combined with --canary, --until-stable, --stamp or --assert-idempotent")
+		}
+		if len(cfg.Glob) > 0 || len(cfg.Ext) > 0 || len(cfg.Files) > 0 || len(cfg.Roots) > 0 {
+			return cfg, errors.New("--from-plan takes its files from the plan; it cannot be combined with --glob, --ext, --files or roots")
+		}
+		p, err := plan.Load(cfg.FromPlan)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Pattern, cfg.Replace = p.Pattern, p.Replace
+		cfg.PlanFiles = make(map[string]plan.File, len(p.Files))
+		for _, f := range p.Files {
+			cfg.PlanFiles[f.Path] = f
+			cfg.Files = append(cfg.Files, filepath.FromSlash(f.Path))
+		}
+	} else if cfg.Rules != "" || cfg.Preset != "" {
+		src := "--rules"
+		if cfg.Preset != "" {
+			src = "--preset"
+		}
+		if cfg.Rules != "" && cfg.Preset != "" {
+			return cfg, errors.New("--rules and --preset are mutually exclusive")
+		}
+		if fs.Changed("pattern") || fs.Changed("replace") || cfg.PatternStdin || cfg.PatternFile != "" || cfg.ReplaceFile != "" {
+			return cfg, fmt.Errorf("%s cannot be combined with --pattern or --replace", src)
+		}
+		if cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Key != "" || cfg.Hex {
+			return cfg, fmt.Errorf("%s cannot be combined with --transform-cmd, --transform-wasm, --mode, --key or --hex", src)
+		}
+		if cfg.Rules != "" {
+			var err error
+			f := rules.Fetcher{Offline: cfg.Offline}
+			if cfg.RuleSet, err = f.Load(context.Background(), cfg.Rules); err != nil {
+				return cfg, err
+			}
+		} else {
+			p, ok := preset.Lookup(cfg.Preset, time.Now())
+			if !ok {
+				return cfg, fmt.Errorf("--preset: unknown preset %q (see safereplace preset list)", cfg.Preset)
+			}
+			recipe, err := p.Build(cfg.PresetParams)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.RuleSet, cfg.FixTrailingWhitespace = recipe.Rules, recipe.TrailingWhitespace
+			if cfg.FixTrailingWhitespace && (cfg.Scope != "" || cfg.XPath != "") {
+				return cfg, fmt.Errorf("--preset %s cannot be combined with --scope or --xpath", cfg.Preset)
+			}
+		}
+		cfg.RuleRegexps = make([]*regexp.Regexp, len(cfg.RuleSet))
+		for i, r := range cfg.RuleSet {
+			if r.Regex {
+				var err error
+				if cfg.RuleRegexps[i], err = compileRegex(cfg, r.Pattern); err != nil {
+					return cfg, fmt.Errorf("%s: rule %d: %w", src, i+1, err)
+				}
+			}
+		}
+	} else if fixing {
+		if fs.Changed("pattern") || fs.Changed("replace") || cfg.PatternStdin || cfg.PatternFile != "" || cfg.ReplaceFile != "" {
+			return cfg, errors.New("--fix-trailing-whitespace, --ensure-final-newline, --retab and --normalize cannot be combined with --pattern or --replace")
+		}
+		if cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Key != "" || cfg.Hex || cfg.Scope != "" || cfg.XPath != "" {
+			return cfg, errors.New("--fix-trailing-whitespace, --ensure-final-newline, --retab and --normalize cannot be combined with --transform-cmd, --transform-wasm, --mode, --key, --hex, --scope or --xpath")
+		}
+	} else if cfg.TransformCmd != "" || cfg.TransformWasm != "" {
+		if cfg.TransformCmd != "" && cfg.TransformWasm != "" {
+			return cfg, errors.New("--transform-cmd and --transform-wasm are mutually exclusive")
+		}
+		if cfg.TransformWasm != "" {
+			if err := checkWasm(cfg.TransformWasm); err != nil {
+				return cfg, err
+			}
+		} else if len(strings.Fields(cfg.TransformCmd)) == 0 {
+			return cfg, errors.New("--transform-cmd: empty command")
+		}
+		if cfg.Mode != modeLiteral || cfg.Key != "" || cfg.Scope != "" || cfg.XPath != "" || cfg.Hex {
+			return cfg, errors.New("--transform-cmd/--transform-wasm cannot be combined with --mode, --key, --scope, --xpath or --hex")
+		}
+	} else if cfg.Mode == modeEnvKey {
+		// The key selects what to replace; an empty value is allowed.
+		if cfg.Key == "" || cfg.Pattern != "" {
+			return cfg, errors.New("--mode env-key takes --key KEY instead of --pattern")
+		}
+		if !fs.Changed("replace") && cfg.ReplaceFile == "" {
+			return cfg, errors.New("--replace is required")
+		}
+	} else if cfg.Pattern == "" || cfg.Replace == "" {
 		return cfg, errors.New("--pattern and --replace are required")
+	} else if cfg.Key != "" {
+		return cfg, errors.New("--key requires --mode env-key")
+	}
+	if cfg.IgnoreCase && (fixing || cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Hex) {
+		return cfg, errors.New("--ignore-case cannot be combined with --transform-cmd, --transform-wasm, --mode, --hex or the built-in fixes")
+	}
+	if cfg.Word && cfg.Regex {
+		return cfg, errors.New(`--word cannot be combined with --regex; use \b in the pattern`)
+	}
+	if cfg.Word && (fixing || cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Hex) {
+		return cfg, errors.New("--word cannot be combined with --transform-cmd, --transform-wasm, --mode, --hex or the built-in fixes")
+	}
+	if (cfg.StartsWith || cfg.EndsWith) && cfg.Regex {
+		return cfg, errors.New("--starts-with and --ends-with cannot be combined with --regex; use ^ and $ with --multiline")
+	}
+	if (cfg.StartsWith || cfg.EndsWith) && (fixing || cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Hex) {
+		return cfg, errors.New("--starts-with and --ends-with cannot be combined with --transform-cmd, --transform-wasm, --mode, --hex or the built-in fixes")
+	}
+	if (cfg.NoExpand || cfg.Multiline || cfg.DotAll) && !cfg.Regex && !slices.ContainsFunc(cfg.RuleRegexps, func(re *regexp.Regexp) bool { return re != nil }) {
+		return cfg, errors.New("--no-expand, --multiline and --dotall require --regex or regex rules")
 	}
 	if cfg.Regex {
-		return cfg, errors.New("regex mode not yet implemented in MVP; use --literal (default)")
+		if cfg.Literal {
+			return cfg, errors.New("--regex and --literal are mutually exclusive")
+		}
+		if cfg.Rules != "" || cfg.Preset != "" || fixing || cfg.TransformCmd != "" || cfg.TransformWasm != "" || cfg.Mode != modeLiteral || cfg.Hex {
+			return cfg, errors.New("--regex cannot be combined with --rules, --preset, --transform-cmd, --transform-wasm, --mode, --hex or the built-in fixes")
+		}
+		if _, err := compilePattern(cfg); err != nil {
+			return cfg, fmt.Errorf("--regex: %w", err)
+		}
 	}
 	if cfg.Yes && cfg.Interactive {
 		return cfg, errors.New("--yes and --interactive are mutually exclusive")
 	}
-	if cfg.Glob == "" && cfg.Ext == "" && len(cfg.Files) == 0 {
+	// Prompts read their answers from stdin, which already held the list.
+	if cfg.FilesFrom == "-" && (cfg.Interactive || cfg.System && !cfg.DryRun && !cfg.YesSystem) {
+		return cfg, errors.New("--files-from - cannot be combined with --interactive or with --system prompts (use --yes-system)")
+	}
+	switch cfg.Mode {
+	case modeLiteral:
+	case modeGoIdent:
+		if cfg.Hex {
+			return cfg, errors.New("--mode go-ident cannot be combined with --hex")
+		}
+		for _, name := range []string{cfg.Pattern, cfg.Replace} {
+			if !token.IsIdentifier(name) {
+				return cfg, fmt.Errorf("--mode go-ident: %q is not a Go identifier", name)
+			}
+		}
+	case modeEnvKey:
+		if cfg.Hex {
+			return cfg, errors.New("--mode env-key cannot be combined with --hex")
+		}
+	default:
+		return cfg, fmt.Errorf("--mode: want literal, go-ident or env-key, got %q", cfg.Mode)
+	}
+	switch cfg.Scope {
+	case "", scopeMarkdownCode, scopeMarkdownProse, scopeFrontMatter, scopeBody:
+	default:
+		return cfg, fmt.Errorf("--scope: want markdown-code, markdown-prose, frontmatter or body, got %q", cfg.Scope)
+	}
+	if cfg.XPath != "" {
+		if cfg.Scope != "" || cfg.Mode != modeLiteral {
+			return cfg, errors.New("--xpath cannot be combined with --scope or --mode")
+		}
+		if _, err := processor.XPathScope(cfg.XPath); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.SkipStrings && (cfg.Scope != "" || cfg.XPath != "" || cfg.Mode != modeLiteral || cfg.TransformCmd != "" || cfg.TransformWasm != "" || fixing) {
+		return cfg, errors.New("--skip-strings cannot be combined with --scope, --xpath, --mode, --transform-cmd, --transform-wasm or the fix flags")
+	}
+	switch cfg.TemplateGuard {
+	case "", guardWarn, guardSkip:
+	default:
+		return cfg, fmt.Errorf("--template-guard: want warn or skip, got %q", cfg.TemplateGuard)
+	}
+	for _, d := range cfg.TemplateDelims {
+		if _, err := processor.ParseDelims(d); err != nil {
+			return cfg, fmt.Errorf("--template-delims: %w", err)
+		}
+	}
+	if len(cfg.TemplateDelims) > 0 && cfg.TemplateGuard == "" {
+		return cfg, errors.New("--template-delims requires --template-guard")
+	}
+	if cfg.Hex {
+		var err error
+		if cfg.Pattern, err = decodeHex(cfg.Pattern); err != nil {
+			return cfg, fmt.Errorf("--pattern: %w", err)
+		}
+		if cfg.Replace, err = decodeHex(cfg.Replace); err != nil {
+			return cfg, fmt.Errorf("--replace: %w", err)
+		}
+		if !fs.Changed("binary") {
+			cfg.Binary = binaryProcess
+		}
+	}
+	switch cfg.Binary {
+	case binarySkip, binaryError, binaryProcess:
+	case binaryForce:
+		cfg.Binary = binaryProcess
+	default:
+		return cfg, fmt.Errorf("--binary: want skip, error or process, got %q", cfg.Binary)
+	}
+	if cfg.Sort != sortPath && cfg.Sort != sortMatches && cfg.Sort != sortSize {
+		return cfg, fmt.Errorf("--sort: want path, matches or size, got %q", cfg.Sort)
+	}
+	if cfg.GroupBy != "" && cfg.GroupBy != groupDir && cfg.GroupBy != groupExt {
+		return cfg, fmt.Errorf("--group-by: want dir or ext, got %q", cfg.GroupBy)
+	}
+	if cfg.Print0 {
+		cfg.ListChanged = true
+	}
+	if _, _, err := parseModePolicy(cfg.ModePolicy); err != nil {
+		return cfg, err
+	}
+	if cfg.Shard != "" {
+		if _, _, err := parseShard(cfg.Shard); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.Jobs < 0 || cfg.ReadAhead < 0 || cfg.BatchSize < 0 {
+		return cfg, errors.New("--jobs, --read-ahead and --batch-size must not be negative")
+	}
+	if cfg.MaxOpenFiles != 0 && cfg.MaxOpenFiles < fdlimit.PerFile {
+		return cfg, fmt.Errorf("--max-open-files: need at least %d, got %d", fdlimit.PerFile, cfg.MaxOpenFiles)
+	}
+	for _, a := range []string{cfg.TempPrefix, cfg.TempSuffix} {
+		if err := apply.CheckTempAffix(a); err != nil {
+			return cfg, fmt.Errorf("--temp-prefix/--temp-suffix: %w", err)
+		}
+	}
+	if cfg.TempDir != "" {
+		if info, err := os.Stat(cfg.TempDir); err != nil || !info.IsDir() {
+			return cfg, fmt.Errorf("--temp-dir: %s is not a directory", cfg.TempDir)
+		}
+	}
+	if cfg.Estimate && !cfg.DryRun {
+		return cfg, errors.New("--estimate requires a dry run")
+	}
+	if cfg.PostCheck != "" && cfg.DryRun {
+		return cfg, errors.New("--post-check requires --dry-run=false")
+	}
+	switch cfg.Format {
+	case formatDiff:
+	case formatQuickfix, formatGitHub, formatMbox:
+		if cfg.ListChanged || cfg.SummaryTable || cfg.Sample > 0 {
+			return cfg, fmt.Errorf("--format %s cannot be combined with --list-changed, --summary-table or --sample", cfg.Format)
+		}
+	default:
+		return cfg, fmt.Errorf("--format: want diff, quickfix, github or mbox, got %q", cfg.Format)
+	}
+	if cfg.ListChanged && (cfg.SummaryTable || cfg.Sample > 0) {
+		return cfg, errors.New("--list-changed cannot be combined with --summary-table or --sample")
+	}
+	if cfg.Sample < 0 {
+		return cfg, errors.New("--sample must not be negative")
+	}
+	if cfg.Canary != "" {
+		if _, err := parseCanary(cfg.Canary); err != nil {
+			return cfg, err
+		}
+		// The plan must rebuild each deferred file from its recorded edits.
+		if cfg.Stamp || cfg.UntilStable || cfg.Merge {
+			return cfg, errors.New("--canary cannot be combined with --stamp, --until-stable or --merge")
+		}
+	}
+	for _, bound := range [][2]string{{"newer-than", cfg.NewerThan}, {"older-than", cfg.OlderThan}} {
+		if bound[1] != "" {
+			if _, err := parseTimeBound(bound[0], bound[1], time.Now()); err != nil {
+				return cfg, err
+			}
+		}
+	}
+	if cfg.MaxSize != "" {
+		if _, err := parseSize(cfg.MaxSize); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.MaxFiles < 0 || cfg.MaxTotalReplacements < 0 {
+		return cfg, errors.New("--max-files and --max-total-replacements must not be negative")
+	}
+	if cfg.Sample > 0 && !cfg.DryRun {
+		return cfg, errors.New("--sample only applies to dry runs")
+	}
+	if cfg.SampleBy != sampleFirst && cfg.SampleBy != sampleRandom && cfg.SampleBy != sampleMostChanged {
+		return cfg, fmt.Errorf("--sample-by: want first, random or most-changed, got %q", cfg.SampleBy)
+	}
+	if cfg.Retries < 0 || cfg.RetryBackoff < 0 {
+		return cfg, errors.New("--retries and --retry-backoff must not be negative")
+	}
+	if cfg.Timeout < 0 {
+		return cfg, errors.New("--timeout must not be negative")
+	}
+	if cfg.MaxLineLength < 0 {
+		return cfg, errors.New("--max-line-length must not be negative")
+	}
+	if cfg.MaxPasses < 1 {
+		return cfg, errors.New("--max-passes must be at least 1")
+	}
+	if fs.Changed("max-passes") && !cfg.UntilStable {
+		return cfg, errors.New("--max-passes requires --until-stable")
+	}
+	if cfg.DiffLabels != "" {
+		if _, _, err := parseDiffLabels(cfg.DiffLabels); err != nil {
+			return cfg, err
+		}
+	}
+	switch cfg.Hyperlinks {
+	case linksAuto, linksAlways, linksNever:
+	default:
+		return cfg, fmt.Errorf("--hyperlinks: want auto, always or never, got %q", cfg.Hyperlinks)
+	}
+	if _, err := wrapWidth(cfg.Wrap); err != nil {
+		return cfg, err
+	}
+	if cfg.Events != "" && cfg.Events != "ndjson" {
+		return cfg, fmt.Errorf("--events: unsupported format %q (want ndjson)", cfg.Events)
+	}
+	if cfg.EventsFile != "" && cfg.Events == "" {
+		return cfg, errors.New("--events-file requires --events")
+	}
+	if cfg.BackupReflink {
+		if cfg.BackupCompress != "" {
+			return cfg, errors.New("--backup-reflink cannot be combined with --backup-compress")
+		}
+		cfg.Backup = true
+	}
+	if cfg.YesSystem && !cfg.System {
+		return cfg, errors.New("--yes-system requires --system")
+	}
+	if cfg.System && countTrue(cfg.Backup, cfg.BackupArchive != "", cfg.BackupToTrash, cfg.BackupDiff) == 0 {
+		if fs.Changed("backup") {
+			return cfg, errors.New("--system always keeps backups; --backup=false is not allowed")
+		}
+		cfg.Backup = true
+	}
+	if cfg.BackupRunID && !cfg.Backup {
+		return cfg, errors.New("--backup-run-id requires --backup")
+	}
+	switch cfg.BackupCompress {
+	case apply.CompressNone, apply.CompressGzip, apply.CompressZstd:
+	default:
+		return cfg, fmt.Errorf("--backup-compress: unsupported format %q (want gzip or zstd)", cfg.BackupCompress)
+	}
+	if cfg.BackupCompress != "" && !cfg.Backup {
+		return cfg, errors.New("--backup-compress requires --backup")
+	}
+	if n := countTrue(cfg.Backup, cfg.BackupArchive != "", cfg.BackupToTrash, cfg.BackupDiff); n > 1 {
+		return cfg, errors.New("--backup, --backup-archive, --backup-to-trash and --backup-diff are mutually exclusive")
+	}
+	if cfg.JournalChain && cfg.Journal == "" {
+		return cfg, errors.New("--journal-chain requires --journal")
+	}
+	if cfg.JournalKey != "" && !cfg.JournalChain {
+		return cfg, errors.New("--journal-key requires --journal-chain")
+	}
+	if cfg.BackupDiff && cfg.Journal == "" {
+		return cfg, errors.New("--backup-diff requires --journal")
+	}
+	if len(cfg.Glob) == 0 && len(cfg.Ext) == 0 && len(cfg.Files) == 0 {
+		if cfg.FromPlan != "" {
+			return cfg, fmt.Errorf("--from-plan: %s: no files to apply", cfg.FromPlan)
+		}
 		return cfg, errors.New("no files specified; use --glob, --ext, or --files")
 	}
+	if err := enforcePolicy(&cfg); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
+// newFlagSet defines the flags of a replacement run, stored into cfg.
+func newFlagSet(cfg *Config) *pflag.FlagSet {
+	fs := pflag.NewFlagSet("safereplace", pflag.ContinueOnError)
+
+	fs.StringVar(&cfg.Pattern, "pattern", "", "Search pattern (required)")
+	fs.StringVar(&cfg.Replace, "replace", "", "Replacement text (required)")
+	fs.StringVar(&cfg.NewerThan, "newer-than", "", "Only select files modified after this time: a duration ago (36h, 7d) or an RFC 3339 timestamp")
+	fs.StringVar(&cfg.OlderThan, "older-than", "", "Only select files modified before this time: a duration ago (36h, 7d) or an RFC 3339 timestamp")
+	fs.StringVar(&cfg.Since, "since", "", "Only consider selected files changed since this git ref (git diff --name-only REF)")
+	fs.StringVar(&cfg.Shard, "shard", "", "Process only shard K of N (e.g. 3/8), partitioning files by a hash of their path")
+	fs.StringVar(&cfg.Mode, "mode", modeLiteral, "Matching mode: literal, go-ident (rename identifiers in .go files, literal elsewhere) or env-key")
+	fs.StringVar(&cfg.Key, "key", "", "With --mode env-key: the key whose value is replaced by --replace")
+	fs.BoolVar(&cfg.SkipStrings, "skip-strings", false, "Leave matches inside quoted string literals of source files alone")
+	fs.StringVar(&cfg.Scope, "scope", "", "Restrict replacements in Markdown files: markdown-code, markdown-prose, frontmatter or body")
+	fs.StringVar(&cfg.XPath, "xpath", "", "Replace only in XML/HTML attribute values or text selected by this XPath (e.g. //a/@href)")
+	fs.StringVar(&cfg.Rules, "rules", "", "Make the replacements of this rule file in one pass instead of --pattern/--replace (see README)")
+	fs.BoolVar(&cfg.Offline, "offline", false, "Use only cached copies of remote --rules files, never fetching them")
+	fs.StringVar(&cfg.Preset, "preset", "", "Run a built-in recipe by name instead of --pattern/--replace (see safereplace preset list)")
+	fs.StringArrayVar(&cfg.PresetParams, "param", nil, "Set a --preset parameter as name=value (repeatable)")
+	fs.BoolVar(&cfg.FixTrailingWhitespace, "fix-trailing-whitespace", false, "Trim spaces and tabs at line ends instead of replacing a pattern")
+	fs.BoolVar(&cfg.EnsureFinalNewline, "ensure-final-newline", false, "End every non-empty file with a newline instead of replacing a pattern")
+	fs.StringSliceVar(&cfg.Normalize, "normalize", nil, "Canonicalize list-like files with sort-lines and/or unique-lines instead of replacing a pattern")
+	fs.StringVar(&cfg.Retab, "retab", "", "Convert leading indentation to spaces=N or tabs[=N] instead of replacing a pattern")
+	fs.StringVar(&cfg.TransformCmd, "transform-cmd", "", "Transform each file with this command (JSON on stdin/stdout; see README)")
+	fs.StringVar(&cfg.TransformWasm, "transform-wasm", "", "Transform each file with this WASI module (same protocol as --transform-cmd), sandboxed")
+	fs.StringVar(&cfg.TemplateGuard, "template-guard", "", "Matches inside template delimiters: warn, or skip (leave them unchanged)")
+	fs.StringSliceVar(&cfg.TemplateDelims, "template-delims", nil, "Template delimiters for --template-guard as OPEN...CLOSE (default {{...}},${...},<%...%>)")
+	fs.BoolVar(&cfg.SecretCheck, "secret-check", true, "Warn when the pattern or replacement looks like a credential")
+	fs.StringVar(&cfg.Config, "config", "", "Per-file overrides config (default: .safereplace.conf if present)")
+	fs.BoolVar(&cfg.PatternStdin, "pattern-stdin", false, "Read the search pattern from stdin")
+	fs.StringVar(&cfg.PatternFile, "pattern-file", "", "Read the search pattern from a file")
+	fs.StringVar(&cfg.ReplaceFile, "replace-file", "", "Read the replacement text from a file")
+	fs.BoolVar(&cfg.Regex, "regex", false, "Treat --pattern as a Go regular expression (default: literal)")
+	fs.BoolVar(&cfg.Multiline, "multiline", false, "With --regex or regex rules, let ^ and $ match at the start and end of each line (?m)")
+	fs.BoolVar(&cfg.DotAll, "dotall", false, "With --regex or regex rules, let . match newlines (?s)")
+	fs.BoolVar(&cfg.NoExpand, "no-expand", false, "With --regex or regex rules, insert --replace literally instead of expanding $1 and ${name}")
+	fs.BoolVar(&cfg.Literal, "literal", false, "Force literal mode (default)")
+	fs.BoolVar(&cfg.IgnoreCase, "ignore-case", false, "Match --pattern case-insensitively")
+	fs.BoolVar(&cfg.Word, "word", false, "Match --pattern only as a whole word, bounded by non-word characters or line boundaries")
+	fs.BoolVar(&cfg.StartsWith, "starts-with", false, "Match --pattern only at the start of a line")
+	fs.BoolVar(&cfg.EndsWith, "ends-with", false, "Match --pattern only at the end of a line")
+	fs.StringArrayVar(&cfg.Glob, "glob", nil, "File glob to match (e.g. \"*.go\"; repeatable)")
+	fs.StringSliceVar(&cfg.Ext, "ext", nil, "File extension filter without dot (e.g. \"txt\", or go,md; repeatable)")
+	fs.StringSliceVar(&cfg.Files, "files", nil, "Explicit list of files")
+	fs.BoolVar(&cfg.FollowSymlinks, "follow-symlinks", false, "Follow symlinks to files and directories during discovery (link cycles are detected)")
+	fs.StringVar(&cfg.FilesFrom, "files-from", "", "Read more files from this list, one path per line (- for stdin)")
+	fs.BoolVarP(&cfg.Null, "null", "0", false, "Paths in --files-from are NUL-separated, as written by find -print0")
+	fs.BoolVar(&cfg.Yes, "yes", false, "Apply all changes without prompt")
+	fs.BoolVar(&cfg.Interactive, "interactive", false, "Confirm per file before applying")
+	fs.BoolVar(&cfg.Backup, "backup", false, "Create backups before modifying files")
+	fs.BoolVar(&cfg.DryRun, "dry-run", true, "Preview changes only (default)")
+	fs.BoolVar(&cfg.NoColor, "no-color", false, "Disable ANSI colors in output")
+	fs.StringVar(&cfg.Hyperlinks, "hyperlinks", linksAuto, "Render file headers as terminal hyperlinks: auto, always or never")
+	fs.StringVar(&cfg.LinkTemplate, "link-template", "", "URL file headers link to, with {path}, {abspath} and {line} (default: a file:// URL)")
+	fs.StringVar(&cfg.DiffLabels, "diff-labels", "", "Diff header labels: git (a/PATH, b/PATH) or OLD,NEW with {path} (default: before,after)")
+	fs.IntVar(&cfg.Context, "context", 0, "Number of context lines in the unified diffs of --diff-labels (0: 3)")
+	fs.BoolVar(&cfg.StrictEOL, "strict-eol", false, "Treat a single trailing final newline difference as a change")
+	fs.StringVar(&cfg.Wrap, "wrap", "", "Soft-wrap diff lines at N columns or \"auto\" (terminal width)")
+	fs.IntVar(&cfg.MaxLineLength, "max-line-length", 0, "Show long changed lines as an excerpt of N characters around the change (0 = off)")
+	fs.BoolVar(&cfg.Hex, "hex", false, "Interpret --pattern and --replace as hex bytes (e.g. 0xDEADBEEF); implies --binary process")
+	fs.StringVar(&cfg.Binary, "binary", binaryError, "Binary file handling: skip (quietly), error or process (replace and show a byte-offset summary)")
+	fs.StringVar(&cfg.Sort, "sort", sortPath, "Order files by path, matches or size (largest first)")
+	fs.StringVar(&cfg.SortLocale, "sort-locale", "", "Order paths for this locale (e.g. de_DE.UTF-8, or auto for $LC_ALL/$LC_COLLATE/$LANG) instead of byte-wise")
+	fs.StringVar(&cfg.GroupBy, "group-by", "", "Group files by dir or ext")
+	fs.StringVar(&cfg.Format, "format", formatDiff, "Per-file output: diff, quickfix (file:line:col: message for Vim/Emacs) github (Actions annotations) or mbox (patch series for git am)")
+	fs.StringVar(&cfg.MboxSubject, "mbox-subject", defaultMboxSubject, "Subject of --format mbox patches; {path}, {files}, {matches}, {pattern} and {replace} are expanded. One patch per file, or per group with --group-by")
+	fs.StringVar(&cfg.ReportCodeQuality, "report-codequality", "", "Write replacements as a GitLab Code Quality JSON report to this file")
+	fs.BoolVar(&cfg.ListChanged, "list-changed", false, "Print only the paths of changed files, one per line")
+	fs.BoolVar(&cfg.Print0, "print0", false, "Separate --list-changed paths with NUL (for xargs -0); implies --list-changed")
+	fs.IntVar(&cfg.Sample, "sample", 0, "Dry run: preview only N files, still reporting totals for all (0 = all)")
+	fs.StringVar(&cfg.SampleBy, "sample-by", sampleFirst, "How --sample picks files: first, random or most-changed")
+	fs.StringVar(&cfg.DiffDir, "diff-dir", "", "Write each file's preview to DIR/<relpath>.diff instead of stdout")
+	fs.BoolVar(&cfg.Estimate, "estimate", false, "In a dry run, estimate apply time, bytes rewritten and backup space")
+	fs.BoolVar(&cfg.SummaryTable, "summary-table", false, "Print an aligned summary table instead of per-file output")
+	fs.StringVar(&cfg.Events, "events", "", "Emit lifecycle events in the given format (ndjson)")
+	fs.StringVar(&cfg.EventsFile, "events-file", "", "Write events to this file instead of stdout")
+	fs.BoolVar(&cfg.LocalTime, "local-time", false, "Write timestamps in journals, events and mbox output in local time instead of UTC")
+	fs.StringVar(&cfg.Journal, "journal", "", "Directory to write a per-run audit journal to")
+	fs.BoolVar(&cfg.JournalChain, "journal-chain", false, "Write the journal as an append-only hash chain")
+	fs.StringVar(&cfg.JournalKey, "journal-key", "", "Key file of --journal-chain (default: $XDG_STATE_HOME/safereplace/journal.key, created if missing)")
+	fs.BoolVar(&cfg.BackupRunID, "backup-run-id", false, "Include the run ID in backup file names")
+	fs.StringVar(&cfg.MaxSize, "max-size", "", "Skip files larger than this without reading them (e.g. 5MB, 512KiB)")
+	fs.IntVar(&cfg.MaxFiles, "max-files", 0, "Refuse to apply if more than N files would change (0: no limit)")
+	fs.IntVar(&cfg.MaxTotalReplacements, "max-total-replacements", 0, "Refuse to apply if more than N replacements would be made (0: no limit)")
+	fs.StringVar(&cfg.Canary, "canary", "", "Apply only a deterministic sample of this percentage of changed files (e.g. 5%); save the rest to the plan")
+	fs.StringVar(&cfg.PlanOut, "plan-out", "", "Save the planned changes (with --canary: the deferred ones) as a JSON plan to this file")
+	fs.StringVar(&cfg.FromPlan, "from-plan", "", "Apply the changes saved in this plan to the files it lists instead of matching a pattern")
+	fs.StringVar(&cfg.ValidateCmd, "validate-cmd", "", "Reject files whose new content fails this shell command ({} is a temporary copy of it)")
+	fs.StringVar(&cfg.PostCheck, "post-check", "", "Shell command run once after applying (e.g. \"go vet ./...\"); roll every file back if it fails")
+	fs.BoolVar(&cfg.AssertIdempotent, "assert-idempotent", false, "Fail files that a second pass of the same replacement would change again")
+	fs.BoolVar(&cfg.UntilStable, "until-stable", false, "Repeat the replacement over each file's new content until a pass changes nothing")
+	fs.IntVar(&cfg.MaxPasses, "max-passes", 10, "Fail files still changing after this many --until-stable passes")
+	fs.BoolVar(&cfg.Stamp, "stamp", false, "Add or refresh a \"modified by safereplace run ID\" comment line in changed files")
+	fs.StringVar(&cfg.BackupCompress, "backup-compress", "", "Compress backups with gzip or zstd")
+	fs.BoolVar(&cfg.System, "system", false, "Guard system files (/etc, /usr, ...): refuse package-managed ones, confirm before applying, always back up and log to syslog")
+	fs.BoolVar(&cfg.YesSystem, "yes-system", false, "Confirm the --system file list without a prompt (--yes does not)")
+	fs.BoolVar(&cfg.BackupReflink, "backup-reflink", false, "Back up originals as copy-on-write clones (btrfs, XFS, APFS): instant and nearly free; implies --backup")
+	fs.StringVar(&cfg.BackupArchive, "backup-archive", "", "Store all originals of the run in one .tar.gz archive")
+	fs.BoolVar(&cfg.BackupToTrash, "backup-to-trash", false, "Copy originals into the OS trash before modifying")
+	fs.BoolVar(&cfg.BackupDiff, "backup-diff", false, "Store reverse patches in the journal instead of backup copies (requires --journal)")
+	fs.StringVar(&cfg.CPUProfile, "cpuprofile", "", "Write a CPU profile of the run to this file")
+	fs.StringVar(&cfg.MemProfile, "memprofile", "", "Write a heap profile at the end of the run to this file")
+	fs.StringVar(&cfg.Trace, "trace", "", "Write an execution trace of the run to this file")
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "Stop the run after this long (e.g. 5m) and report completed, skipped and pending files")
+	fs.IntVar(&cfg.Retries, "retries", 3, "Retry reads and writes failing with transient errors (EAGAIN, EBUSY, sharing violations) this many times")
+	fs.DurationVar(&cfg.RetryBackoff, "retry-backoff", 100*time.Millisecond, "Delay before the first retry; doubles for each further retry")
+	fs.StringVar(&cfg.TempPrefix, "temp-prefix", "", "Prefix for temp file names while applying")
+	fs.StringVar(&cfg.TempSuffix, "temp-suffix", "", "Suffix for temp file names while applying")
+	fs.IntVar(&cfg.Jobs, "jobs", 0, "Files processed concurrently (0: one per CPU)")
+	fs.IntVar(&cfg.ReadAhead, "read-ahead", 0, "Files queued ahead of the workers (0: twice --jobs)")
+	fs.IntVar(&cfg.BatchSize, "batch-size", 0, "Files handed to a worker at a time (0: grows with the number of files)")
+	fs.IntVar(&cfg.MaxOpenFiles, "max-open-files", 0, "Open at most this many file descriptors for files at once (0: from ulimit -n)")
+	fs.StringVar(&cfg.TempDir, "temp-dir", "", "Directory for temp files while applying (default: next to each file)")
+	fs.StringVar(&cfg.ModePolicy, "mode-policy", apply.ModePreserve, "Permissions of rewritten files: preserve, umask, or an octal mode such as 0644")
+	fs.BoolVar(&cfg.ForcePerm, "force-perm", false, "Temporarily lift read-only/immutable attributes to apply changes")
+	fs.BoolVar(&cfg.Merge, "merge", false, "Merge edits onto files changed since the preview, marking conflicts, instead of refusing them")
+	return fs
+}
+
 // Run executes the CLI with the provided args and writers, returning the exit code.
+// --pattern-stdin reads from os.Stdin.
 func Run(args []string, stdout, stderr io.Writer) int {
-	cfg, err := parseArgs(args)
+	return RunWithStdin(args, os.Stdin, stdout, stderr)
+}
+
+// RunWithStdin is Run with an explicit stdin for --pattern-stdin.
+func RunWithStdin(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	recorded := args
+	args, err := expandArgFiles(args)
 	if err != nil {
 		fmt.Fprintln(stderr, err)
 		return 2
 	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "undo":
+			return runUndo(args[1:], stdout, stderr)
+		case "verify":
+			return runVerify(args[1:], stdout, stderr)
+		case "clean-temp":
+			return runCleanTemp(args[1:], stdout, stderr)
+		case "invert":
+			return runInvert(args[1:], stdout, stderr)
+		case "preset":
+			return runPreset(args[1:], stdout, stderr)
+		case "plan":
+			return runPlan(args[1:], stdin, stdout, stderr)
+		case "history":
+			return runHistory(args[1:], stdout, stderr)
+		case "rerun":
+			return runRerun(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	var entry history.Entry
+	code := run(args, stdin, stdout, stderr, &entry)
+	recordHistory(entry, recorded, code, stderr)
+	return code
+}
+
+// run is the replacement command. It fills in the run ID and totals of entry
+// for the history.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, entry *history.Entry) int {
+	cfg, err := parseArgs(args, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	stopProfiling, err := startProfiling(cfg)
+	defer func() {
+		if err := stopProfiling(); err != nil {
+			fmt.Fprintf(stderr, "warn: %v\n", err)
+		}
+	}()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	conf, err := loadConfig(cfg.Config)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	runID := newRunID(time.Now())
+	stderr = newRunLog(stderr, runID)
 
-	paths, discErr := discovery.Discover(".", discovery.Selector{
-		Glob:    cfg.Glob,
-		Ext:     cfg.Ext,
-		Files:   cfg.Files,
-		Exclude: nil,
-	})
+	var events *eventSink
+	if cfg.Events != "" {
+		if cfg.EventsFile != "" {
+			f, ferr := os.Create(cfg.EventsFile)
+			if ferr != nil {
+				fmt.Fprintf(stderr, "error: events: %v\n", ferr)
+				return 2
+			}
+			defer func() { _ = f.Close() }()
+			events = newEventSink(f, runID, cfg.LocalTime)
+		} else {
+			// Events own stdout so the stream stays parseable; human output is dropped.
+			events = newEventSink(stdout, runID, cfg.LocalTime)
+			stdout = io.Discard
+		}
+	}
+
+	var hadErrors, hadChanges bool
+	var jw *journal.Writer
+	if cfg.Journal != "" {
+		if cfg.JournalChain {
+			var key []byte
+			if key, err = loadJournalKey(cfg.JournalKey, true); err == nil {
+				jw, err = journal.CreateChained(cfg.Journal, runID, key)
+			}
+		} else {
+			jw, err = journal.Create(cfg.Journal, runID)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 2
+		}
+		defer func() { _ = jw.Close() }()
+	}
+	record := func(e journal.Entry) {
+		if jw == nil {
+			return
+		}
+		e.Time = timestamp(time.Now(), cfg.LocalTime)
+		if err := jw.Append(e); err != nil {
+			fmt.Fprintf(stderr, "warn: %v\n", err)
+			hadErrors = true
+		}
+	}
+	record(journal.Entry{Action: journal.ActionRunStart, Args: args})
+
+	var archive *apply.Archive
+	if cfg.BackupArchive != "" && !cfg.DryRun {
+		archive, err = apply.CreateArchive(cfg.BackupArchive, ".")
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 2
+		}
+		defer func() {
+			if err := archive.Close(); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+			}
+		}()
+	}
+
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	sel := discovery.Selector{
+		Glob:           cfg.Glob,
+		Ext:            cfg.Ext,
+		Files:          cfg.Files,
+		Exclude:        cfg.Exclude,
+		FollowSymlinks: cfg.FollowSymlinks,
+	}
+	now := time.Now()
+	if cfg.NewerThan != "" {
+		sel.NewerThan, _ = parseTimeBound("newer-than", cfg.NewerThan, now) // validated in parseArgs
+	}
+	if cfg.OlderThan != "" {
+		sel.OlderThan, _ = parseTimeBound("older-than", cfg.OlderThan, now) // validated in parseArgs
+	}
+	paths, discErr := discovery.DiscoverRoots(ctx, cfg.Roots, sel)
+	if cfg.Since != "" && len(paths) > 0 {
+		changed, err := gitdiff.ChangedSince(ctx, ".", cfg.Since)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: --since: %v\n", err)
+			events.emit(event{Event: evError, Error: err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+			return 2
+		}
+		paths = onlyChanged(paths, changed)
+	}
+	if cfg.Shard != "" {
+		k, n, _ := parseShard(cfg.Shard) // validated in parseArgs
+		paths = shardPaths(paths, k, n)
+	}
 	if discErr != nil && len(paths) == 0 {
 		fmt.Fprintln(stderr, discErr)
+		events.emit(event{Event: evError, Error: discErr.Error()})
+		record(journal.Entry{Action: journal.ActionError, Error: discErr.Error()})
 		return 2
 	}
 
-	var hadErrors, hadChanges bool
+	var rows []fileSummary
+	entry.RunID = runID
+	defer func() { entry.Files, entry.Replacements = changeTotals(rows) }()
+	// applied keeps the original content of written files for --post-check.
+	var applied []appliedFile
+	color := !cfg.NoColor && enableColor(stdout)
+	links := cfg.Hyperlinks == linksAlways || cfg.Hyperlinks == linksAuto && supportsHyperlinks(stdout)
+	wrap, _ := wrapWidth(cfg.Wrap) // validated in parseArgs
 	// Ensure deterministic order
-	sort.Strings(paths)
+	coll := collate.New(cfg.SortLocale)
+	slices.SortFunc(paths, coll.Compare)
 
 	for _, p := range paths {
-		res, perr := processor.SubstituteLiteralFile(p, cfg.Pattern, cfg.Replace)
+		events.emit(event{Event: evFileDiscovered, Path: p})
+	}
+
+	var completed, skipped int
+	if cfg.MaxSize != "" {
+		limit, _ := parseSize(cfg.MaxSize) // validated in parseArgs
+		var large []largeFile
+		paths, large = dropLarge(paths, limit)
+		for _, f := range large {
+			reason := fmt.Sprintf("%s, larger than --max-size %s", formatBytes(f.size), cfg.MaxSize)
+			fmt.Fprintf(stderr, "skip: %s: %s\n", f.path, reason)
+			events.emit(event{Event: evFileSkipped, Path: f.path, Reason: reason})
+			record(journal.Entry{Action: journal.ActionSkipped, Path: f.path, Error: reason})
+			rows = append(rows, fileSummary{Path: f.path, Status: "skipped"})
+			skipped++
+		}
+	}
+
+	// Process every file first so results can be ordered by --sort/--group-by
+	// before anything is printed or written.
+	transform, closeTransform, err := newTransformer(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		events.emit(event{Event: evError, Error: err.Error()})
+		record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+		return 2
+	}
+	defer closeTransform()
+	fixes := fixesFor(cfg)
+	prog := &progress{total: len(paths)}
+	defer watchProgress(prog, stderr)()
+
+	// A missing final newline is the whole change --ensure-final-newline makes.
+	baseOpts := diff.Options{Color: color, Context: cfg.Context, StrictEOL: cfg.StrictEOL || cfg.EnsureFinalNewline, Wrap: wrap, MaxLineLength: cfg.MaxLineLength}
+	xpath, _ := processor.XPathScope(cfg.XPath) // validated in parseArgs
+	var re *regexp.Regexp
+	if cfg.Regex {
+		re, _ = compilePattern(cfg) // validated in parseArgs
+	}
+	retryPolicy := retry.Policy{Retries: cfg.Retries, Backoff: cfg.RetryBackoff}
+	maxOpen := cfg.MaxOpenFiles
+	if maxOpen == 0 {
+		maxOpen = fdlimit.Default()
+	}
+	budget, _ := fdlimit.New(maxOpen) // validated in parseArgs
+
+	// processOne reads and matches one file and renders its preview; it runs
+	// concurrently for --jobs files at a time.
+	processOne := func(p string) (fileResult, bool) {
+		prog.start(p)
+		ov := conf.For(displayPath(p))
+		if ov.Skip {
+			prog.scannedFile(false)
+			return fileResult{row: fileSummary{Path: p}, skip: "config: skip"}, true
+		}
+		set := settingsFor(cfg, baseOpts, ov)
+		set.proc.Scope = scopeFor(cfg.Scope, p)
+		if xpath != nil {
+			set.proc.Scope = xpath
+		}
+		if cfg.SkipStrings {
+			set.proc.Scope = processor.OutsideStrings(p)
+		}
+		var res processor.Result
+		began := time.Now()
+		if err := budget.Acquire(ctx); err != nil {
+			return fileResult{}, false
+		}
+		perr := retry.Do(retryPolicy, func() (err error) {
+			switch {
+			case cfg.PlanFiles != nil:
+				res, err = plannedResult(p, cfg.PlanFiles)
+			case len(fixes) > 0:
+				res, err = processor.FixFile(p, fixes, set.proc)
+			case len(set.rules) > 0:
+				res, err = processor.SubstituteLiteralsFile(p, set.rules, set.proc)
+			case transform != nil:
+				res, err = processor.SubstituteCommandFile(ctx, transform, p, cfg.Pattern, set.replace, set.proc)
+			case cfg.Mode == modeEnvKey:
+				res, err = processor.SubstituteEnvKeyFile(p, cfg.Key, set.replace, set.proc)
+			case cfg.Mode == modeGoIdent && strings.EqualFold(filepath.Ext(p), ".go"):
+				res, err = processor.SubstituteGoIdentFile(p, cfg.Pattern, cfg.Replace, set.proc)
+			case re != nil:
+				res, err = processor.SubstituteRegexFile(p, re, set.replace, !cfg.NoExpand, set.proc)
+			default:
+				res, err = processor.SubstituteLiteralFileWithOptions(p, cfg.Pattern, set.replace, set.proc)
+			}
+			return err
+		})
+		budget.Release()
+		prog.scannedFile(perr == nil && res.Changed)
+		if errors.Is(perr, processor.ErrBinary) && set.binary == binarySkip {
+			return fileResult{row: fileSummary{Path: p}, skip: "binary"}, true
+		}
 		if perr != nil {
-			fmt.Fprintf(stderr, "warn: %s: %v\n", p, perr)
-			hadErrors = true
-			continue
+			status := "error"
+			if errors.Is(perr, processor.ErrNotRegular) {
+				status = "refused"
+			}
+			return fileResult{row: fileSummary{Path: p, Status: status}, err: perr}, true
+		}
+		var notes strings.Builder
+		if cfg.TemplateGuard != "" && res.Changed {
+			res = guardTemplates(&notes, p, res, templateDelims(cfg.TemplateDelims, ov), cfg.TemplateGuard)
+			if !res.Changed {
+				return fileResult{row: fileSummary{Path: p}, skip: "inside template delimiters", notes: notes.String()}, true
+			}
 		}
 		if !res.Changed {
-			continue
+			return fileResult{row: fileSummary{Path: p}, skip: "no changes"}, true
+		}
+		// again reruns the replacement over new content, in memory.
+		again := func(data []byte) (processor.Result, error) {
+			r, err := substituteAgain(ctx, cfg, transform, re, p, set, data)
+			if err == nil && cfg.TemplateGuard != "" {
+				r = guardTemplates(io.Discard, p, r, templateDelims(cfg.TemplateDelims, ov), cfg.TemplateGuard)
+			}
+			return r, err
+		}
+		var passes []int
+		if cfg.UntilStable {
+			var err error
+			if res, passes, err = untilStable(res, cfg.MaxPasses, again); err != nil {
+				row := fileSummary{Path: p, Matches: res.Matches, Replacements: res.Replacements, Status: "error"}
+				return fileResult{row: row, err: err, changed: true, notes: notes.String()}, true
+			}
+		}
+		if cfg.AssertIdempotent {
+			second, err := again(res.After)
+			if err == nil && second.Changed {
+				err = fmt.Errorf("%w: a second pass would make %d more replacement(s)", errNotIdempotent, second.Replacements)
+			}
+			if err != nil {
+				row := fileSummary{Path: p, Matches: res.Matches, Replacements: res.Replacements, Status: "error"}
+				return fileResult{row: row, err: err, changed: true, notes: notes.String()}, true
+			}
+		}
+		if cfg.Stamp && !res.Binary {
+			if after, ok := stamp(res.After, p, runID); ok {
+				res.After = after
+			} else {
+				fmt.Fprintf(&notes, "warn: %s: no comment syntax known for --stamp; not stamped\n", p)
+			}
+		}
+		if cfg.ValidateCmd != "" {
+			if err := validate(ctx, cfg.ValidateCmd, p, res.After); err != nil {
+				row := fileSummary{Path: p, Matches: res.Matches, Replacements: res.Replacements, Status: "rejected"}
+				return fileResult{row: row, err: err, changed: true, notes: notes.String()}, true
+			}
 		}
-		hadChanges = true
 
-		opts := diff.Options{Color: !cfg.NoColor, Context: cfg.Context, StrictEOL: cfg.StrictEOL}
-		preview, changed, derr := diff.Diff(res.Before, res.After, opts)
+		opts := set.diff
+		if cfg.DiffLabels != "" {
+			opts.OldLabel, opts.NewLabel = diffLabels(cfg.DiffLabels, p)
+		}
+		// UTF-16 files are shown decoded rather than as binary changes.
+		enc := diff.DetectEncoding(res.Before)
+		render := func(opts diff.Options) (string, bool, error) {
+			if enc != "" {
+				return diff.DiffEncoded(res.Before, res.After, enc, opts)
+			}
+			if res.Binary || cfg.Hex {
+				return diff.BinarySummary(byteChanges(res), opts), true, nil
+			}
+			if cfg.DiffLabels != "" {
+				return diff.DiffUnified(res.Before, res.After, opts)
+			}
+			return diff.DiffBytes(res.Before, res.After, opts)
+		}
+		preview, changed, derr := render(opts)
 		if derr != nil {
-			fmt.Fprintf(stderr, "warn: %s: diff error: %v\n", p, derr)
-			hadErrors = true
-			continue
+			row := fileSummary{Path: p, Matches: res.Matches, Replacements: res.Replacements, Status: "error"}
+			return fileResult{row: row, err: fmt.Errorf("diff error: %w", derr), changed: true, notes: notes.String()}, true
 		}
 		if !changed {
 			// e.g., only trailing final newline difference with StrictEOL=false
-			continue
+			return fileResult{row: fileSummary{Path: p}, skip: "trailing newline only", changed: true, notes: notes.String()}, true
 		}
 
-		fmt.Fprintf(stdout, "file: %s  (matches: %d, replacements: %d)\n", p, res.Matches, res.Replacements)
+		fr := fileResult{res: res, preview: preview, plain: preview, elapsed: time.Since(began), changed: true, notes: notes.String(), passes: passes}
+		fr.row = fileSummary{Path: p, Matches: res.Matches, Replacements: res.Replacements, Status: "preview", Kind: classify.Classify(displayPath(p), res.Before)}
+		if enc != "" {
+			fr.row.Added, fr.row.Removed = diff.StatBytes(diff.Decode(res.Before, enc), diff.Decode(res.After, enc))
+		} else if !res.Binary && !cfg.Hex {
+			fr.row.Added, fr.row.Removed = diff.StatBytes(res.Before, res.After)
+		}
+		if cfg.DiffDir != "" && opts.Color {
+			opts.Color = false
+			fr.plain, _, _ = render(opts)
+		}
+		return fr, true
+	}
+	// pending collects files never finished because --timeout expired.
+	results, pending := processAll(ctx, paths, resolveSchedule(cfg, len(paths)), processOne)
+	for i, r := range results {
+		if o := conf.For(displayPath(r.row.Path)).Order; o != nil {
+			results[i].order = *o
+		}
+		io.WriteString(stderr, r.notes)
+		hadErrors = hadErrors || r.err != nil
+		hadChanges = hadChanges || r.changed
+	}
+	sortResults(results, cfg.Sort, cfg.GroupBy, coll)
+	if cfg.UntilStable {
+		reportPasses(stderr, results)
+	}
+	var systemFiles []string
+	if cfg.System {
+		systemFiles = guardSystemFiles(ctx, results)
+		for _, r := range results {
+			hadErrors = hadErrors || r.err != nil
+		}
+	}
+	if cfg.SecretCheck {
+		changed := 0
+		for _, r := range results {
+			if r.err == nil && r.skip == "" {
+				changed++
+			}
+		}
+		warnSecrets(stderr, cfg, changed)
+	}
+	// saved holds the files of the plan written once every check passed.
+	var saved []fileResult
+	if cfg.Canary != "" {
+		pct, _ := parseCanary(cfg.Canary) // validated in parseArgs
+		saved = deferCanary(results, pct)
+		cfg.PlanOut = cmp.Or(cfg.PlanOut, "safereplace-plan-"+runID+".json")
+	} else if cfg.PlanOut != "" {
+		saved = slices.DeleteFunc(slices.Clone(results), func(r fileResult) bool { return r.err != nil || r.skip != "" })
+	}
+	if err := checkLimits(cfg, results); err != nil {
 		if cfg.DryRun {
-			fmt.Fprint(stdout, preview)
+			fmt.Fprintf(stderr, "warn: %v\n", err)
 		} else {
+			fmt.Fprintf(stderr, "error: %v; nothing applied\n", err)
+			events.emit(event{Event: evError, Error: err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+			return 2
+		}
+	}
+	if !cfg.DryRun {
+		if err := preflightSpace(cfg, results); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			events.emit(event{Event: evError, Error: err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+			return 2
+		}
+	}
+	var shown map[int]bool
+	if cfg.Sample > 0 {
+		shown = sampleResults(results, cfg.Sample, cfg.SampleBy)
+	}
+	// quiet suppresses the per-file preview: replaced by the table, the
+	// --list-changed list or --format findings, or left out of the sample.
+	quiet := func(i int) bool {
+		return cfg.SummaryTable || cfg.ListChanged || cfg.Format != formatDiff || (shown != nil && !shown[i])
+	}
+	listChanged := func(p string) {
+		if !cfg.ListChanged {
+			return
+		}
+		if cfg.Print0 {
+			fmt.Fprintf(stdout, "%s\x00", p)
+		} else {
+			fmt.Fprintln(stdout, p)
+		}
+	}
+
+	var group string
+	var issues []cqIssue         // for --report-codequality
+	var series []mboxPatch       // for --format mbox
+	var prompt *bufio.Reader     // for --interactive and --system, read from stdin
+	crossFS := map[string]bool{} // directories warned about for --temp-dir
+	var audit auditLog           // for --system
+	if cfg.System && !cfg.DryRun && len(systemFiles) > 0 {
+		prompt = bufio.NewReader(stdin)
+		if !cfg.YesSystem && !confirmSystem(prompt, stdout, systemFiles) {
+			fmt.Fprintln(stderr, "error: --system: not confirmed; nothing applied")
+			record(journal.Entry{Action: journal.ActionError, Error: "--system: not confirmed"})
+			return 2
+		}
+		var err error
+		if audit, err = openAuditLog(); err != nil {
+			fmt.Fprintf(stderr, "warn: --system: %v; changes are not logged to syslog\n", err)
+		} else {
+			defer func() { _ = audit.Close() }()
+		}
+	}
+	if cfg.PlanOut != "" {
+		if err := plan.Write(cfg.PlanOut, plan.Plan{RunID: runID, Pattern: cfg.Pattern, Replace: cfg.Replace, Files: planFiles(saved)}); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			events.emit(event{Event: evError, Error: err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+			return 2
+		}
+		if cfg.Canary != "" {
+			fmt.Fprintf(stderr, "canary: %d file(s) deferred to %s; apply them with: safereplace plan apply %s\n", len(saved), cfg.PlanOut, cfg.PlanOut)
+		}
+	}
+	for i, fr := range results {
+		p, res, row := fr.row.Path, fr.res, fr.row
+		if ctx.Err() != nil {
+			for _, r := range results[i:] {
+				pending = append(pending, r.row.Path)
+			}
+			break
+		}
+		prog.start(p)
+		if fr.skip != "" {
+			events.emit(event{Event: evFileSkipped, Path: p, Reason: fr.skip})
+			skipped++
+			continue
+		}
+		if cfg.GroupBy != "" && !quiet(i) {
+			if key := groupKey(p, cfg.GroupBy); i == 0 || key != group {
+				group = key
+				fmt.Fprintf(stdout, "group: %s\n", group)
+			}
+		}
+		if fr.err != nil {
+			fmt.Fprintf(stderr, "warn: %s: %v\n", p, fr.err)
+			events.emit(event{Event: evError, Path: p, Error: fr.err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Path: p, Error: fr.err.Error()})
+			rows = append(rows, row)
+			continue
+		}
+		beforeSum, afterSum := journal.Hash(res.Before), journal.Hash(res.After)
+		var diffFile string
+		if cfg.DiffDir != "" {
+			var werr error
+			if diffFile, werr = writeDiffFile(cfg.DiffDir, p, fr.plain); werr != nil {
+				fmt.Fprintf(stderr, "error: %s: %v\n", p, werr)
+				events.emit(event{Event: evError, Path: p, Error: werr.Error()})
+				record(journal.Entry{Action: journal.ActionError, Path: p, Error: werr.Error()})
+				hadErrors = true
+				row.Status = "error"
+				rows = append(rows, row)
+				continue
+			}
+		}
+		if !quiet(i) {
+			name := p
+			if links {
+				name = hyperlink(linkTarget(cfg.LinkTemplate, p, firstChangedLine(res.Before, res.After)), p)
+			}
+			fmt.Fprintf(stdout, "file: %s  (matches: %d, replacements: %d)\n", name, res.Matches, res.Replacements)
+		}
+		if cfg.DryRun {
+			if !quiet(i) {
+				if diffFile != "" {
+					fmt.Fprintf(stdout, "diff: %s\n", diffFile)
+				} else {
+					fmt.Fprint(stdout, fr.preview)
+				}
+			}
+			listChanged(p)
+			writeFindings(stdout, cfg.Format, p, res)
+			if cfg.Format == formatMbox {
+				series = addMboxFile(series, p, res, cfg.GroupBy)
+			}
+			if cfg.ReportCodeQuality != "" {
+				issues = append(issues, codeQualityIssues(p, res)...)
+			}
+			events.emit(event{Event: evFilePreviewed, Path: p, Matches: res.Matches, Replacements: res.Replacements, BeforeSHA256: beforeSum, AfterSHA256: afterSum})
+			record(journal.Entry{Action: journal.ActionPreviewed, Path: p, Matches: res.Matches, Replacements: res.Replacements, BeforeSHA256: beforeSum, AfterSHA256: afterSum})
+		} else {
+			var conflicts int
+			current, drifted := drift(p, res)
+			switch {
+			case !drifted:
+			case cfg.Interactive:
+				if prompt == nil {
+					prompt = bufio.NewReader(stdin)
+				}
+				answer, after := promptDrift(prompt, stdout, stderr, p, res, current)
+				if after != nil {
+					res = rebase(res, current, after)
+					break
+				}
+				reason, status := "kept on-disk version", "kept"
+				if answer == resolveSkip {
+					reason, status = "changed since preview", "conflict"
+					hadErrors = true
+				}
+				events.emit(event{Event: evFileSkipped, Path: p, Reason: reason})
+				record(journal.Entry{Action: journal.ActionSkipped, Path: p, Error: reason})
+				row.Status = status
+				rows = append(rows, row)
+				skipped++
+				continue
+			case cfg.Merge && !res.Binary && !processor.IsBinary(current):
+				res, conflicts = mergeDrift(stderr, p, res, current)
+			default:
+				reportDrift(stdout, stderr, p, res, current, processOne)
+				events.emit(event{Event: evFileSkipped, Path: p, Reason: "changed since preview"})
+				record(journal.Entry{Action: journal.ActionSkipped, Path: p, Error: "changed since preview"})
+				hadErrors = true
+				row.Status = "conflict"
+				rows = append(rows, row)
+				skipped++
+				continue
+			}
+			if drifted {
+				beforeSum, afterSum = journal.Hash(res.Before), journal.Hash(res.After)
+				row.Matches, row.Replacements = res.Matches, res.Replacements
+			}
 			// Apply changes safely with optional backup
-			if err := apply.WriteAtomic(p, []byte(res.After), apply.Options{Backup: cfg.Backup}); err != nil {
+			aopts := apply.Options{
+				Backup:         cfg.Backup,
+				BackupCompress: cfg.BackupCompress,
+				BackupReflink:  cfg.BackupReflink,
+				Archive:        archive,
+				Trash:          cfg.BackupToTrash,
+				ForcePerm:      cfg.ForcePerm,
+				TempDir:        cfg.TempDir,
+				TempPrefix:     cfg.TempPrefix,
+				TempSuffix:     cfg.TempSuffix,
+			}
+			aopts.ModePolicy, aopts.Mode, _ = parseModePolicy(cfg.ModePolicy) // validated in parseArgs
+			if dir := filepath.Dir(p); cfg.TempDir != "" && !crossFS[dir] && !apply.SameFilesystem(cfg.TempDir, dir) {
+				crossFS[dir] = true
+				fmt.Fprintf(stderr, "warn: --temp-dir %s is not on the filesystem of %s; staging temp files next to the targets there\n", cfg.TempDir, dir)
+			}
+			if cfg.BackupRunID {
+				aopts.BackupSuffix = "." + runID + ".bak"
+			}
+			// Reverse patches are stored as JSON strings, which cannot hold invalid
+			// UTF-8 exactly; keep a backup copy for such files instead.
+			reversible := cfg.BackupDiff && utf8.Valid(res.Before) && utf8.Valid(res.After)
+			if cfg.BackupDiff && !reversible {
+				aopts.Backup = true
+			}
+			if bits, serr := apply.SpecialBits(p); serr == nil && bits != 0 && aopts.ModePolicy == apply.ModePreserve {
+				fmt.Fprintf(stderr, "warn: %s: preserving special mode bits (%s)\n", p, describeSpecialBits(bits))
+			}
+			var backupPath string
+			_ = budget.Acquire(context.Background()) // a started write is not interrupted by --timeout
+			err := retry.Do(retryPolicy, func() (err error) {
+				backupPath, err = apply.WriteAtomicWithBackup(p, res.After, aopts)
+				return err
+			})
+			budget.Release()
+			if errors.Is(err, apply.ErrReadOnly) || errors.Is(err, apply.ErrImmutable) {
+				reason := strings.TrimPrefix(err.Error(), "apply: ")
+				fmt.Fprintf(stderr, "skip: %s: %s (use --force-perm to override)\n", p, reason)
+				events.emit(event{Event: evFileSkipped, Path: p, Reason: reason})
+				record(journal.Entry{Action: journal.ActionSkipped, Path: p, Error: reason})
+				row.Status = "skipped"
+				rows = append(rows, row)
+				skipped++
+				continue
+			}
+			if err != nil {
 				fmt.Fprintf(stderr, "error: apply %s: %v\n", p, err)
+				events.emit(event{Event: evError, Path: p, Error: err.Error()})
+				record(journal.Entry{Action: journal.ActionError, Path: p, Error: err.Error()})
 				hadErrors = true
+				row.Status = "error"
+				rows = append(rows, row)
 				continue
 			}
+			row.Status = "applied"
+			if conflicts > 0 {
+				row.Status = "conflict"
+				hadErrors = true
+			}
+			listChanged(p)
+			writeFindings(stdout, cfg.Format, p, res)
+			if cfg.Format == formatMbox {
+				series = addMboxFile(series, p, res, cfg.GroupBy)
+			}
+			if cfg.ReportCodeQuality != "" {
+				issues = append(issues, codeQualityIssues(p, res)...)
+			}
+			events.emit(event{Event: evFileApplied, Path: p, Matches: res.Matches, Replacements: res.Replacements, BeforeSHA256: beforeSum, AfterSHA256: afterSum})
+			entry := journal.Entry{
+				Action:       journal.ActionApplied,
+				Path:         p,
+				Backup:       backupPath,
+				Matches:      res.Matches,
+				Replacements: res.Replacements,
+				BeforeSHA256: beforeSum,
+				AfterSHA256:  afterSum,
+			}
+			if archive != nil {
+				entry.Backup = archive.Path()
+				entry.ArchiveMember = archive.MemberName(p)
+			}
+			if reversible {
+				rp := patch.Make(string(res.After), string(res.Before))
+				entry.ReversePatch = &rp
+			}
+			record(entry)
+			if audit != nil && isSystemPath(p) {
+				_ = audit.Notice(fmt.Sprintf("run %s: modified %s (%d replacements, backup %s)", runID, p, res.Replacements, entry.Backup))
+			}
+			if cfg.PostCheck != "" {
+				applied = append(applied, appliedFile{path: p, before: res.Before, afterSum: afterSum, row: len(rows)})
+			}
+		}
+		rows = append(rows, row)
+		completed++
+	}
+
+	if len(applied) > 0 {
+		if err := postCheck(context.Background(), cfg.PostCheck, stderr); err != nil {
+			fmt.Fprintf(stderr, "error: %v; rolling back %d file(s)\n", err, len(applied))
+			events.emit(event{Event: evError, Error: err.Error()})
+			record(journal.Entry{Action: journal.ActionError, Error: err.Error()})
+			ropts := apply.Options{ForcePerm: cfg.ForcePerm, TempDir: cfg.TempDir, TempPrefix: cfg.TempPrefix, TempSuffix: cfg.TempSuffix}
+			restored, _ := rollback(stderr, applied, ropts, record)
+			for _, i := range restored {
+				rows[i].Status = "rolled-back"
+			}
+			hadErrors = true
+		}
+	}
+
+	if cfg.SummaryTable {
+		writeSummaryTable(stdout, rows, terminalWidth(), runID)
+	} else if shown != nil {
+		writeSampleTotals(stdout, rows, len(shown))
+	}
+	if cfg.Estimate {
+		writeEstimate(stdout, estimateRun(cfg, results))
+	}
+	if cfg.Format == formatMbox {
+		writeMbox(stdout, series, cfg, inZone(time.Now(), cfg.LocalTime))
+	}
+	if cfg.ReportCodeQuality != "" {
+		if err := writeCodeQuality(cfg.ReportCodeQuality, issues); err != nil {
+			fmt.Fprintf(stderr, "error: --report-codequality: %v\n", err)
+			hadErrors = true
 		}
 	}
 
 	if discErr != nil {
 		fmt.Fprintln(stderr, discErr)
+		events.emit(event{Event: evError, Error: discErr.Error()})
+		record(journal.Entry{Action: journal.ActionError, Error: discErr.Error()})
+		hadErrors = true
+	}
+
+	if ctx.Err() != nil {
+		for _, p := range pending {
+			fmt.Fprintf(stderr, "pending: %s\n", p)
+		}
+		msg := fmt.Sprintf("timeout: run stopped after %s: %d completed, %d skipped, %d pending", cfg.Timeout, completed, skipped, len(pending))
+		fmt.Fprintln(stderr, msg)
+		events.emit(event{Event: evError, Error: msg})
+		record(journal.Entry{Action: journal.ActionError, Error: msg})
 		hadErrors = true
 	}
 
@@ -143,3 +1596,194 @@ func Run(args []string, stdout, stderr io.Writer) int {
 	}
 	return 0
 }
+
+// describeSpecialBits names the setuid/setgid/sticky bits in m, e.g. "setuid, sticky".
+func describeSpecialBits(m os.FileMode) string {
+	var names []string
+	if m&os.ModeSetuid != 0 {
+		names = append(names, "setuid")
+	}
+	if m&os.ModeSetgid != 0 {
+		names = append(names, "setgid")
+	}
+	if m&os.ModeSticky != 0 {
+		names = append(names, "sticky")
+	}
+	return strings.Join(names, ", ")
+}
+
+// compilePattern compiles the --regex pattern with compileRegex.
+func compilePattern(cfg Config) (*regexp.Regexp, error) {
+	return compileRegex(cfg, cfg.Pattern)
+}
+
+// compileRegex compiles pattern with the flags of --ignore-case (i),
+// --multiline (m) and --dotall (s).
+func compileRegex(cfg Config, pattern string) (*regexp.Regexp, error) {
+	var flags string
+	for _, f := range []struct {
+		on   bool
+		flag string
+	}{{cfg.IgnoreCase, "i"}, {cfg.Multiline, "m"}, {cfg.DotAll, "s"}} {
+		if f.on {
+			flags += f.flag
+		}
+	}
+	if flags != "" {
+		return regexp.Compile("(?" + flags + ")" + pattern)
+	}
+	return regexp.Compile(pattern)
+}
+
+func countTrue(bs ...bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// wrapWidth resolves a --wrap value: "" disables wrapping, "auto" uses the
+// terminal width, anything else must be a positive column count.
+func wrapWidth(v string) (int, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "auto":
+		return terminalWidth(), nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("--wrap: want a positive number or \"auto\", got %q", v)
+	}
+	return n, nil
+}
+
+// byteChanges converts the edits of a binary result for diff.BinarySummary.
+func byteChanges(res processor.Result) []diff.ByteChange {
+	changes := make([]diff.ByteChange, 0, len(res.Edits))
+	for _, e := range res.Edits {
+		changes = append(changes, diff.ByteChange{Offset: e.Start, Old: res.Before[e.Start:e.End], New: e.Text})
+	}
+	return changes
+}
+
+// readPatternSources fills cfg.Pattern and cfg.Replace from --pattern-stdin,
+// --pattern-file and --replace-file, which conflict with the inline flags.
+func readPatternSources(cfg *Config, fs *pflag.FlagSet, stdin io.Reader) error {
+	if n := countTrue(fs.Changed("pattern"), cfg.PatternStdin, cfg.PatternFile != ""); n > 1 {
+		return errors.New("--pattern, --pattern-stdin and --pattern-file are mutually exclusive")
+	}
+	if fs.Changed("replace") && cfg.ReplaceFile != "" {
+		return errors.New("--replace and --replace-file are mutually exclusive")
+	}
+	var err error
+	switch {
+	case cfg.PatternStdin:
+		var data []byte
+		if data, err = io.ReadAll(stdin); err != nil {
+			return fmt.Errorf("--pattern-stdin: %w", err)
+		}
+		cfg.Pattern = trimFinalNewline(string(data))
+	case cfg.PatternFile != "":
+		if cfg.Pattern, err = readPatternFile(cfg.PatternFile); err != nil {
+			return fmt.Errorf("--pattern-file: %w", err)
+		}
+	}
+	if cfg.ReplaceFile != "" {
+		if cfg.Replace, err = readPatternFile(cfg.ReplaceFile); err != nil {
+			return fmt.Errorf("--replace-file: %w", err)
+		}
+	}
+	return nil
+}
+
+// readFilesFrom appends the paths listed in --files-from to cfg.Files. Lines
+// keep their spaces; only a CR before the newline is dropped. With --null,
+// entries are split at NUL bytes and taken verbatim, so they may hold
+// newlines. Empty entries are skipped.
+func readFilesFrom(cfg *Config, stdin io.Reader) error {
+	if cfg.FilesFrom == "" {
+		if cfg.Null {
+			return errors.New("--null requires --files-from")
+		}
+		return nil
+	}
+	var data []byte
+	var err error
+	if cfg.FilesFrom == "-" {
+		if cfg.PatternStdin {
+			return errors.New("--files-from - and --pattern-stdin both read stdin")
+		}
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(cfg.FilesFrom)
+	}
+	if err != nil {
+		return fmt.Errorf("--files-from: %w", err)
+	}
+	sep := "\n"
+	if cfg.Null {
+		sep = "\x00"
+	}
+	for _, p := range strings.Split(string(data), sep) {
+		if !cfg.Null {
+			p = strings.TrimSuffix(p, "\r")
+		}
+		if p != "" {
+			cfg.Files = append(cfg.Files, p)
+		}
+	}
+	return nil
+}
+
+func readPatternFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return trimFinalNewline(string(data)), nil
+}
+
+// trimFinalNewline drops one trailing "\n" or "\r\n", as added by editors and echo.
+func trimFinalNewline(s string) string {
+	if t, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(t, "\r")
+	}
+	return s
+}
+
+// parseModePolicy maps --mode-policy to an apply.Options policy and mode.
+func parseModePolicy(s string) (string, os.FileMode, error) {
+	switch s {
+	case apply.ModePreserve, apply.ModeUmask:
+		return s, 0, nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o7777 {
+		return "", 0, fmt.Errorf("--mode-policy: want preserve, umask or an octal mode like 0644, got %q", s)
+	}
+	mode := os.FileMode(n & 0o777)
+	for bit, m := range map[uint64]os.FileMode{0o4000: os.ModeSetuid, 0o2000: os.ModeSetgid, 0o1000: os.ModeSticky} {
+		if n&bit != 0 {
+			mode |= m
+		}
+	}
+	return apply.ModeExplicit, mode, nil
+}
+
+// decodeHex parses a hex byte string such as "0xDEADBEEF" or "de ad be ef".
+func decodeHex(s string) (string, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	s = strings.Join(strings.Fields(s), "")
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(b) == 0 {
+		return "", errors.New("invalid hex: empty")
+	}
+	return string(b), nil
+}
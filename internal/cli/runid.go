@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+)
+
+// newRunID returns an identifier for one invocation. It starts with the UTC
+// start time so IDs sort chronologically, followed by random bytes to keep
+// concurrent runs apart. It is safe for use in file names.
+func newRunID(now time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:]) // crypto/rand.Read never returns an error
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:])
+}
+
+// inZone returns t in UTC, or in the local time zone with --local-time.
+func inZone(t time.Time, local bool) time.Time {
+	if local {
+		return t.Local()
+	}
+	return t.UTC()
+}
+
+// timestamp formats t as RFC 3339 for journals: UTC by default so artifacts
+// compare across machines, local time with --local-time.
+func timestamp(t time.Time, local bool) string {
+	return inZone(t, local).Format(time.RFC3339)
+}
+
+// runLog prefixes every line written to w with "[<run id>] ", so warnings
+// and errors on stderr can be matched with the journal and events of their
+// run. It is safe for concurrent use, as progress reports write from
+// another goroutine.
+type runLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	// mid is set when the last write did not end a line.
+	mid bool
+}
+
+func newRunLog(w io.Writer, runID string) *runLog {
+	return &runLog{w: w, prefix: []byte("[" + runID + "] ")}
+}
+
+func (l *runLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buf []byte
+	for rest := p; len(rest) > 0; {
+		if !l.mid {
+			buf = append(buf, l.prefix...)
+		}
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		buf = append(buf, line...)
+		rest = rest[len(line):]
+		l.mid = line[len(line)-1] != '\n'
+	}
+	if _, err := l.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
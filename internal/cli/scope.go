@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+
+	"safereplace/internal/processor"
+)
+
+// Values accepted by --scope.
+const (
+	scopeMarkdownCode  = "markdown-code"
+	scopeMarkdownProse = "markdown-prose"
+	scopeFrontMatter   = "frontmatter"
+	scopeBody          = "body"
+)
+
+// scopeFor returns the processor scope --scope selects for path p, or nil when
+// the whole file is in scope. All scopes only restrict Markdown files.
+func scopeFor(scope, p string) processor.Scope {
+	if !isMarkdown(p) {
+		return nil
+	}
+	switch scope {
+	case scopeMarkdownCode:
+		return processor.MarkdownCode
+	case scopeMarkdownProse:
+		return processor.MarkdownProse
+	case scopeFrontMatter:
+		return processor.FrontMatter
+	case scopeBody:
+		return processor.Body
+	}
+	return nil
+}
+
+func isMarkdown(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"safereplace/internal/secrets"
+)
+
+// secretSpreadFiles is the number of changed files from which a replacement
+// that looks like a credential is reported.
+const secretSpreadFiles = 2
+
+// warnSecrets reports a replacement that looks like a credential being written
+// into many files, and a pattern that looks like one (suggesting a rotation).
+func warnSecrets(w io.Writer, cfg Config, changed int) {
+	if changed == 0 || cfg.Hex {
+		return
+	}
+	if kind, ok := secrets.Detect(cfg.Replace); ok && changed >= secretSpreadFiles {
+		fmt.Fprintf(w, "warn: replacement looks like a %s and goes into %d files; consider a secret store or environment variable instead\n", kind, changed)
+	}
+	if kind, ok := secrets.Detect(cfg.Pattern); ok {
+		fmt.Fprintf(w, "warn: pattern looks like a %s; if this run rotates it, also update CI variables and secret vaults\n", kind)
+	}
+}
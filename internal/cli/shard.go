@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseShard parses --shard K/N (1 <= K <= N).
+func parseShard(s string) (k, n int, err error) {
+	ks, ns, ok := strings.Cut(s, "/")
+	k, kerr := strconv.Atoi(ks)
+	n, nerr := strconv.Atoi(ns)
+	if !ok || kerr != nil || nerr != nil || n < 1 || k < 1 || k > n {
+		return 0, 0, fmt.Errorf("--shard: want K/N with 1 <= K <= N, got %q", s)
+	}
+	return k, n, nil
+}
+
+// shardPaths keeps the paths of shard k of n. Paths are assigned by a hash of
+// their slash-separated form relative to the working directory, so every CI
+// job computes the same partition regardless of checkout location or OS.
+func shardPaths(paths []string, k, n int) []string {
+	var kept []string
+	for _, p := range paths {
+		h := fnv.New64a()
+		h.Write([]byte(filepath.ToSlash(displayPath(p))))
+		if int(h.Sum64()%uint64(n)) == k-1 {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package cli
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// shellCommand runs script with the POSIX shell.
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
+
+// shellQuote quotes s as a single word for shellCommand.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
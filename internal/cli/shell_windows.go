@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs script with cmd.exe. The command line is passed through
+// as is, since cmd.exe does not follow the usual argument quoting rules.
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /S /C "` + script + `"`}
+	return cmd
+}
+
+// shellQuote quotes s as a single word for shellCommand. Temporary file
+// paths never contain double quotes, which cmd.exe cannot escape.
+func shellQuote(s string) string {
+	return `"` + s + `"`
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// onlyChanged keeps the paths listed in changed. Both sides are compared with
+// symlinks resolved, since git reports paths below the real work tree root.
+func onlyChanged(paths, changed []string) []string {
+	set := make(map[string]bool, len(changed))
+	for _, c := range changed {
+		set[realPath(c)] = true
+	}
+	var kept []string
+	for _, p := range paths {
+		if set[realPath(p)] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func realPath(p string) string {
+	if r, err := filepath.EvalSymlinks(p); err == nil {
+		return r
+	}
+	return p
+}
+
+// parseTimeBound parses a --newer-than or --older-than value: an RFC 3339
+// timestamp, or a duration before now such as "36h" or "7d".
+func parseTimeBound(flag, s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("--%s: want a duration such as 36h or 7d, or an RFC 3339 time, got %q", flag, s)
+}
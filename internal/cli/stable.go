@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"safereplace/internal/processor"
+)
+
+// errNotStable marks files --until-stable still changed on the last pass.
+var errNotStable = errors.New("not stable")
+
+// untilStable reruns the replacement over the new content of res with again
+// until a pass changes nothing, making at most max passes in all (res being
+// the first). It returns the combined result and the replacements made by
+// each pass.
+func untilStable(res processor.Result, max int, again func([]byte) (processor.Result, error)) (processor.Result, []int, error) {
+	passes := []int{res.Replacements}
+	for {
+		next, err := again(res.After)
+		if err != nil {
+			return res, passes, err
+		}
+		if !next.Changed {
+			return res, passes, nil
+		}
+		if len(passes) == max {
+			return res, passes, fmt.Errorf("%w after %d passes: another would make %d more replacement(s)", errNotStable, max, next.Replacements)
+		}
+		passes = append(passes, next.Replacements)
+		res.After = next.After
+		res.Matches += next.Matches
+		res.Replacements += next.Replacements
+		res.Edits = nil // offsets into an intermediate content
+	}
+}
+
+// reportPasses prints, for --until-stable, the replacements each pass made
+// over all files without errors and how many files each pass changed.
+func reportPasses(w io.Writer, results []fileResult) {
+	var replacements, files []int
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		for i, n := range r.passes {
+			if i == len(replacements) {
+				replacements, files = append(replacements, 0), append(files, 0)
+			}
+			replacements[i] += n
+			files[i]++
+		}
+	}
+	for i := range replacements {
+		fmt.Fprintf(w, "until-stable: pass %d: %d replacement(s) in %d file(s)\n", i+1, replacements[i], files[i])
+	}
+	fmt.Fprintf(w, "until-stable: stable after %d pass(es)\n", len(replacements))
+}
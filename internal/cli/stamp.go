@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// stampText starts the provenance line written by --stamp; the run ID follows.
+const stampText = "modified by safereplace run "
+
+// stampComments gives the comment syntax (open, close) of the provenance line
+// per extension.
+var stampComments = map[string][2]string{
+	".go": {"// ", ""}, ".c": {"// ", ""}, ".h": {"// ", ""}, ".cc": {"// ", ""}, ".cpp": {"// ", ""},
+	".java": {"// ", ""}, ".kt": {"// ", ""}, ".swift": {"// ", ""}, ".rs": {"// ", ""}, ".cs": {"// ", ""},
+	".js": {"// ", ""}, ".jsx": {"// ", ""}, ".ts": {"// ", ""}, ".tsx": {"// ", ""}, ".scala": {"// ", ""},
+	".py": {"# ", ""}, ".rb": {"# ", ""}, ".sh": {"# ", ""}, ".bash": {"# ", ""}, ".pl": {"# ", ""},
+	".yaml": {"# ", ""}, ".yml": {"# ", ""}, ".toml": {"# ", ""}, ".conf": {"# ", ""}, ".cfg": {"# ", ""},
+	".properties": {"# ", ""}, ".env": {"# ", ""}, ".tf": {"# ", ""}, ".r": {"# ", ""},
+	".ini": {"; ", ""}, ".sql": {"-- ", ""}, ".lua": {"-- ", ""}, ".hs": {"-- ", ""},
+	".css": {"/* ", " */"}, ".scss": {"// ", ""}, ".less": {"// ", ""},
+	".html": {"<!-- ", " -->"}, ".htm": {"<!-- ", " -->"}, ".xml": {"<!-- ", " -->"}, ".md": {"<!-- ", " -->"},
+	".vim": {`" `, ""}, ".el": {";; ", ""}, ".tex": {"% ", ""}, ".erl": {"% ", ""},
+}
+
+// stamp writes the provenance line of run runID into data, the content a
+// run would write to path p: an existing line is refreshed in place,
+// otherwise one is appended in the file's line ending. It reports false when
+// no comment syntax is known for p.
+func stamp(data []byte, p, runID string) ([]byte, bool) {
+	c, ok := stampComments[strings.ToLower(filepath.Ext(p))]
+	if !ok {
+		if name := filepath.Base(p); name == "Makefile" || name == "Dockerfile" {
+			c, ok = [2]string{"# ", ""}, true
+		}
+	}
+	if !ok {
+		return data, false
+	}
+	line := []byte(c[0] + stampText + runID + c[1])
+	prefix := []byte(c[0] + stampText)
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += off
+		}
+		cur := bytes.TrimSuffix(data[off:end], []byte("\r"))
+		if bytes.HasPrefix(cur, prefix) {
+			out := append(append(bytes.Clone(data[:off]), line...), data[off+len(cur):]...)
+			return out, true
+		}
+		off = end + 1
+	}
+	eol := []byte("\n")
+	if bytes.Contains(data, []byte("\r\n")) {
+		eol = []byte("\r\n")
+	}
+	out := bytes.Clone(data)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, eol...)
+	}
+	return append(append(out, line...), eol...), true
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"safereplace/internal/classify"
+)
+
+// fileSummary is one row of the --summary-table output.
+type fileSummary struct {
+	Path         string
+	Matches      int
+	Replacements int
+	Added        int
+	Removed      int
+	Status       string
+	// Kind is the classify bucket of a changed file, empty otherwise.
+	Kind classify.Kind
+}
+
+const defaultTermWidth = 80
+
+// terminalWidth returns the width to render tables for: that of the terminal
+// on stdout, else $COLUMNS when set to a positive integer, else
+// defaultTermWidth.
+func terminalWidth() int {
+	if n, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultTermWidth
+}
+
+// displayPath shortens p to a path relative to the working directory when it lives under it.
+func displayPath(p string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(wd, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return rel
+}
+
+// writeSummaryTable renders rows as a column-aligned table followed by a totals line
+// and the run ID. The file column is truncated from the left when the table would
+// exceed width.
+func writeSummaryTable(w io.Writer, rows []fileSummary, width int, runID string) {
+	header := []string{"FILE", "MATCHES", "REPLACEMENTS", "+LINES", "-LINES", "STATUS"}
+	var total fileSummary
+	cells := make([][]string, 0, len(rows)+1)
+	for _, r := range rows {
+		total.Matches += r.Matches
+		total.Replacements += r.Replacements
+		total.Added += r.Added
+		total.Removed += r.Removed
+		cells = append(cells, []string{
+			displayPath(r.Path),
+			strconv.Itoa(r.Matches),
+			strconv.Itoa(r.Replacements),
+			strconv.Itoa(r.Added),
+			strconv.Itoa(r.Removed),
+			r.Status,
+		})
+	}
+	cells = append(cells, []string{
+		fmt.Sprintf("TOTAL (%d files)", len(rows)),
+		strconv.Itoa(total.Matches),
+		strconv.Itoa(total.Replacements),
+		strconv.Itoa(total.Added),
+		strconv.Itoa(total.Removed),
+		"",
+	})
+	for _, k := range kindTotals(rows) {
+		cells = append(cells, []string{
+			fmt.Sprintf("  %s (%d files)", k.Kind, k.files),
+			strconv.Itoa(k.Matches),
+			strconv.Itoa(k.Replacements),
+			strconv.Itoa(k.Added),
+			strconv.Itoa(k.Removed),
+			"",
+		})
+	}
+
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, row := range cells {
+		for i, c := range row {
+			if n := len([]rune(c)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	// Shrink the file column so the whole row fits, keeping room for the header.
+	const gap = 2
+	rest := 0
+	for _, wd := range widths[1:] {
+		rest += gap + wd
+	}
+	if widths[0]+rest > width {
+		widths[0] = width - rest
+		if widths[0] < len(header[0]) {
+			widths[0] = len(header[0])
+		}
+	}
+
+	writeRow := func(row []string) {
+		var b strings.Builder
+		for i, c := range row {
+			if i == 0 {
+				c = truncateLeft(c, widths[0])
+				b.WriteString(c)
+				b.WriteString(strings.Repeat(" ", widths[0]-len([]rune(c))))
+				continue
+			}
+			b.WriteString(strings.Repeat(" ", gap))
+			if i == len(row)-1 {
+				// Status is left-aligned and last, so no trailing padding.
+				b.WriteString(c)
+				continue
+			}
+			b.WriteString(strings.Repeat(" ", widths[i]-len(c)))
+			b.WriteString(c)
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+
+	writeRow(header)
+	for _, row := range cells {
+		writeRow(row)
+	}
+	fmt.Fprintf(w, "run: %s\n", runID)
+}
+
+// truncateLeft keeps the rightmost part of s (the file name end of a path) within n runes.
+func truncateLeft(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 3 {
+		return string(r[len(r)-n:])
+	}
+	return "..." + string(r[len(r)-(n-3):])
+}
+
+// writeSampleTotals follows a --sample preview with totals over all rows, so
+// the sample can be judged against the full run.
+func writeSampleTotals(w io.Writer, rows []fileSummary, shown int) {
+	var total fileSummary
+	changed := 0
+	for _, r := range rows {
+		if r.Status == "error" || r.Status == "refused" {
+			continue
+		}
+		changed++
+		total.Matches += r.Matches
+		total.Replacements += r.Replacements
+		total.Added += r.Added
+		total.Removed += r.Removed
+	}
+	fmt.Fprintf(w, "sample: previewed %d of %d changed files\n", shown, changed)
+	fmt.Fprintf(w, "total: matches: %d, replacements: %d, +%d -%d lines\n", total.Matches, total.Replacements, total.Added, total.Removed)
+	if kinds := kindTotals(rows); kinds != nil {
+		parts := make([]string, len(kinds))
+		for i, k := range kinds {
+			parts[i] = fmt.Sprintf("%s %d files +%d -%d", k.Kind, k.files, k.Added, k.Removed)
+		}
+		fmt.Fprintf(w, "by kind: %s\n", strings.Join(parts, ", "))
+	}
+}
+
+// kindTotal sums the rows of one kind of file.
+type kindTotal struct {
+	fileSummary
+	files int
+}
+
+// kindTotals sums rows by Kind, in classify.Kinds order, leaving out kinds
+// without files and rows without a kind. It returns nothing when all files
+// are of one kind, since the overall totals say it all.
+func kindTotals(rows []fileSummary) []kindTotal {
+	var totals []kindTotal
+	for _, kind := range classify.Kinds {
+		t := kindTotal{fileSummary: fileSummary{Kind: kind}}
+		for _, r := range rows {
+			if r.Kind != kind {
+				continue
+			}
+			t.files++
+			t.Matches += r.Matches
+			t.Replacements += r.Replacements
+			t.Added += r.Added
+			t.Removed += r.Removed
+		}
+		if t.files > 0 {
+			totals = append(totals, t)
+		}
+	}
+	if len(totals) < 2 {
+		return nil
+	}
+	return totals
+}
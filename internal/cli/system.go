@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// auditLog is the system log --system writes applied changes to.
+type auditLog interface {
+	Notice(msg string) error
+	Close() error
+}
+
+// isSystemPath reports whether p lies under one of systemDirs.
+func isSystemPath(p string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	for _, dir := range systemDirs() {
+		if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageOwner names the package that installed p and its package manager,
+// asking dpkg and rpm where available; it returns "" for unmanaged files.
+func packageOwner(ctx context.Context, p string) (pkg, manager string) {
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		// dpkg -S prints "package[, package]: path" for owned files.
+		if out, err := exec.CommandContext(ctx, "dpkg", "-S", p).Output(); err == nil {
+			if name, _, ok := strings.Cut(strings.TrimSpace(string(out)), ": "); ok {
+				return name, "dpkg"
+			}
+		}
+	}
+	if _, err := exec.LookPath("rpm"); err == nil {
+		if out, err := exec.CommandContext(ctx, "rpm", "-qf", p).Output(); err == nil {
+			if name := strings.TrimSpace(string(out)); name != "" {
+				return strings.Split(name, "\n")[0], "rpm"
+			}
+		}
+	}
+	return "", ""
+}
+
+// guardSystemFiles refuses changed files under system paths that belong to
+// a package, since the package manager may overwrite or conflict with local
+// edits. It returns the paths of the system files still changed.
+func guardSystemFiles(ctx context.Context, results []fileResult) (system []string) {
+	for i := range results {
+		r := &results[i]
+		if r.err != nil || r.skip != "" || !isSystemPath(r.row.Path) {
+			continue
+		}
+		if pkg, manager := packageOwner(ctx, r.row.Path); pkg != "" {
+			r.err = fmt.Errorf("managed by the %s package %s; change it through the package's configuration instead", manager, pkg)
+			r.row.Status = "refused"
+			continue
+		}
+		system = append(system, r.row.Path)
+	}
+	return system
+}
+
+// confirmSystem lists the system files about to be modified and asks for
+// "yes" on in.
+func confirmSystem(in *bufio.Reader, out io.Writer, files []string) bool {
+	fmt.Fprintf(out, "--system: about to modify %d system file(s):\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(out, "  %s\n", f)
+	}
+	fmt.Fprint(out, "Type yes to continue: ")
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line) == "yes"
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package cli
+
+import "log/syslog"
+
+// systemDirs lists the directories --system treats as system paths.
+func systemDirs() []string {
+	return []string{"/etc", "/usr", "/boot", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/opt", "/var/lib",
+		"/System", "/Library", "/private/etc"}
+}
+
+// openAuditLog connects to the local syslog daemon.
+func openAuditLog() (auditLog, error) {
+	return syslog.New(syslog.LOG_NOTICE|syslog.LOG_USER, "safereplace")
+}
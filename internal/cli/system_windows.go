@@ -0,0 +1,24 @@
+//go:build windows
+
+package cli
+
+import (
+	"cmp"
+	"errors"
+	"os"
+)
+
+// systemDirs lists the directories --system treats as system paths.
+func systemDirs() []string {
+	return []string{
+		cmp.Or(os.Getenv("SystemRoot"), `C:\Windows`),
+		cmp.Or(os.Getenv("ProgramFiles"), `C:\Program Files`),
+		cmp.Or(os.Getenv("ProgramFiles(x86)"), `C:\Program Files (x86)`),
+		cmp.Or(os.Getenv("ProgramData"), `C:\ProgramData`),
+	}
+}
+
+// openAuditLog fails: there is no syslog on Windows.
+func openAuditLog() (auditLog, error) {
+	return nil, errors.New("syslog is not available on Windows")
+}
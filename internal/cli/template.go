@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"safereplace/internal/config"
+	"safereplace/internal/processor"
+)
+
+// Values accepted by --template-guard.
+const (
+	guardWarn = "warn"
+	guardSkip = "skip"
+)
+
+// templateDelims returns the delimiters --template-guard checks for a file:
+// the config file's template-delims, else --template-delims, else the defaults.
+func templateDelims(flag []string, ov config.Overrides) []processor.Delims {
+	raw := flag
+	if ov.TemplateDelims != nil {
+		raw = ov.TemplateDelims
+	}
+	if len(raw) == 0 {
+		return processor.DefaultTemplateDelims
+	}
+	delims := make([]processor.Delims, 0, len(raw))
+	for _, r := range raw {
+		d, _ := processor.ParseDelims(r) // validated in parseArgs and config.Parse
+		delims = append(delims, d)
+	}
+	return delims
+}
+
+// guardTemplates warns about replacements inside template expressions of path p
+// and, in skip mode, drops them from res.
+func guardTemplates(w io.Writer, p string, res processor.Result, delims []processor.Delims, mode string) processor.Result {
+	spans := processor.TemplateSpans(res.Before, delims)
+	if len(spans) == 0 {
+		return res
+	}
+	var lines []string
+	for _, e := range res.Edits {
+		if e.InSpans(spans) {
+			lines = append(lines, strconv.Itoa(bytes.Count(res.Before[:e.Start], []byte("\n"))+1))
+		}
+	}
+	if len(lines) == 0 {
+		return res
+	}
+	names := make([]string, len(delims))
+	for i, d := range delims {
+		names[i] = d.String()
+	}
+	msg := fmt.Sprintf("warn: %s: %d match(es) inside template delimiters (%s) at line %s", p, len(lines), strings.Join(names, ", "), strings.Join(lines, ", "))
+	if mode != guardSkip {
+		fmt.Fprintln(w, msg)
+		return res
+	}
+	fmt.Fprintln(w, msg+"; left unchanged")
+	return processor.KeepEdits(res, func(e processor.Edit) bool { return !e.InSpans(spans) })
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"safereplace/internal/processor"
+)
+
+// wasmMagic starts every WebAssembly binary module.
+var wasmMagic = []byte("\x00asm")
+
+// newTransformer returns the transformer run per file for --transform-cmd or
+// --transform-wasm, nil when neither is set. A WASM module runs in-process
+// without preopened directories, environment or sockets, so it sees only the
+// request on stdin. close releases it.
+func newTransformer(ctx context.Context, cfg Config) (t processor.Transformer, close func(), err error) {
+	if cfg.TransformWasm == "" {
+		if argv := strings.Fields(cfg.TransformCmd); len(argv) > 0 {
+			return processor.Command(argv), func() {}, nil
+		}
+		return nil, func() {}, nil
+	}
+	code, err := os.ReadFile(cfg.TransformWasm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--transform-wasm: %w", err)
+	}
+	w, err := processor.NewWasm(ctx, code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--transform-wasm: %s: %w", cfg.TransformWasm, err)
+	}
+	return w, func() { _ = w.Close(context.Background()) }, nil
+}
+
+// checkWasm reports whether path holds a WebAssembly binary module.
+func checkWasm(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("--transform-wasm: %w", err)
+	}
+	if !bytes.HasPrefix(data, wasmMagic) {
+		return errors.New("--transform-wasm: " + path + ": not a WebAssembly module")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"safereplace/internal/apply"
+	"safereplace/internal/journal"
+)
+
+// runUndo restores the files changed by one journaled run:
+//
+//	safereplace undo --journal DIR --run ID [--dry-run] [--force] [--force-perm]
+//
+// Entries are restored newest first from whatever the run recorded: a reverse
+// patch, a run archive member, or a (possibly compressed) backup copy. Files
+// that no longer hold what the run wrote are refused, since restoring them
+// would discard later edits; --force restores backup copies anyway.
+func runUndo(args []string, stdout, stderr io.Writer) int {
+	var dir, runID string
+	var dryRun, force, forcePerm bool
+	fs := pflag.NewFlagSet("safereplace undo", pflag.ContinueOnError)
+	fs.StringVar(&dir, "journal", "", "Journal directory of the run (required)")
+	fs.StringVar(&runID, "run", "", "Run ID to undo (required)")
+	fs.BoolVar(&dryRun, "dry-run", false, "List the files that would be restored")
+	fs.BoolVar(&force, "force", false, "Restore backup copies even over files changed since the run")
+	fs.BoolVar(&forcePerm, "force-perm", false, "Temporarily lift read-only/immutable attributes to restore")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if dir == "" || runID == "" {
+		fmt.Fprintln(stderr, "undo: --journal and --run are required")
+		return 2
+	}
+
+	entries, err := journal.Read(dir, runID)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+
+	var hadErrors, restored bool
+	applied := journal.Applied(entries)
+	for i := len(applied) - 1; i >= 0; i-- {
+		e := applied[i]
+		orig, err := originalContent(e, force)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: undo %s: %v\n", e.Path, err)
+			hadErrors = true
+			continue
+		}
+		if !dryRun {
+			if err := apply.WriteAtomic(e.Path, orig, apply.Options{ForcePerm: forcePerm}); err != nil {
+				fmt.Fprintf(stderr, "error: undo %s: %v\n", e.Path, err)
+				hadErrors = true
+				continue
+			}
+		}
+		fmt.Fprintf(stdout, "restored: %s\n", e.Path)
+		restored = true
+	}
+
+	if hadErrors {
+		return 2
+	}
+	if restored {
+		return 1
+	}
+	return 0
+}
+
+// originalContent reconstructs the pre-run content of the file in e. The file
+// must still hold what the run wrote unless force is set; a reverse patch
+// cannot be applied to anything else either way.
+func originalContent(e journal.Entry, force bool) ([]byte, error) {
+	cur, err := os.ReadFile(e.Path)
+	switch {
+	case err == nil && journal.Hash(cur) == e.AfterSHA256:
+	case err != nil:
+		return nil, err
+	case force && e.ReversePatch == nil:
+		// Restore the backup over later edits.
+	case e.ReversePatch != nil:
+		return nil, errors.New("file changed since the run")
+	default:
+		return nil, errors.New("file changed since the run (use --force to restore the backup anyway)")
+	}
+	switch {
+	case e.ReversePatch != nil:
+		orig, err := e.ReversePatch.Apply(string(cur))
+		if err != nil {
+			return nil, fmt.Errorf("file changed since the run: %w", err)
+		}
+		return []byte(orig), nil
+	case e.ArchiveMember != "":
+		return apply.ReadArchiveMember(e.Backup, e.ArchiveMember)
+	case e.Backup != "":
+		return apply.ReadBackup(e.Backup)
+	}
+	return nil, errors.New("no backup recorded for this file")
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errRejected marks files whose new content --validate-cmd rejected.
+var errRejected = errors.New("rejected by --validate-cmd")
+
+// validate runs the --validate-cmd command against after, the proposed new
+// content of p, written to a temporary file with p's extension so tools that
+// go by extension recognize it. The temporary file's path replaces every {}
+// in command, or is appended when there is none. A non-zero exit status
+// rejects the change, quoting the command's output.
+func validate(ctx context.Context, command, p string, after []byte) error {
+	f, err := os.CreateTemp("", "safereplace-validate-*"+filepath.Ext(p))
+	if err != nil {
+		return fmt.Errorf("--validate-cmd: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(after)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("--validate-cmd: %w", err)
+	}
+	script := command + " " + shellQuote(f.Name())
+	if strings.Contains(command, "{}") {
+		script = strings.ReplaceAll(command, "{}", shellQuote(f.Name()))
+	}
+	var out bytes.Buffer
+	cmd := shellCommand(ctx, script)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("%w: %v: %s", errRejected, err, msg)
+		}
+		return fmt.Errorf("%w: %v", errRejected, err)
+	}
+	return nil
+}
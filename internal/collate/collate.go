@@ -0,0 +1,139 @@
+// Package collate orders strings the way people expect rather than byte-wise:
+// case and accents only break ties ("resume" < "Resume" < "résumé" < "run"). It
+// implements the untailored multilingual order for Latin text in pure Go, so
+// results are identical on every OS regardless of its locale database.
+package collate
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Collator compares strings for one locale.
+type Collator struct {
+	bytewise bool
+}
+
+// New returns the collator for locale, a POSIX locale name like "de_DE.UTF-8".
+// "C" and "POSIX" compare byte-wise; any other locale uses the multilingual
+// order (language-specific tailorings such as Swedish "å" after "z" are not
+// applied). "auto" takes the locale from LC_ALL, LC_COLLATE or LANG.
+func New(locale string) Collator {
+	if locale == "auto" {
+		locale = FromEnv()
+	}
+	name, _, _ := strings.Cut(locale, ".")
+	return Collator{bytewise: name == "" || name == "C" || name == "POSIX"}
+}
+
+// FromEnv returns the collation locale of the environment, "C" if unset.
+func FromEnv() string {
+	for _, v := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if l := os.Getenv(v); l != "" {
+			return l
+		}
+	}
+	return "C"
+}
+
+// Compare returns -1, 0 or +1 as a sorts before, equal to or after b. Strings
+// differing only in case or accents are still ordered, by accents first, then
+// case (lowercase first), then bytes, so the order is total.
+func (c Collator) Compare(a, b string) int {
+	if !c.bytewise {
+		for level := 1; level <= 3; level++ {
+			if r := compareLevel(a, b, level); r != 0 {
+				return r
+			}
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// Less reports whether a sorts before b.
+func (c Collator) Less(a, b string) bool { return c.Compare(a, b) < 0 }
+
+func compareLevel(a, b string, level int) int {
+	ra, rb := []rune(a), []rune(b)
+	for i := 0; i < len(ra) && i < len(rb); i++ {
+		ka, kb := weight(ra[i], level), weight(rb[i], level)
+		if ka != kb {
+			if ka < kb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(ra) < len(rb):
+		return -1
+	case len(ra) > len(rb):
+		return 1
+	}
+	return 0
+}
+
+// weight returns the collation weight of r at level 1 (base letter), 2
+// (accent) or 3 (case).
+func weight(r rune, level int) rune {
+	base, accented := fold(r)
+	switch level {
+	case 1:
+		return unicode.ToLower(base)
+	case 2:
+		if accented {
+			return 1
+		}
+		return 0
+	}
+	if unicode.IsUpper(r) {
+		return 1
+	}
+	return 0
+}
+
+// fold maps an accented Latin letter to its base letter.
+func fold(r rune) (rune, bool) {
+	if r < 0xC0 || r > 0x17F {
+		return r, false
+	}
+	if b, ok := latin[r]; ok {
+		if unicode.IsUpper(r) {
+			b = unicode.ToUpper(b)
+		}
+		return b, true
+	}
+	return r, false
+}
+
+// latin maps the accented letters of Latin-1 Supplement and Latin Extended-A.
+var latin = func() map[rune]rune {
+	m := make(map[rune]rune)
+	for base, letters := range map[rune]string{
+		'a': "ÀÁÂÃÄÅàáâãäåĀāĂăĄą",
+		'c': "ÇçĆćĈĉĊċČč",
+		'd': "ĎďĐđ",
+		'e': "ÈÉÊËèéêëĒēĔĕĖėĘęĚě",
+		'g': "ĜĝĞğĠġĢģ",
+		'h': "ĤĥĦħ",
+		'i': "ÌÍÎÏìíîïĨĩĪīĬĭĮįİı",
+		'j': "Ĵĵ",
+		'k': "Ķķ",
+		'l': "ĹĺĻļĽľĿŀŁł",
+		'n': "ÑñŃńŅņŇň",
+		'o': "ÒÓÔÕÖØòóôõöøŌōŎŏŐő",
+		'r': "ŔŕŖŗŘř",
+		's': "ŚśŜŝŞşŠš",
+		't': "ŢţŤťŦŧ",
+		'u': "ÙÚÛÜùúûüŨũŪūŬŭŮůŰűŲų",
+		'w': "Ŵŵ",
+		'y': "ÝýÿŶŷŸ",
+		'z': "ŹźŻżŽž",
+	} {
+		for _, r := range letters {
+			m[r] = base
+		}
+	}
+	return m
+}()
@@ -0,0 +1,36 @@
+package collate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCollator_Multilingual(t *testing.T) {
+	in := []string{"banana", "Zebra", "Äpfel", "apple", "éclair", "Apple", "eclair", "zoo"}
+	c := New("de_DE.UTF-8")
+	slices.SortFunc(in, c.Compare)
+	want := []string{"Äpfel", "apple", "Apple", "banana", "eclair", "éclair", "Zebra", "zoo"}
+	if !slices.Equal(in, want) {
+		t.Fatalf("got %q want %q", in, want)
+	}
+}
+
+func TestCollator_C(t *testing.T) {
+	in := []string{"b", "B", "a", "A"}
+	slices.SortFunc(in, New("C").Compare)
+	if want := []string{"A", "B", "a", "b"}; !slices.Equal(in, want) {
+		t.Fatalf("got %q want %q", in, want)
+	}
+}
+
+func TestNew_Auto(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_COLLATE", "POSIX")
+	if !New("auto").bytewise {
+		t.Fatal("expected byte-wise collation for LC_COLLATE=POSIX")
+	}
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+	if New("auto").bytewise {
+		t.Fatal("expected multilingual collation for LC_ALL=fr_FR.UTF-8")
+	}
+}
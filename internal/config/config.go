@@ -0,0 +1,228 @@
+// Package config reads per-file option overrides from a config file. Each rule
+// maps a glob to options, for example:
+//
+//	# Markdown: LF line endings, more context
+//	*.md: eol=lf, context=5
+//	*.min.js: skip
+//	go.mod: order=-1
+//
+// Rules may also be separated by ";" on one line. A glob without "/" matches
+// the file's base name; otherwise it matches the slash-separated path relative
+// to the working directory. All matching rules apply, later ones overriding
+// earlier ones.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultFile is loaded from the working directory when no config is given.
+const DefaultFile = ".safereplace.conf"
+
+// Overrides are per-file settings. Nil fields leave the command-line value in place.
+type Overrides struct {
+	// Skip excludes matching files from processing.
+	Skip bool
+	// EOL ("lf" or "crlf") sets the line ending newlines in the replacement are written with.
+	EOL           string
+	Context       *int
+	StrictEOL     *bool
+	Wrap          *string
+	MaxLineLength *int
+	Binary        *string
+	// TemplateDelims are "OPEN...CLOSE" pairs checked by --template-guard.
+	TemplateDelims []string
+	// Order ranks files for output and apply: lower ranks go first (default
+	// 0), e.g. to write go.mod before the .go files that depend on it.
+	Order *int
+}
+
+// Rule applies Overrides to files matching Glob.
+type Rule struct {
+	Glob      string
+	Overrides Overrides
+}
+
+// File is a parsed config file.
+type File struct {
+	Rules []Rule
+}
+
+// Load parses the config file at path.
+func Load(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, fmt.Errorf("config: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	conf, err := Parse(f)
+	if err != nil {
+		return File{}, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// Parse reads rules from r.
+func Parse(r io.Reader) (File, error) {
+	var conf File
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, text := range strings.Split(line, ";") {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			rule, err := parseRule(text)
+			if err != nil {
+				return conf, fmt.Errorf("line %d: %w", n, err)
+			}
+			conf.Rules = append(conf.Rules, rule)
+		}
+	}
+	return conf, sc.Err()
+}
+
+func parseRule(text string) (Rule, error) {
+	glob, opts, ok := strings.Cut(text, ":")
+	glob = strings.TrimSpace(glob)
+	if !ok || glob == "" {
+		return Rule{}, fmt.Errorf("want GLOB: options, got %q", strings.TrimSpace(text))
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return Rule{}, fmt.Errorf("%s: %w", glob, err)
+	}
+	rule := Rule{Glob: glob}
+	for _, opt := range strings.Split(opts, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if err := setOption(&rule.Overrides, opt); err != nil {
+			return Rule{}, fmt.Errorf("%s: %w", glob, err)
+		}
+	}
+	return rule, nil
+}
+
+func setOption(o *Overrides, opt string) error {
+	key, val, hasVal := strings.Cut(opt, "=")
+	key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+	if key == "skip" {
+		if hasVal {
+			return errors.New("skip takes no value")
+		}
+		o.Skip = true
+		return nil
+	}
+	if !hasVal {
+		return fmt.Errorf("option %q needs a value", key)
+	}
+	switch key {
+	case "eol":
+		if val != "lf" && val != "crlf" {
+			return fmt.Errorf("eol: want lf or crlf, got %q", val)
+		}
+		o.EOL = val
+	case "context", "max-line-length":
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: want a non-negative integer, got %q", key, val)
+		}
+		if key == "context" {
+			o.Context = &n
+		} else {
+			o.MaxLineLength = &n
+		}
+	case "order":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("order: want an integer, got %q", val)
+		}
+		o.Order = &n
+	case "strict-eol":
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("strict-eol: %w", err)
+		}
+		o.StrictEOL = &b
+	case "wrap":
+		if n, err := strconv.Atoi(val); val != "auto" && (err != nil || n < 0) {
+			return fmt.Errorf("wrap: want a column count or auto, got %q", val)
+		}
+		o.Wrap = &val
+	case "template-delims":
+		for _, d := range strings.Fields(val) {
+			if open, close, ok := strings.Cut(d, "..."); !ok || open == "" || close == "" {
+				return fmt.Errorf("template-delims: want OPEN...CLOSE pairs, got %q", d)
+			}
+		}
+		o.TemplateDelims = strings.Fields(val)
+	case "binary":
+		switch val {
+		case "skip", "error", "process", "force":
+		default:
+			return fmt.Errorf("binary: want skip, error or process, got %q", val)
+		}
+		o.Binary = &val
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	return nil
+}
+
+// For returns the merged overrides of all rules matching rel, a path relative
+// to the working directory.
+func (c File) For(rel string) Overrides {
+	rel = filepath.ToSlash(rel)
+	var o Overrides
+	for _, r := range c.Rules {
+		name := rel
+		if !strings.Contains(r.Glob, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(r.Glob, name); !ok {
+			continue
+		}
+		o.merge(r.Overrides)
+	}
+	return o
+}
+
+func (o *Overrides) merge(from Overrides) {
+	o.Skip = o.Skip || from.Skip
+	if from.EOL != "" {
+		o.EOL = from.EOL
+	}
+	if from.Context != nil {
+		o.Context = from.Context
+	}
+	if from.StrictEOL != nil {
+		o.StrictEOL = from.StrictEOL
+	}
+	if from.Wrap != nil {
+		o.Wrap = from.Wrap
+	}
+	if from.MaxLineLength != nil {
+		o.MaxLineLength = from.MaxLineLength
+	}
+	if from.Binary != nil {
+		o.Binary = from.Binary
+	}
+	if from.TemplateDelims != nil {
+		o.TemplateDelims = from.TemplateDelims
+	}
+	if from.Order != nil {
+		o.Order = from.Order
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAndFor(t *testing.T) {
+	conf, err := Parse(strings.NewReader(`
+# comment
+*.md: eol=lf, context=5
+*.js: wrap=auto ; *.min.js: skip
+*.vue: template-delims={{...}} [[...]]
+docs/*.md: context=1, strict-eol=true
+go.mod: order=-1
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(conf.Rules) != 6 {
+		t.Fatalf("expected 6 rules, got %d", len(conf.Rules))
+	}
+
+	md := conf.For("README.md")
+	if md.EOL != "lf" || md.Context == nil || *md.Context != 5 || md.StrictEOL != nil || md.Skip {
+		t.Fatalf("README.md: %+v", md)
+	}
+	doc := conf.For("docs/a.md")
+	if doc.Context == nil || *doc.Context != 1 || doc.StrictEOL == nil || !*doc.StrictEOL || doc.EOL != "lf" {
+		t.Fatalf("docs/a.md: later rule should override: %+v", doc)
+	}
+	if min := conf.For("web/app.min.js"); !min.Skip || min.Wrap == nil || *min.Wrap != "auto" {
+		t.Fatalf("app.min.js: %+v", min)
+	}
+	if vue := conf.For("App.vue"); len(vue.TemplateDelims) != 2 || vue.TemplateDelims[1] != "[[...]]" {
+		t.Fatalf("App.vue: %+v", vue)
+	}
+	if mod := conf.For("go.mod"); mod.Order == nil || *mod.Order != -1 {
+		t.Fatalf("go.mod: %+v", mod)
+	}
+	if none := conf.For("main.go"); !reflect.DeepEqual(none, Overrides{}) {
+		t.Fatalf("main.go: expected no overrides, got %+v", none)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"*.md",
+		"*.md: eol=cr",
+		"*.md: context=-1",
+		"*.md: colour=red",
+		"*.md: skip=true",
+		"[: skip",
+		"*.bin: binary=maybe",
+		"*.vue: template-delims={{",
+		"go.mod: order=first",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
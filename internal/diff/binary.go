@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ByteChange describes one replaced byte range of a binary file:
+// Old was found at Offset in the original and replaced by New.
+type ByteChange struct {
+	Offset int
+	Old    []byte
+	New    []byte
+}
+
+// binaryExcerpt is the maximum number of bytes shown per side of a change.
+const binaryExcerpt = 16
+
+// BinarySummary renders changes of a binary file as offset/length headers with
+// hex and printable-ASCII excerpts, since a line diff of binary content is
+// unreadable. Only Color and the labels are honored from opts.
+func BinarySummary(changes []ByteChange, opts Options) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(opts.header(""))
+	for _, c := range changes {
+		fmt.Fprintf(&b, "@ 0x%08x  len %d -> %d\n", c.Offset, len(c.Old), len(c.New))
+		writeHexRow(&b, "-", c.Old, opts.Color, false)
+		writeHexRow(&b, "+", c.New, opts.Color, true)
+	}
+	return b.String()
+}
+
+func writeHexRow(b *strings.Builder, prefix string, data []byte, color, added bool) {
+	shown, more := data, ""
+	if len(shown) > binaryExcerpt {
+		shown, more = shown[:binaryExcerpt], " …"
+	}
+	hex := make([]string, len(shown))
+	ascii := make([]byte, len(shown))
+	for i := 0; i < len(shown); i++ {
+		hex[i] = fmt.Sprintf("%02x", shown[i])
+		if shown[i] >= 0x20 && shown[i] < 0x7f {
+			ascii[i] = shown[i]
+		} else {
+			ascii[i] = '.'
+		}
+	}
+	row := fmt.Sprintf("%s %s%s  |%s|", prefix, strings.Join(hex, " "), more, ascii)
+	switch {
+	case !color:
+	case added:
+		row = "\x1b[32m" + row + "\x1b[0m"
+	default:
+		row = "\x1b[31m" + row + "\x1b[0m"
+	}
+	b.WriteString(row)
+	b.WriteByte('\n')
+}
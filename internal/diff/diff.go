@@ -1,11 +1,13 @@
 package diff
 
 import (
+	"bytes"
+	"cmp"
 	"strings"
 )
 
 // Options control how the diff output is rendered.
-// Context is reserved for future unified-diff support; currently ignored (0).
+// Context is the number of context lines DiffUnified shows; DiffBytes ignores it.
 // If Color is true, added/removed lines are wrapped with ANSI colors.
 //
 // This is a minimal, dependency-free implementation suitable for MVP.
@@ -17,22 +19,81 @@ type Options struct {
 	// StrictEOL controls whether differences in a single trailing final newline are treated as changes.
 	// When true, a difference in a lone trailing newline is reported as a change. When false (default), such differences are ignored.
 	StrictEOL bool
+	// Wrap soft-wraps rendered lines longer than Wrap runes (prefix included).
+	// Continuation rows repeat the +/- prefix followed by WrapMarker. Colors are
+	// applied per row so escape sequences are never split. 0 disables wrapping.
+	Wrap int
+	// MaxLineLength truncates changed lines longer than this many runes to an
+	// excerpt around the first difference, marked with "…". 0 disables truncation.
+	MaxLineLength int
+	// OldLabel and NewLabel name the sides in the "---" and "+++" header
+	// lines, e.g. "a/main.go" and "b/main.go" as git does. Empty means
+	// "before" and "after".
+	OldLabel string
+	NewLabel string
 }
 
+// header returns the "---" and "+++" lines naming the sides, each label
+// followed by note.
+func (o Options) header(note string) string {
+	return "--- " + cmp.Or(o.OldLabel, "before") + note + "\n+++ " + cmp.Or(o.NewLabel, "after") + note + "\n"
+}
+
+// WrapMarker starts each continuation row of a wrapped line.
+const WrapMarker = "↪"
+
 // HasChanges reports whether the inputs differ.
 func HasChanges(before, after string) bool { return before != after }
 
+// Stat returns the number of added and removed lines Diff would render for the inputs.
+// It uses the same per-line comparison as Diff, so empty lines are not counted.
+func Stat(before, after string) (added, removed int) {
+	return StatBytes([]byte(before), []byte(after))
+}
+
+// StatBytes is Stat for raw content.
+func StatBytes(before, after []byte) (added, removed int) {
+	if bytes.Equal(before, after) {
+		return 0, 0
+	}
+	bl := bytes.Split(before, nl)
+	al := bytes.Split(after, nl)
+	for i := 0; i < max(len(bl), len(al)); i++ {
+		br, ar := lineAt(bl, i), lineAt(al, i)
+		if bytes.Equal(br, ar) {
+			continue
+		}
+		if len(br) > 0 {
+			removed++
+		}
+		if len(ar) > 0 {
+			added++
+		}
+	}
+	return added, removed
+}
+
+var nl = []byte("\n")
+
+// lineAt returns lines[i], or nil past the end.
+func lineAt(lines [][]byte, i int) []byte {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return nil
+}
+
 // equalIgnoringSingleTrailingFinalNL returns true if a and b are equal, or if they differ only by a single trailing final newline.
-func equalIgnoringSingleTrailingFinalNL(a, b string) bool {
-	if a == b {
+func equalIgnoringSingleTrailingFinalNL(a, b []byte) bool {
+	if bytes.Equal(a, b) {
 		return true
 	}
 	// If exactly one side ends with a single trailing \n, consider equal when contents match without that \n.
-	if strings.HasSuffix(a, "\n") && !strings.HasSuffix(b, "\n") {
-		return strings.TrimSuffix(a, "\n") == b
+	if bytes.HasSuffix(a, nl) && !bytes.HasSuffix(b, nl) {
+		return bytes.Equal(bytes.TrimSuffix(a, nl), b)
 	}
-	if strings.HasSuffix(b, "\n") && !strings.HasSuffix(a, "\n") {
-		return strings.TrimSuffix(b, "\n") == a
+	if bytes.HasSuffix(b, nl) && !bytes.HasSuffix(a, nl) {
+		return bytes.Equal(bytes.TrimSuffix(b, nl), a)
 	}
 	return false
 }
@@ -41,11 +102,17 @@ func equalIgnoringSingleTrailingFinalNL(a, b string) bool {
 // For MVP it emits a simple per-line `---/+++` header and `-`/`+` lines when
 // corresponding lines differ. Identical lines are elided. Context is ignored.
 func Diff(before, after string, opts Options) (string, bool, error) {
+	return DiffBytes([]byte(before), []byte(after), opts)
+}
+
+// DiffBytes is Diff for raw content. Lines are compared as bytes, so content
+// with NUL bytes or invalid UTF-8 is diffed exactly; it is rendered as-is.
+func DiffBytes(before, after []byte, opts Options) (string, bool, error) {
 	// Ignore a lone trailing final newline difference by default (unless StrictEOL)
 	if !opts.StrictEOL && equalIgnoringSingleTrailingFinalNL(before, after) {
 		return "", false, nil
 	}
-	changed := before != after
+	changed := !bytes.Equal(before, after)
 	if !changed {
 		return "", false, nil
 	}
@@ -56,45 +123,91 @@ func Diff(before, after string, opts Options) (string, bool, error) {
 		ansiGreen = "\x1b[32m"
 	)
 
-	colorize := func(prefix, line string, added bool) string {
+	colorize := func(line string, added bool) string {
 		if !opts.Color {
-			return prefix + line
+			return line
 		}
 		if added {
-			return ansiGreen + prefix + line + ansiReset
+			return ansiGreen + line + ansiReset
 		}
-		return ansiRed + prefix + line + ansiReset
+		return ansiRed + line + ansiReset
 	}
 
 	var b strings.Builder
-	b.WriteString("--- before\n")
-	b.WriteString("+++ after\n")
-
-	bl := strings.Split(before, "\n")
-	al := strings.Split(after, "\n")
-	max := len(bl)
-	if len(al) > max {
-		max = len(al)
-	}
-	for i := 0; i < max; i++ {
-		var br, ar string
-		if i < len(bl) {
-			br = bl[i]
-		}
-		if i < len(al) {
-			ar = al[i]
-		}
-		if br == ar {
+	b.WriteString(opts.header(""))
+
+	bl := bytes.Split(before, nl)
+	al := bytes.Split(after, nl)
+	for i := 0; i < max(len(bl), len(al)); i++ {
+		br, ar := lineAt(bl, i), lineAt(al, i)
+		if bytes.Equal(br, ar) {
 			continue
 		}
-		if br != "" {
-			b.WriteString(colorize("-", br, false))
-			b.WriteByte('\n')
+		bs, as := string(br), string(ar)
+		if opts.MaxLineLength > 0 {
+			bs, as = excerptPair(bs, as, opts.MaxLineLength)
 		}
-		if ar != "" {
-			b.WriteString(colorize("+", ar, true))
-			b.WriteByte('\n')
+		if bs != "" {
+			for _, row := range wrapLine("-", bs, opts.Wrap) {
+				b.WriteString(colorize(row, false))
+				b.WriteByte('\n')
+			}
+		}
+		if as != "" {
+			for _, row := range wrapLine("+", as, opts.Wrap) {
+				b.WriteString(colorize(row, true))
+				b.WriteByte('\n')
+			}
 		}
 	}
 	return b.String(), true, nil
 }
+
+// wrapLine splits prefix+line into rows of at most width runes. Rows after the
+// first start with prefix+WrapMarker. Widths too small to make progress disable wrapping.
+func wrapLine(prefix, line string, width int) []string {
+	first := width - len([]rune(prefix))
+	rest := first - len([]rune(WrapMarker))
+	r := []rune(line)
+	if width <= 0 || rest < 1 || len(r) <= first {
+		return []string{prefix + line}
+	}
+	rows := []string{prefix + string(r[:first])}
+	for r = r[first:]; len(r) > 0; {
+		n := min(rest, len(r))
+		rows = append(rows, prefix+WrapMarker+string(r[:n]))
+		r = r[n:]
+	}
+	return rows
+}
+
+// excerptPair shortens a and b to at most n runes each (plus "…" markers) using
+// the same window, centered on the first rune where they differ.
+func excerptPair(a, b string, n int) (string, string) {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) <= n && len(rb) <= n {
+		return a, b
+	}
+	at := 0
+	for at < len(ra) && at < len(rb) && ra[at] == rb[at] {
+		at++
+	}
+	start := max(at-n/2, 0)
+	return excerpt(ra, start, n), excerpt(rb, start, n)
+}
+
+func excerpt(r []rune, start, n int) string {
+	if len(r) <= n {
+		return string(r)
+	}
+	start = min(start, len(r)-n)
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(string(r[start : start+n]))
+	if start+n < len(r) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
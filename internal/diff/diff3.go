@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// chunk3 is a region of a three-way comparison: base lines o[O1:O2] and the
+// corresponding lines a[A1:A2] and b[B1:B2]. Stable chunks are equal in all
+// three.
+type chunk3 struct {
+	stable         bool
+	O1, O2, A1, A2 int
+	B1, B2         int
+}
+
+// matches maps each line of o to its equal line in the other side of ops, or -1.
+func matches(ops []Op, n int) []int {
+	m := make([]int, n)
+	for i := range m {
+		m[i] = -1
+	}
+	for _, op := range ops {
+		if op.Kind == OpEqual {
+			for i := op.A1; i < op.A2; i++ {
+				m[i] = op.B1 + i - op.A1
+			}
+		}
+	}
+	return m
+}
+
+// diff3Chunks splits o, a and b into alternating stable and unstable chunks,
+// as diff3(1) does: lines of o kept by both a and b anchor stable chunks.
+func diff3Chunks(o, a, b []string) []chunk3 {
+	ma, mb := matches(LineOps(o, a), len(o)), matches(LineOps(o, b), len(o))
+	var chunks []chunk3
+	io, ia, ib := 0, 0, 0
+	for io < len(o) || ia < len(a) || ib < len(b) {
+		// Stable run: o[io] is kept in place by both sides.
+		j := io
+		for j < len(o) && ma[j] == ia+j-io && mb[j] == ib+j-io {
+			j++
+		}
+		if j > io {
+			chunks = append(chunks, chunk3{stable: true, O1: io, O2: j, A1: ia, A2: ia + j - io, B1: ib, B2: ib + j - io})
+			ia, ib, io = ia+j-io, ib+j-io, j
+			continue
+		}
+		// Unstable run up to the next line of o kept by both sides.
+		for j < len(o) && (ma[j] < 0 || mb[j] < 0) {
+			j++
+		}
+		a2, b2 := len(a), len(b)
+		if j < len(o) {
+			a2, b2 = ma[j], mb[j]
+		}
+		chunks = append(chunks, chunk3{O1: io, O2: j, A1: ia, A2: a2, B1: ib, B2: b2})
+		io, ia, ib = j, a2, b2
+	}
+	return chunks
+}
+
+// Diff3 renders the regions where ours or theirs differ from base in the
+// style of diff3 -m conflicts, each headed by its line in base:
+//
+//	@@ line 12 @@
+//	<<<<<<< ours
+//	...
+//	||||||| base
+//	...
+//	=======
+//	...
+//	>>>>>>> theirs
+//
+// Labels name the three versions. It returns "" when all three are equal.
+func Diff3(base, ours, theirs []byte, oursLabel, baseLabel, theirsLabel string) string {
+	o, a, b := splitLinesKeep(base), splitLinesKeep(ours), splitLinesKeep(theirs)
+	var w strings.Builder
+	for _, c := range diff3Chunks(o, a, b) {
+		if c.stable {
+			continue
+		}
+		fmt.Fprintf(&w, "@@ line %d @@\n", c.O1+1)
+		writeConflict(&w, a[c.A1:c.A2], o[c.O1:c.O2], b[c.B1:c.B2], oursLabel, baseLabel, theirsLabel)
+	}
+	return w.String()
+}
+
+// Merge3 merges the changes ours and theirs made to base, line by line, the
+// way diff3 -m does. Regions changed on one side take that side; regions both
+// sides changed identically are taken once; other regions are written as
+// conflict blocks with the given labels, and counted in conflicts.
+func Merge3(base, ours, theirs []byte, oursLabel, baseLabel, theirsLabel string) (merged []byte, conflicts int) {
+	o, a, b := splitLinesKeep(base), splitLinesKeep(ours), splitLinesKeep(theirs)
+	var w strings.Builder
+	for _, c := range diff3Chunks(o, a, b) {
+		oc, ac, bc := o[c.O1:c.O2], a[c.A1:c.A2], b[c.B1:c.B2]
+		switch {
+		case c.stable, slices.Equal(ac, bc), slices.Equal(oc, bc):
+			w.WriteString(strings.Join(ac, ""))
+		case slices.Equal(oc, ac):
+			w.WriteString(strings.Join(bc, ""))
+		default:
+			writeConflict(&w, ac, oc, bc, oursLabel, baseLabel, theirsLabel)
+			conflicts++
+		}
+	}
+	return []byte(w.String()), conflicts
+}
+
+// writeConflict writes one diff3-style conflict block. Lines without a
+// terminator get one so the markers stay on their own lines.
+func writeConflict(w *strings.Builder, ours, base, theirs []string, oursLabel, baseLabel, theirsLabel string) {
+	section := func(marker string, lines []string) {
+		w.WriteString(marker + "\n")
+		for _, l := range lines {
+			w.WriteString(l)
+			if !strings.HasSuffix(l, "\n") {
+				w.WriteByte('\n')
+			}
+		}
+	}
+	section("<<<<<<< "+oursLabel, ours)
+	section("||||||| "+baseLabel, base)
+	section("=======", theirs)
+	w.WriteString(">>>>>>> " + theirsLabel + "\n")
+}
@@ -0,0 +1,46 @@
+package diff
+
+import "testing"
+
+func TestDiff3(t *testing.T) {
+	base := "a\nfoo\nb\nc\nd\ne\nf\n"
+	ours := "a\nbar\nb\nc\nd\ne\nf\n"
+	theirs := "a\nfoo\nb\nc\nd\nE\nf\n"
+	want := "@@ line 2 @@\n<<<<<<< proposed\nbar\n||||||| base\nfoo\n=======\nfoo\n>>>>>>> on disk\n" +
+		"@@ line 6 @@\n<<<<<<< proposed\ne\n||||||| base\ne\n=======\nE\n>>>>>>> on disk\n"
+	if got := Diff3([]byte(base), []byte(ours), []byte(theirs), "proposed", "base", "on disk"); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := Diff3([]byte(base), []byte(base), []byte(base), "a", "b", "c"); got != "" {
+		t.Fatalf("expected empty view for equal inputs, got\n%s", got)
+	}
+}
+
+func TestDiff3_Overlap(t *testing.T) {
+	got := Diff3([]byte("x\nfoo"), []byte("x\nbar"), []byte("x\nbaz\nqux\n"), "ours", "base", "theirs")
+	want := "@@ line 2 @@\n<<<<<<< ours\nbar\n||||||| base\nfoo\n=======\nbaz\nqux\n>>>>>>> theirs\n"
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	for _, tc := range []struct {
+		name, base, ours, theirs, want string
+		conflicts                      int
+	}{
+		{"disjoint", "foo\nb\nc\nd\n", "bar\nb\nc\nd\n", "foo\nb\nc\nD\n", "bar\nb\nc\nD\n", 0},
+		{"theirs only", "foo\n", "foo\n", "x\n", "x\n", 0},
+		{"same change", "foo\n", "bar\n", "bar\n", "bar\n", 0},
+		{"insert both ends", "a\nfoo\n", "a\nbar\n", "top\na\nfoo\n", "top\na\nbar\n", 0},
+		{
+			"conflict", "a\nfoo\nz\n", "a\nbar\nz\n", "a\nbaz\nz\n",
+			"a\n<<<<<<< ours\nbar\n||||||| base\nfoo\n=======\nbaz\n>>>>>>> theirs\nz\n", 1,
+		},
+	} {
+		got, n := Merge3([]byte(tc.base), []byte(tc.ours), []byte(tc.theirs), "ours", "base", "theirs")
+		if string(got) != tc.want || n != tc.conflicts {
+			t.Errorf("%s: got %q (%d conflicts) want %q (%d)", tc.name, got, n, tc.want, tc.conflicts)
+		}
+	}
+}
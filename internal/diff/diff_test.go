@@ -88,6 +88,24 @@ func TestDiff_IncludesHeadersWhenChanged(t *testing.T) {
 	}
 }
 
+func TestDiff_Labels(t *testing.T) {
+	opts := Options{OldLabel: "a/main.go", NewLabel: "b/main.go"}
+	out, _, err := Diff("x\n", "y\n", opts)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if want := "--- a/main.go\n+++ b/main.go\n-x\n+y\n"; out != want {
+		t.Fatalf("got %q want %q", out, want)
+	}
+	out, _, _ = DiffEncoded(utf16le("x\n"), utf16le("y\n"), UTF16LE, opts)
+	if !strings.HasPrefix(out, "--- a/main.go (UTF-16LE)\n+++ b/main.go (UTF-16LE)\n-x\n") {
+		t.Fatalf("encoded: %q", out)
+	}
+	if out := BinarySummary([]ByteChange{{Offset: 0, Old: []byte("x"), New: []byte("y")}}, opts); !strings.HasPrefix(out, "--- a/main.go\n+++ b/main.go\n@") {
+		t.Fatalf("binary: %q", out)
+	}
+}
+
 func TestDiff_NoColor_HasNoANSI(t *testing.T) {
 	out, changed, err := Diff("a", "b", Options{Color: false})
 	if err != nil {
@@ -147,3 +165,91 @@ func TestDiff_ContextOption_CurrentlyIgnored(t *testing.T) {
 		t.Fatalf("Context should be ignored in MVP; outputs differ\nA:\n%s\nB:\n%s", out1, out2)
 	}
 }
+
+func TestStat_CountsChangedLines(t *testing.T) {
+	added, removed := Stat("a\nb\nc", "a\nB\nc\nd")
+	if added != 2 || removed != 1 {
+		t.Fatalf("stat: got +%d -%d, want +2 -1", added, removed)
+	}
+	added, removed = Stat("same", "same")
+	if added != 0 || removed != 0 {
+		t.Fatalf("stat on identical input: got +%d -%d", added, removed)
+	}
+}
+
+func TestDiff_WrapLongLines(t *testing.T) {
+	out, changed, err := Diff("short\nabcdefghij", "short\nABCDEFGHIJ", Options{Wrap: 6, Color: true})
+	if err != nil || !changed {
+		t.Fatalf("err=%v changed=%v", err, changed)
+	}
+	for _, want := range []string{
+		"\x1b[31m-abcde\x1b[0m\n",
+		"\x1b[31m-" + WrapMarker + "fghi\x1b[0m\n",
+		"\x1b[31m-" + WrapMarker + "j\x1b[0m\n",
+		"\x1b[32m+ABCDE\x1b[0m\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing wrapped row %q in:\n%q", want, out)
+		}
+	}
+}
+
+func TestDiff_WrapDisabledForShortLines(t *testing.T) {
+	out, _, _ := Diff("a", "b", Options{Wrap: 80})
+	if strings.Contains(out, WrapMarker) {
+		t.Fatalf("unexpected wrap marker: %q", out)
+	}
+}
+
+func TestDiff_MaxLineLength_ExcerptAroundChange(t *testing.T) {
+	before := strings.Repeat("a", 1000) + "OLD" + strings.Repeat("z", 1000)
+	after := strings.Repeat("a", 1000) + "NEW" + strings.Repeat("z", 1000)
+	out, changed, err := Diff(before, after, Options{MaxLineLength: 20})
+	if err != nil || !changed {
+		t.Fatalf("err=%v changed=%v", err, changed)
+	}
+	if want := "-…" + strings.Repeat("a", 10) + "OLD" + strings.Repeat("z", 7) + "…\n"; !strings.Contains(out, want) {
+		t.Fatalf("missing removed excerpt %q in:\n%s", want, out)
+	}
+	if want := "+…" + strings.Repeat("a", 10) + "NEW" + strings.Repeat("z", 7) + "…\n"; !strings.Contains(out, want) {
+		t.Fatalf("missing added excerpt %q in:\n%s", want, out)
+	}
+}
+
+func TestDiff_MaxLineLength_ShortLinesUntouched(t *testing.T) {
+	out, _, _ := Diff("abc", "abd", Options{MaxLineLength: 20})
+	if !strings.Contains(out, "-abc\n") || !strings.Contains(out, "+abd\n") {
+		t.Fatalf("short lines changed:\n%s", out)
+	}
+}
+
+func TestBinarySummary_OffsetsAndHex(t *testing.T) {
+	out := BinarySummary([]ByteChange{{Offset: 16, Old: []byte("\xde\xad\xbe\xef"), New: []byte("AB")}}, Options{})
+	for _, want := range []string{
+		"--- before\n+++ after\n",
+		"@ 0x00000010  len 4 -> 2\n",
+		"- de ad be ef  |....|\n",
+		"+ 41 42  |AB|\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	long := BinarySummary([]ByteChange{{Old: []byte(strings.Repeat("x", 40)), New: []byte("y")}}, Options{})
+	if !strings.Contains(long, " …  |") {
+		t.Fatalf("expected truncated excerpt:\n%s", long)
+	}
+	if BinarySummary(nil, Options{}) != "" {
+		t.Fatalf("expected empty summary for no changes")
+	}
+}
+
+func TestDiffBytes_NULContent(t *testing.T) {
+	out, changed, err := DiffBytes([]byte("a\x00b\nsame"), []byte("a\x00c\nsame"), Options{})
+	if err != nil || !changed {
+		t.Fatalf("err=%v changed=%v", err, changed)
+	}
+	if !strings.Contains(out, "-a\x00b\n") || !strings.Contains(out, "+a\x00c\n") || strings.Contains(out, "same") {
+		t.Fatalf("unexpected diff: %q", out)
+	}
+}
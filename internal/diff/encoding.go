@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings DetectEncoding recognizes besides UTF-8.
+const (
+	UTF16LE = "UTF-16LE"
+	UTF16BE = "UTF-16BE"
+)
+
+var (
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding returns the encoding declared by the byte order mark data
+// starts with, UTF16LE or UTF16BE, or "" for anything else (including UTF-8,
+// which is rendered as is).
+func DetectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE
+	}
+	return ""
+}
+
+// Decode converts data in enc to UTF-8 without its byte order mark. Unpaired
+// surrogates and a trailing odd byte become U+FFFD. Other encodings are
+// returned unchanged.
+func Decode(data []byte, enc string) []byte {
+	var order binary.ByteOrder
+	switch enc {
+	case UTF16LE:
+		order, data = binary.LittleEndian, bytes.TrimPrefix(data, bomUTF16LE)
+	case UTF16BE:
+		order, data = binary.BigEndian, bytes.TrimPrefix(data, bomUTF16BE)
+	default:
+		return data
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	out := make([]byte, 0, len(data))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	if len(data)%2 == 1 {
+		out = utf8.AppendRune(out, utf8.RuneError)
+	}
+	return out
+}
+
+// DiffEncoded is DiffBytes for content in enc: before and after are decoded
+// for display, so a UTF-16 file reads as normal text, and the encoding is
+// noted in the header.
+func DiffEncoded(before, after []byte, enc string, opts Options) (string, bool, error) {
+	out, changed, err := DiffBytes(Decode(before, enc), Decode(after, enc), opts)
+	if !changed || err != nil || enc == "" {
+		return out, changed, err
+	}
+	return opts.header(" ("+enc+")") + out[len(opts.header("")):], true, nil
+}
@@ -0,0 +1,53 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16le encodes s as UTF-16LE with a byte order mark.
+func utf16le(s string) []byte {
+	out := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u), byte(u>>8))
+	}
+	return out
+}
+
+func TestDetectEncodingAndDecode(t *testing.T) {
+	le := utf16le("héllo 𝄞\r\n")
+	be := []byte{0xFE, 0xFF, 0x00, 'h', 0x00, 'i'}
+	for _, tc := range []struct {
+		data      []byte
+		enc, text string
+	}{
+		{le, UTF16LE, "héllo 𝄞\r\n"},
+		{be, UTF16BE, "hi"},
+		{append(be, 'x'), UTF16BE, "hi�"},
+		{[]byte("\xEF\xBB\xBFplain"), "", "\xEF\xBB\xBFplain"},
+	} {
+		if got := DetectEncoding(tc.data); got != tc.enc {
+			t.Errorf("%q: encoding %q, want %q", tc.data, got, tc.enc)
+		}
+		if got := string(Decode(tc.data, tc.enc)); got != tc.text {
+			t.Errorf("%q: decoded %q, want %q", tc.data, got, tc.text)
+		}
+	}
+}
+
+func TestDiffEncoded(t *testing.T) {
+	before := utf16le("name=old\r\nport=80\r\n")
+	after := utf16le("name=new\r\nport=80\r\n")
+	out, changed, err := DiffEncoded(before, after, UTF16LE, Options{})
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	want := "--- before (UTF-16LE)\n+++ after (UTF-16LE)\n-name=old\r\n+name=new\r\n"
+	if out != want {
+		t.Fatalf("got\n%q\nwant\n%q", out, want)
+	}
+	if strings.Contains(out, "\x00") {
+		t.Fatal("raw UTF-16 bytes in the diff")
+	}
+}
@@ -0,0 +1,197 @@
+package diff
+
+// OpKind classifies a run of lines in an edit script.
+type OpKind int
+
+const (
+	OpEqual OpKind = iota
+	OpDelete
+	OpInsert
+)
+
+// Op is a run of lines of one kind. Lines a[A1:A2] are kept (OpEqual) or
+// deleted (OpDelete); lines b[B1:B2] are kept (OpEqual) or inserted (OpInsert).
+// Ranges that do not apply to a kind are empty.
+type Op struct {
+	Kind   OpKind
+	A1, A2 int
+	B1, B2 int
+}
+
+// LineOps returns a minimal edit script turning a into b, computed with
+// Myers' O(ND) algorithm after trimming the common prefix and suffix.
+// Consecutive operations of the same kind are merged.
+func LineOps(a, b []string) []Op {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var ops []Op
+	add := func(kind OpKind, a1, b1 int) {
+		a2, b2 := a1, b1
+		switch kind {
+		case OpEqual:
+			a2, b2 = a1+1, b1+1
+		case OpDelete:
+			a2 = a1 + 1
+		case OpInsert:
+			b2 = b1 + 1
+		}
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind && ops[n-1].A2 == a1 && ops[n-1].B2 == b1 {
+			ops[n-1].A2, ops[n-1].B2 = a2, b2
+			return
+		}
+		ops = append(ops, Op{Kind: kind, A1: a1, A2: a2, B1: b1, B2: b2})
+	}
+
+	for i := 0; i < pre; i++ {
+		add(OpEqual, i, i)
+	}
+	for _, e := range myers(a[pre:len(a)-suf], b[pre:len(b)-suf]) {
+		add(e.kind, e.a+pre, e.b+pre)
+	}
+	for i := 0; i < suf; i++ {
+		add(OpEqual, len(a)-suf+i, len(b)-suf+i)
+	}
+	return ops
+}
+
+type edit struct {
+	kind OpKind
+	a, b int
+}
+
+// myers returns single-line edits in order, using the linear-space variant
+// of Myers' algorithm: it finds the middle snake of an optimal path and
+// recurses on both sides, so memory stays O(N+M) however much changed. Within
+// each changed run, deletions come before insertions.
+func myers(a, b []string) []edit {
+	var out []edit
+	var rec func(a0, a1, b0, b1 int)
+	rec = func(a0, a1, b0, b1 int) {
+		for a0 < a1 && b0 < b1 && a[a0] == b[b0] {
+			out = append(out, edit{kind: OpEqual, a: a0, b: b0})
+			a0++
+			b0++
+		}
+		suf := 0
+		for a0 < a1-suf && b0 < b1-suf && a[a1-1-suf] == b[b1-1-suf] {
+			suf++
+		}
+		a1, b1 = a1-suf, b1-suf
+		switch {
+		case a0 == a1:
+			for y := b0; y < b1; y++ {
+				out = append(out, edit{kind: OpInsert, a: a0, b: y})
+			}
+		case b0 == b1:
+			for x := a0; x < a1; x++ {
+				out = append(out, edit{kind: OpDelete, a: x, b: b0})
+			}
+		default:
+			x, y, u, v := middleSnake(a[a0:a1], b[b0:b1])
+			rec(a0, a0+x, b0, b0+y)
+			for i := 0; i < u-x; i++ {
+				out = append(out, edit{kind: OpEqual, a: a0 + x + i, b: b0 + y + i})
+			}
+			rec(a0+u, a1, b0+v, b1)
+		}
+		for i := 0; i < suf; i++ {
+			out = append(out, edit{kind: OpEqual, a: a1 + i, b: b1 + i})
+		}
+	}
+	rec(0, len(a), 0, len(b))
+	return deletesFirst(out)
+}
+
+// middleSnake returns the middle snake (x, y) to (u, v) of an optimal path
+// turning a into b, both non-empty and differing in their first and last
+// lines, so that both sides of the snake are smaller problems.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	dmax := (n+m+1)/2 + 1
+	// vf[k] is the furthest x reached forward on diagonal k = x-y; vb[k] the
+	// furthest x reached backward on diagonal k of the reversed inputs.
+	off := dmax + 1
+	vf := make([]int, 2*off+1)
+	vb := make([]int, 2*off+1)
+	for d := 0; d <= dmax; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			vf[off+k] = u
+			if kr := delta - k; odd && kr >= -(d-1) && kr <= d-1 && u+vb[off+kr] >= n {
+				return x, y, u, v
+			}
+		}
+		for kr := -d; kr <= d; kr += 2 {
+			var xr int
+			if kr == -d || (kr != d && vb[off+kr-1] < vb[off+kr+1]) {
+				xr = vb[off+kr+1]
+			} else {
+				xr = vb[off+kr-1] + 1
+			}
+			yr := xr - kr
+			xr0, yr0 := xr, yr
+			for xr < n && yr < m && a[n-1-xr] == b[m-1-yr] {
+				xr++
+				yr++
+			}
+			vb[off+kr] = xr
+			if k := delta - kr; !odd && k >= -d && k <= d && vf[off+k]+xr >= n {
+				return n - xr, m - yr, n - xr0, m - yr0
+			}
+		}
+	}
+	panic("diff: no middle snake")
+}
+
+// deletesFirst reorders each run of deletions and insertions between kept
+// lines so that the deletions come first.
+func deletesFirst(edits []edit) []edit {
+	out := make([]edit, 0, len(edits))
+	for i := 0; i < len(edits); {
+		if edits[i].kind == OpEqual {
+			out = append(out, edits[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].kind != OpEqual {
+			j++
+		}
+		a, b := edits[i].a, edits[i].b
+		var dels, ins int
+		for _, e := range edits[i:j] {
+			if e.kind == OpDelete {
+				dels++
+			} else {
+				ins++
+			}
+		}
+		for x := 0; x < dels; x++ {
+			out = append(out, edit{kind: OpDelete, a: a + x, b: b})
+		}
+		for y := 0; y < ins; y++ {
+			out = append(out, edit{kind: OpInsert, a: a + dels, b: b + y})
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// applyOps rebuilds b from a and the script, checking that ranges are contiguous.
+func applyOps(t *testing.T, a, b []string, ops []Op) []string {
+	t.Helper()
+	var out []string
+	ai, bi := 0, 0
+	for _, op := range ops {
+		if op.A1 != ai || op.B1 != bi {
+			t.Fatalf("non-contiguous op %+v at a=%d b=%d", op, ai, bi)
+		}
+		switch op.Kind {
+		case OpEqual:
+			for i := op.A1; i < op.A2; i++ {
+				if a[i] != b[op.B1+i-op.A1] {
+					t.Fatalf("equal op over differing lines: %+v", op)
+				}
+				out = append(out, a[i])
+			}
+		case OpInsert:
+			out = append(out, b[op.B1:op.B2]...)
+		}
+		ai, bi = op.A2, op.B2
+	}
+	if ai != len(a) || bi != len(b) {
+		t.Fatalf("script ends at a=%d b=%d, want %d %d", ai, bi, len(a), len(b))
+	}
+	return out
+}
+
+func lcsLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestLineOps_Simple(t *testing.T) {
+	a := strings.Split("a b c d", " ")
+	b := strings.Split("a x c d e", " ")
+	ops := LineOps(a, b)
+	want := []Op{
+		{Kind: OpEqual, A1: 0, A2: 1, B1: 0, B2: 1},
+		{Kind: OpDelete, A1: 1, A2: 2, B1: 1, B2: 1},
+		{Kind: OpInsert, A1: 2, A2: 2, B1: 1, B2: 2},
+		{Kind: OpEqual, A1: 2, A2: 4, B1: 2, B2: 4},
+		{Kind: OpInsert, A1: 4, A2: 4, B1: 4, B2: 5},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("ops: got %+v want %+v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatalf("op %d: got %+v want %+v", i, ops[i], want[i])
+		}
+	}
+}
+
+func TestLineOps_RandomIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	gen := func() []string {
+		n := rng.Intn(40)
+		s := make([]string, n)
+		for i := range s {
+			s[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return s
+	}
+	for iter := 0; iter < 500; iter++ {
+		a, b := gen(), gen()
+		ops := LineOps(a, b)
+		got := applyOps(t, a, b, ops)
+		if strings.Join(got, "") != strings.Join(b, "") {
+			t.Fatalf("rebuild mismatch: a=%v b=%v got=%v", a, b, got)
+		}
+		equal := 0
+		for _, op := range ops {
+			if op.Kind == OpEqual {
+				equal += op.A2 - op.A1
+			}
+		}
+		if want := lcsLen(a, b); equal != want {
+			t.Fatalf("not minimal: a=%v b=%v kept %d want %d", a, b, equal, want)
+		}
+	}
+}
+
+func TestLineOps_LargeRewrite(t *testing.T) {
+	const n = 10000
+	a, b := make([]string, n), make([]string, n)
+	for i := range a {
+		a[i] = "old " + strconv.Itoa(i)
+		b[i] = "new " + strconv.Itoa(i)
+	}
+	ops := LineOps(a, b)
+	want := []Op{
+		{Kind: OpDelete, A1: 0, A2: n, B1: 0, B2: 0},
+		{Kind: OpInsert, A1: n, A2: n, B1: 0, B2: n},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got %+v, want %+v", ops, want)
+	}
+
+	// Every other line kept.
+	for i := 0; i < n; i += 2 {
+		b[i] = a[i]
+	}
+	got := applyOps(t, a, b, LineOps(a, b))
+	if !reflect.DeepEqual(got, b) {
+		t.Fatal("rebuild mismatch")
+	}
+}
@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Unified renders a unified diff of before and after with context lines
+// around each change, in the format patch(1) and git apply accept. The file
+// header names the sides oldName and newName. It returns "" when the inputs
+// are equal.
+func Unified(oldName, newName string, before, after []byte, context int) string {
+	a, b := splitLinesKeep(before), splitLinesKeep(after)
+	ops := LineOps(a, b)
+	var hunks [][]Op
+	var cur []Op
+	for i, op := range ops {
+		if op.Kind != OpEqual {
+			cur = append(cur, op)
+			continue
+		}
+		n := op.A2 - op.A1
+		switch {
+		case cur == nil:
+			// Leading context of the first hunk is added when it starts.
+		case i == len(ops)-1 || n > 2*context:
+			// Close the hunk with trailing context; a long run of equal lines
+			// separates hunks.
+			cur = append(cur, Op{Kind: OpEqual, A1: op.A1, A2: op.A1 + min(n, context), B1: op.B1, B2: op.B1 + min(n, context)})
+			hunks = append(hunks, cur)
+			cur = nil
+		default:
+			cur = append(cur, op)
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, cur)
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var w strings.Builder
+	fmt.Fprintf(&w, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		// Leading context: up to context equal lines before the first change.
+		first := h[0]
+		lead := min(first.A1, context)
+		if first.Kind == OpInsert {
+			lead = min(first.A1, first.B1, context)
+		}
+		a1, b1 := first.A1-lead, first.B1-lead
+		last := h[len(h)-1]
+		a2, b2 := max(last.A2, last.A1), max(last.B2, last.B1)
+		fmt.Fprintf(&w, "@@ -%s +%s @@\n", hunkRange(a1, a2-a1), hunkRange(b1, b2-b1))
+		for _, l := range a[a1:first.A1] {
+			writeUnifiedLine(&w, ' ', l)
+		}
+		for _, op := range h {
+			switch op.Kind {
+			case OpEqual:
+				for _, l := range a[op.A1:op.A2] {
+					writeUnifiedLine(&w, ' ', l)
+				}
+			case OpDelete:
+				for _, l := range a[op.A1:op.A2] {
+					writeUnifiedLine(&w, '-', l)
+				}
+			case OpInsert:
+				for _, l := range b[op.B1:op.B2] {
+					writeUnifiedLine(&w, '+', l)
+				}
+			}
+		}
+	}
+	return w.String()
+}
+
+// defaultUnifiedContext is the context DiffUnified shows when opts.Context
+// is 0, as diff -u and git do.
+const defaultUnifiedContext = 3
+
+// DiffUnified is DiffBytes rendered as an uncolored unified diff, with hunks
+// patch(1) and git apply accept, between sides named by opts.OldLabel and
+// opts.NewLabel. StrictEOL is honored; Color, Wrap and MaxLineLength are
+// not, since they would corrupt the patch.
+func DiffUnified(before, after []byte, opts Options) (string, bool, error) {
+	if !opts.StrictEOL && equalIgnoringSingleTrailingFinalNL(before, after) {
+		return "", false, nil
+	}
+	if bytes.Equal(before, after) {
+		return "", false, nil
+	}
+	context := opts.Context
+	if context == 0 {
+		context = defaultUnifiedContext
+	}
+	return Unified(cmp.Or(opts.OldLabel, "before"), cmp.Or(opts.NewLabel, "after"), before, after, context), true, nil
+}
+
+// hunkRange formats the start,count of a hunk side; empty sides point at the
+// line before them, as diff -u does.
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func writeUnifiedLine(w *strings.Builder, prefix byte, line string) {
+	w.WriteByte(prefix)
+	w.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		w.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLinesKeep splits data after each "\n", keeping terminators.
+func splitLinesKeep(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name, before, after, want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"middle", "1\n2\n3\n4\n5\n", "1\n2\nX\n4\n5\n", "@@ -1,5 +1,5 @@\n 1\n 2\n-3\n+X\n 4\n 5\n"},
+		{"append", "a\n", "a\nb\n", "@@ -1 +1,2 @@\n a\n+b\n"},
+		{"to empty", "a\n", "", "@@ -1 +0,0 @@\n-a\n"},
+		{"no newline", "a", "b", "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n"},
+		{
+			"two hunks",
+			"a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			"A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			"merged hunks",
+			"a\n1\n2\n3\n4\n5\n6\nb\n",
+			"A\n1\n2\n3\n4\n5\n6\nB\n",
+			"@@ -1,8 +1,8 @@\n-a\n+A\n 1\n 2\n 3\n 4\n 5\n 6\n-b\n+B\n",
+		},
+	} {
+		got := Unified("a/f", "b/f", []byte(tc.before), []byte(tc.after), 3)
+		if tc.want != "" {
+			tc.want = "--- a/f\n+++ b/f\n" + tc.want
+		}
+		if got != tc.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestUnified_MatchesLineCounts(t *testing.T) {
+	before := strings.Repeat("x\n", 20) + "y\n" + strings.Repeat("x\n", 20)
+	after := strings.Replace(before, "y\n", "z\nz\n", 1)
+	got := Unified("a", "b", []byte(before), []byte(after), 3)
+	if !strings.Contains(got, "@@ -18,7 +18,8 @@\n") {
+		t.Fatalf("unexpected header:\n%s", got)
+	}
+}
+
+func TestDiffUnified(t *testing.T) {
+	opts := Options{OldLabel: "a/x.txt", NewLabel: "b/x.txt", Color: true}
+	out, changed, err := DiffUnified([]byte("a\nb\n"), []byte("a\nc\n"), opts)
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	if want := "--- a/x.txt\n+++ b/x.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"; out != want {
+		t.Fatalf("got %q want %q", out, want)
+	}
+	if _, changed, _ := DiffUnified([]byte("a\n"), []byte("a"), opts); changed {
+		t.Fatal("lone trailing newline reported without StrictEOL")
+	}
+}
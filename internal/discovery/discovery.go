@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -8,28 +9,48 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 // Selector defines how files are selected for processing.
-// Provide at least one of Glob, Ext, or Files.
-// Ext should be provided without a leading dot (e.g., "txt").
+// Provide at least one of Glob, Ext, or Files; the files every pattern
+// selects are combined.
+// Ext lists extensions, with or without a leading dot (e.g., "txt").
 type Selector struct {
-	Glob    string
-	Ext     string
+	Glob    []string
+	Ext     []string
 	Files   []string
 	Exclude []string
+	// FollowSymlinks resolves symlinks instead of skipping them: links to
+	// files select their targets and links to directories are searched, each
+	// directory once so link cycles end. Excludes match the link paths; the
+	// paths returned are resolved, so a file reached through several links
+	// is returned once.
+	FollowSymlinks bool
+	// NewerThan and OlderThan, when set, keep only files last modified
+	// after or before them.
+	NewerThan time.Time
+	OlderThan time.Time
 }
 
 // Discover returns absolute, deduplicated, sorted paths to regular files under root
-// according to the selector. It performs no I/O beyond file system queries,
-// prints nothing, and is deterministic in its output ordering.
+// according to the selector. FIFOs, sockets and devices named in Files are
+// returned too, so callers can refuse them visibly instead of dropping them.
+// It performs no I/O beyond file system queries, prints nothing, and is
+// deterministic in its output ordering.
 func Discover(root string, sel Selector) ([]string, error) {
+	return DiscoverContext(context.Background(), root, sel)
+}
+
+// DiscoverContext is like Discover but stops walking directories once ctx is
+// done. The paths found so far are returned together with ctx's error.
+func DiscoverContext(ctx context.Context, root string, sel Selector) ([]string, error) {
 	normRoot, normSel, err := normalize(root, sel)
 	if err != nil {
 		return nil, err
 	}
 
-	if normSel.Glob == "" && normSel.Ext == "" && len(normSel.Files) == 0 {
+	if len(normSel.Glob) == 0 && len(normSel.Ext) == 0 && len(normSel.Files) == 0 {
 		return nil, errors.New("discovery: at least one of --glob, --ext or --files must be provided")
 	}
 
@@ -38,16 +59,16 @@ func Discover(root string, sel Selector) ([]string, error) {
 
 	// Expand explicit files first
 	if len(normSel.Files) > 0 {
-		paths, ferrs := expandFiles(normRoot, normSel.Files)
+		paths, ferrs := expandFiles(normRoot, normSel.Files, normSel.FollowSymlinks)
 		for _, p := range paths {
 			resultSet[p] = struct{}{}
 		}
 		errs = append(errs, ferrs...)
 	}
 
-	// Expand glob
-	if normSel.Glob != "" {
-		paths, gerrs := expandGlob(normRoot, normSel.Glob)
+	// Expand globs
+	for _, g := range normSel.Glob {
+		paths, gerrs := expandGlob(ctx, normRoot, g, normSel.FollowSymlinks)
 		for _, p := range paths {
 			resultSet[p] = struct{}{}
 		}
@@ -55,8 +76,8 @@ func Discover(root string, sel Selector) ([]string, error) {
 	}
 
 	// Expand by extension walk
-	if normSel.Ext != "" {
-		paths, werrs := expandExt(normRoot, normSel.Ext)
+	if len(normSel.Ext) > 0 {
+		paths, werrs := expandExt(ctx, normRoot, normSel.Ext, normSel.FollowSymlinks)
 		for _, p := range paths {
 			resultSet[p] = struct{}{}
 		}
@@ -73,10 +94,20 @@ func Discover(root string, sel Selector) ([]string, error) {
 	if len(normSel.Exclude) > 0 && len(paths) > 0 {
 		paths = applyExcludes(normRoot, paths, normSel.Exclude)
 	}
+	if !normSel.NewerThan.IsZero() || !normSel.OlderThan.IsZero() {
+		paths = filterModTime(paths, normSel.NewerThan, normSel.OlderThan)
+	}
+	if normSel.FollowSymlinks {
+		paths = resolveLinks(paths)
+	}
 
 	// Deterministic order
 	sort.Strings(paths)
 
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("discovery: %w", err))
+	}
+
 	// Join errors (non-fatal during discovery)
 	if len(errs) > 0 {
 		return paths, errors.Join(errs...)
@@ -84,6 +115,44 @@ func Discover(root string, sel Selector) ([]string, error) {
 	return paths, nil
 }
 
+// DiscoverRoots is DiscoverContext over several roots, with the results
+// merged, deduplicated and sorted. Glob, Ext and Exclude apply under each
+// root; Files are resolved against the working directory once. No roots
+// means the working directory.
+func DiscoverRoots(ctx context.Context, roots []string, sel Selector) ([]string, error) {
+	if len(roots) == 0 {
+		return DiscoverContext(ctx, ".", sel)
+	}
+	seen := make(map[string]struct{})
+	var paths []string
+	var errs []error
+	add := func(found []string, err error) {
+		for _, p := range found {
+			if _, ok := seen[p]; !ok {
+				seen[p] = struct{}{}
+				paths = append(paths, p)
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(sel.Files) > 0 {
+		files := sel
+		files.Glob, files.Ext = nil, nil
+		add(DiscoverContext(ctx, ".", files))
+	}
+	if len(sel.Glob) > 0 || len(sel.Ext) > 0 {
+		walked := sel
+		walked.Files = nil
+		for _, root := range roots {
+			add(DiscoverContext(ctx, root, walked))
+		}
+	}
+	sort.Strings(paths)
+	return paths, errors.Join(errs...)
+}
+
 // --- internals ---
 
 func normalize(root string, sel Selector) (string, Selector, error) {
@@ -95,13 +164,25 @@ func normalize(root string, sel Selector) (string, Selector, error) {
 		return "", sel, fmt.Errorf("discovery: invalid root: %w", err)
 	}
 
-	sel.Ext = strings.TrimPrefix(sel.Ext, ".")
+	exts := make([]string, 0, len(sel.Ext))
+	for _, e := range sel.Ext {
+		if e = strings.TrimPrefix(e, "."); e != "" {
+			exts = append(exts, e)
+		}
+	}
+	sel.Ext = exts
 	// Leave Glob and Exclude as-is (may be relative to root)
 	return absRoot, sel, nil
 }
 
-func isRegular(path string) bool {
-	info, err := os.Lstat(path)
+// isRegular reports whether path is a regular file; with follow, a symlink
+// to one counts.
+func isRegular(path string, follow bool) bool {
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+	info, err := stat(path)
 	if err != nil {
 		return false
 	}
@@ -123,7 +204,7 @@ func toAbsUnderRoot(root, p string) (string, error) {
 	return abs, nil
 }
 
-func expandFiles(root string, files []string) ([]string, []error) {
+func expandFiles(root string, files []string, follow bool) ([]string, []error) {
 	var out []string
 	var errs []error
 	for _, f := range files {
@@ -132,19 +213,28 @@ func expandFiles(root string, files []string) ([]string, []error) {
 			errs = append(errs, fmt.Errorf("files: %s: %w", f, err))
 			continue
 		}
-		if isRegular(abs) {
+		if isRegular(abs, follow) || isSpecial(abs) {
 			out = append(out, abs)
 		}
 	}
 	return out, errs
 }
 
-func expandGlob(root, pattern string) ([]string, []error) {
+// isSpecial reports whether path is a FIFO, socket or device (not a symlink to one).
+func isSpecial(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&(fs.ModeNamedPipe|fs.ModeSocket|fs.ModeDevice|fs.ModeCharDevice) != 0
+}
+
+func expandGlob(ctx context.Context, root, pattern string, follow bool) ([]string, []error) {
 	var errs []error
 	// If the pattern is not absolute, make it relative to root.
 	if !filepath.IsAbs(pattern) {
 		pattern = filepath.Join(root, pattern)
 	}
+	if strings.Contains(pattern, "**") {
+		return expandDoublestar(ctx, pattern, follow)
+	}
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
 		// Bad pattern is a hard error for this branch, but we keep going elsewhere.
@@ -153,7 +243,7 @@ func expandGlob(root, pattern string) ([]string, []error) {
 	}
 	out := make([]string, 0, len(matches))
 	for _, m := range matches {
-		if isRegular(m) {
+		if isRegular(m, follow) {
 			abs, _ := filepath.Abs(m) // Abs should succeed for Glob results
 			out = append(out, abs)
 		}
@@ -161,11 +251,101 @@ func expandGlob(root, pattern string) ([]string, []error) {
 	return out, errs
 }
 
-func expandExt(root, ext string) ([]string, []error) {
+// expandDoublestar matches an absolute pattern in which a "**" path segment
+// stands for any number of directories, including none: "src/**/*.yaml"
+// matches src/a.yaml and src/x/y/b.yaml. The tree is walked from the
+// pattern's leading segments without wildcards, skipping directories that
+// cannot lead to a match.
+func expandDoublestar(ctx context.Context, pattern string, follow bool) ([]string, []error) {
+	segs := strings.Split(filepath.ToSlash(pattern), "/")
+	n := 0
+	for n < len(segs)-1 && !hasMeta(segs[n]) {
+		n++
+	}
+	for _, seg := range segs[n:] {
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return nil, []error{fmt.Errorf("glob: %w", err)}
+		}
+	}
+	// The trailing slash keeps a bare root ("/" or "C:/") absolute.
+	base := filepath.Clean(filepath.FromSlash(strings.Join(segs[:n], "/") + "/"))
+	var out []string
+	var errs []error
+	walkFn := func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) || path != base {
+				errs = append(errs, fmt.Errorf("walk: %s: %w", path, err))
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(base, path)
+		var name []string
+		if rel != "." {
+			name = strings.Split(filepath.ToSlash(rel), "/")
+		}
+		if d.IsDir() {
+			if !matchSegments(segs[n:], name, true) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && matchSegments(segs[n:], name, false) {
+			out = append(out, path)
+		}
+		return nil
+	}
+	_ = walk(base, follow, walkFn)
+	return out, errs
+}
+
+// hasMeta reports whether a path segment contains wildcard characters.
+func hasMeta(seg string) bool {
+	return strings.ContainsAny(seg, `*?[\`)
+}
+
+// matchSegments reports whether the path segments name match the pattern
+// segments pat, a "**" segment matching any number of segments. With prefix
+// set, it reports whether name, a directory, may contain a match.
+func matchSegments(pat, name []string, prefix bool) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if prefix {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:], false) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return prefix
+		}
+		if !match(pat[0], name[0]) {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandExt walks root once, collecting regular files with any of the
+// extensions exts (compared case-insensitively).
+func expandExt(ctx context.Context, root string, exts []string, follow bool) ([]string, []error) {
 	var out []string
 	var errs []error
-	target := strings.ToLower(strings.TrimPrefix(ext, "."))
+	targets := make(map[string]bool, len(exts))
+	for _, e := range exts {
+		targets[strings.ToLower(strings.TrimPrefix(e, "."))] = true
+	}
 	walkFn := func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
 		if err != nil {
 			// Collect and continue
 			errs = append(errs, fmt.Errorf("walk: %s: %w", path, err))
@@ -174,7 +354,7 @@ func expandExt(root, ext string) ([]string, []error) {
 		if !d.Type().IsRegular() {
 			return nil
 		}
-		if strings.EqualFold(strings.TrimPrefix(filepath.Ext(path), "."), target) {
+		if targets[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))] {
 			abs, aerr := filepath.Abs(path)
 			if aerr != nil {
 				errs = append(errs, fmt.Errorf("abs: %s: %w", path, aerr))
@@ -184,10 +364,107 @@ func expandExt(root, ext string) ([]string, []error) {
 		}
 		return nil
 	}
-	_ = filepath.WalkDir(root, walkFn)
+	_ = walk(root, follow, walkFn)
 	return out, errs
 }
 
+// walk calls fn for root and everything below it, like filepath.WalkDir.
+// With follow, symlinks are resolved: a link to a file is passed to fn as
+// that file, and a link to a directory is descended into. Each directory is
+// entered once, by its resolved path, so link cycles end. Dangling links are
+// skipped.
+func walk(root string, follow bool, fn fs.WalkDirFunc) error {
+	if !follow {
+		return filepath.WalkDir(root, fn)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFollow(root, fs.FileInfoToDirEntry(info), map[string]bool{}, fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkFollow(path string, d fs.DirEntry, visited map[string]bool, fn fs.WalkDirFunc) error {
+	if !d.IsDir() {
+		return fn(path, d, nil)
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		if visited[real] {
+			return nil
+		}
+		visited[real] = true
+	}
+	if err := fn(path, d, nil); err != nil {
+		if errors.Is(err, fs.SkipDir) {
+			return nil
+		}
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := fn(path, d, err); err != nil && !errors.Is(err, fs.SkipDir) {
+			return err
+		}
+		return nil
+	}
+	for _, e := range entries {
+		p := filepath.Join(path, e.Name())
+		if e.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			e = fs.FileInfoToDirEntry(info)
+		}
+		if err := walkFollow(p, e, visited, fn); err != nil {
+			if errors.Is(err, fs.SkipDir) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// filterModTime keeps the paths last modified after newer and before older,
+// either of which may be zero for no bound. Paths that cannot be stat'ed are
+// kept, so reading them reports the error.
+func filterModTime(paths []string, newer, older time.Time) []string {
+	out := paths[:0]
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			mod := info.ModTime()
+			if !newer.IsZero() && !mod.After(newer) || !older.IsZero() && !mod.Before(older) {
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// resolveLinks replaces paths by their resolved form, dropping duplicates.
+// Paths that cannot be resolved are kept as they are.
+func resolveLinks(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	out := paths[:0]
+	for _, p := range paths {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 func applyExcludes(root string, paths []string, excludes []string) []string {
 	filtered := make([]string, 0, len(paths))
 nextPath:
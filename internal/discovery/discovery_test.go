@@ -1,12 +1,15 @@
 package discovery
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"sort"
 	"testing"
+	"time"
 )
 
 func writeFile(t *testing.T, dir, rel, content string) string {
@@ -41,7 +44,7 @@ func TestDiscover_ByExtRecursive(t *testing.T) {
 	d := writeFile(t, root, "sub/d.txt", "q") // nested
 	_ = writeFile(t, root, "sub/e.md", "w")
 
-	got, err := Discover(root, Selector{Ext: "txt"})
+	got, err := Discover(root, Selector{Ext: []string{"txt"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -52,6 +55,38 @@ func TestDiscover_ByExtRecursive(t *testing.T) {
 	}
 }
 
+func TestDiscover_MultipleExts(t *testing.T) {
+	root := t.TempDir()
+	g := writeFile(t, root, "a.go", "x")
+	m := writeFile(t, root, "sub/b.MD", "y")
+	_ = writeFile(t, root, "c.txt", "z")
+
+	got, err := Discover(root, Selector{Ext: []string{"go", ".md"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{g, m}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestDiscoverRoots(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	c := writeFile(t, root, "cmd/main.go", "x")
+	i := writeFile(t, root, "internal/x/x.go", "y")
+	_ = writeFile(t, root, "other/o.go", "z")
+	r := writeFile(t, root, "README.md", "w")
+
+	got, err := DiscoverRoots(context.Background(), []string{"cmd", "internal", "./cmd"}, Selector{Ext: []string{"go"}, Files: []string{"README.md"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{r, c, i}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
 func TestDiscover_ByGlob(t *testing.T) {
 	root := t.TempDir()
 	_ = writeFile(t, root, "a.txt", "x")
@@ -59,7 +94,7 @@ func TestDiscover_ByGlob(t *testing.T) {
 	e := writeFile(t, root, "sub/e.md", "w")
 
 	// Non-recursive glob from root: only *.md at sub/ level when pattern includes sub/
-	got, err := Discover(root, Selector{Glob: filepath.Join("sub", "*.md")})
+	got, err := Discover(root, Selector{Glob: []string{filepath.Join("sub", "*.md")}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -69,6 +104,53 @@ func TestDiscover_ByGlob(t *testing.T) {
 	}
 }
 
+func TestDiscover_MultipleGlobs(t *testing.T) {
+	root := t.TempDir()
+	a := writeFile(t, root, "a.go", "x")
+	m := writeFile(t, root, "go.mod", "y")
+	_ = writeFile(t, root, "b.txt", "z")
+
+	got, err := Discover(root, Selector{Glob: []string{"*.go", "*.mod", "a.*"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{a, m}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestDiscover_DoubleStarGlob(t *testing.T) {
+	root := t.TempDir()
+	a := writeFile(t, root, "src/a.yaml", "x")
+	b := writeFile(t, root, "src/x/y/b.yaml", "y")
+	_ = writeFile(t, root, "src/x/c.txt", "z")
+	c := writeFile(t, root, "other/c.yaml", "w")
+
+	got, err := Discover(root, Selector{Glob: []string{"src/**/*.yaml"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("src/**/*.yaml: got %v want %v", got, want)
+	}
+
+	got, err = Discover(root, Selector{Glob: []string{"**/*.yaml"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{c, a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("**/*.yaml: got %v want %v", got, want)
+	}
+
+	got, err = Discover(root, Selector{Glob: []string{"missing/**/*.yaml"}})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("missing base: got %v, %v", got, err)
+	}
+	if _, err := Discover(root, Selector{Glob: []string{"src/**/[.yaml"}}); err == nil {
+		t.Fatal("expected error for bad pattern")
+	}
+}
+
 func TestDiscover_FilesAndDedupAndExclude(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, "a.txt", "x")
@@ -78,7 +160,7 @@ func TestDiscover_FilesAndDedupAndExclude(t *testing.T) {
 
 	// Mix explicit files + ext. Exclude b.txt and everything under sub/
 	sel := Selector{
-		Ext:     "txt",
+		Ext:     []string{"txt"},
 		Files:   []string{"a.txt", "sub/keep.md"}, // a.txt will be deduped; keep.md will be excluded
 		Exclude: []string{"b.*", "sub/*"},
 	}
@@ -103,7 +185,7 @@ func TestDiscover_IgnoresNonRegular(t *testing.T) {
 		t.Fatalf("mkdir: %v", err)
 	}
 
-	got, err := Discover(root, Selector{Ext: "txt"})
+	got, err := Discover(root, Selector{Ext: []string{"txt"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -134,7 +216,7 @@ func TestDiscover_IgnoresNonRegular(t *testing.T) {
 func TestDiscover_NoMatches_ReturnsEmpty(t *testing.T) {
 	root := t.TempDir()
 	_ = writeFile(t, root, "a.txt", "x")
-	got, err := Discover(root, Selector{Ext: "md"})
+	got, err := Discover(root, Selector{Ext: []string{"md"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -146,7 +228,7 @@ func TestDiscover_NoMatches_ReturnsEmpty(t *testing.T) {
 func TestDiscover_ExtWithDot_Normalizes(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, "a.txt", "x")
-	got, err := Discover(root, Selector{Ext: ".txt"})
+	got, err := Discover(root, Selector{Ext: []string{".txt"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -159,7 +241,7 @@ func TestDiscover_InvalidGlob_joinsErrorButKeepsOtherResults(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, "a.txt", "x")
 	// Invalid pattern (per filepath.Glob rules) to force an error
-	sel := Selector{Glob: "[", Files: []string{"a.txt"}}
+	sel := Selector{Glob: []string{"["}, Files: []string{"a.txt"}}
 	got, err := Discover(root, sel)
 	if err == nil {
 		t.Fatalf("expected joined error from invalid glob, got nil")
@@ -185,7 +267,7 @@ func TestDiscover_AbsoluteGlob(t *testing.T) {
 	root := t.TempDir()
 	f := writeFile(t, root, "a.txt", "x")
 	absGlob := f // exact absolute path acts like a glob match
-	got, err := Discover(root, Selector{Glob: absGlob})
+	got, err := Discover(root, Selector{Glob: []string{absGlob}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -198,7 +280,7 @@ func TestDiscover_AbsoluteExclude(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, "a.txt", "x")
 	b := writeFile(t, root, "b.txt", "y")
-	got, err := Discover(root, Selector{Ext: "txt", Exclude: []string{a}})
+	got, err := Discover(root, Selector{Ext: []string{"txt"}, Exclude: []string{a}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -229,7 +311,7 @@ func TestDiscover_RootEmpty_NormalizesToDot(t *testing.T) {
 func TestDiscover_DedupAcrossSelectors(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, "a.txt", "x")
-	got, err := Discover(root, Selector{Ext: "txt", Files: []string{"a.txt"}, Glob: "*.txt"})
+	got, err := Discover(root, Selector{Ext: []string{"txt"}, Files: []string{"a.txt"}, Glob: []string{"*.txt"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -253,7 +335,7 @@ func TestDiscover_WalkDirPermissionError_Joined(t *testing.T) {
 		t.Fatalf("chmod: %v", err)
 	}
 	t.Cleanup(func() { _ = os.Chmod(denied, 0o700) })
-	_, err := Discover(root, Selector{Ext: "txt"})
+	_, err := Discover(root, Selector{Ext: []string{"txt"}})
 	if err == nil {
 		t.Fatalf("expected joined error from WalkDir, got nil")
 	}
@@ -262,7 +344,7 @@ func TestDiscover_WalkDirPermissionError_Joined(t *testing.T) {
 func TestDiscover_HiddenFilesByExt(t *testing.T) {
 	root := t.TempDir()
 	a := writeFile(t, root, ".env", "x")
-	got, err := Discover(root, Selector{Ext: "env"})
+	got, err := Discover(root, Selector{Ext: []string{"env"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -281,7 +363,7 @@ func TestDiscover_SymlinkIgnored_InGlobAndExt(t *testing.T) {
 		}
 	}
 	// Ext should include only real, not symlink
-	gotExt, err := Discover(root, Selector{Ext: "txt"})
+	gotExt, err := Discover(root, Selector{Ext: []string{"txt"}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -289,7 +371,7 @@ func TestDiscover_SymlinkIgnored_InGlobAndExt(t *testing.T) {
 		t.Fatalf("ext with symlink: got %v want [%s]", gotExt, real)
 	}
 	// Glob matching both names should still filter out symlink
-	gotGlob, err := Discover(root, Selector{Glob: filepath.Join(root, "*.txt")})
+	gotGlob, err := Discover(root, Selector{Glob: []string{filepath.Join(root, "*.txt")}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -297,3 +379,104 @@ func TestDiscover_SymlinkIgnored_InGlobAndExt(t *testing.T) {
 		t.Fatalf("glob with symlink: got %v want [%s]", gotGlob, real)
 	}
 }
+
+func TestDiscover_FollowSymlinks(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := writeFile(t, base, "shared/util.txt", "x")
+	root := filepath.Join(base, "repo")
+	own := writeFile(t, root, "own.txt", "y")
+	links := map[string]string{
+		filepath.Join(root, "shared"):         filepath.Join(base, "shared"),
+		filepath.Join(root, "again"):          filepath.Join(base, "shared"),
+		filepath.Join(root, "link.txt"):       own,
+		filepath.Join(base, "shared", "loop"): root,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlink unsupported here: %v", err)
+		}
+	}
+	want := []string{own, shared}
+
+	// Linked directories are searched once each despite the loop back to
+	// root, and each file is returned once at its resolved path.
+	got, err := Discover(root, Selector{Ext: []string{"txt"}, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ext: got %v want %v", got, want)
+	}
+	got, err = Discover(root, Selector{Glob: []string{"**/*.txt"}, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("glob: got %v want %v", got, want)
+	}
+	got, err = Discover(root, Selector{Files: []string{"link.txt"}, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{own}) {
+		t.Fatalf("files: got %v want [%s]", got, own)
+	}
+
+	// Excludes match the link paths.
+	got, err = Discover(root, Selector{Ext: []string{"txt"}, Exclude: []string{"shared/*", "again/*"}, FollowSymlinks: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{own}) {
+		t.Fatalf("exclude: got %v want [%s]", got, own)
+	}
+}
+
+func TestDiscover_ModTime(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	old := writeFile(t, root, "old.txt", "x")
+	mid := writeFile(t, root, "mid.txt", "x")
+	recent := writeFile(t, root, "recent.txt", "x")
+	for p, age := range map[string]time.Duration{old: 72 * time.Hour, mid: 24 * time.Hour, recent: time.Minute} {
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		newer, older time.Time
+		want         []string
+	}{
+		{newer: now.Add(-time.Hour), want: []string{recent}},
+		{older: now.Add(-48 * time.Hour), want: []string{old}},
+		{newer: now.Add(-48 * time.Hour), older: now.Add(-time.Hour), want: []string{mid}},
+	}
+	for _, c := range cases {
+		got, err := Discover(root, Selector{Ext: []string{"txt"}, NewerThan: c.newer, OlderThan: c.older})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("newer %v, older %v: got %v want %v", c.newer, c.older, got, c.want)
+		}
+	}
+}
+
+func TestDiscoverContext_CancelledStopsWalk(t *testing.T) {
+	root := t.TempDir()
+	_ = writeFile(t, root, "a.txt", "x")
+	_ = writeFile(t, root, "sub/b.txt", "y")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := DiscoverContext(ctx, root, Selector{Ext: []string{"txt"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no paths after cancellation, got %v", got)
+	}
+}
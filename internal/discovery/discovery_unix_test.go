@@ -0,0 +1,28 @@
+//go:build unix
+
+package discovery
+
+import (
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestDiscover_ExplicitFIFOReturned(t *testing.T) {
+	root := t.TempDir()
+	fifo := filepath.Join(root, "pipe.txt")
+	if err := syscall.Mkfifo(fifo, 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	// Named explicitly: returned so the caller can refuse it visibly.
+	got, err := Discover(root, Selector{Files: []string{"pipe.txt"}})
+	if err != nil || !reflect.DeepEqual(got, []string{fifo}) {
+		t.Fatalf("files: got %v, %v", got, err)
+	}
+	// Found by a walk: skipped like any other non-regular file.
+	got, err = Discover(root, Selector{Ext: []string{"txt"}})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("ext: got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package fdlimit keeps a run within a budget of open file descriptors, so
+// processing many files at once on a machine with a low ulimit queues up
+// instead of failing with EMFILE.
+package fdlimit
+
+import (
+	"context"
+	"errors"
+)
+
+// PerFile is the number of descriptors one file can hold while it is
+// processed and applied: the original, its backup copy and the temp file.
+const PerFile = 3
+
+// reserve is kept free for stdio, the journal, the event stream and profiles.
+const reserve = 16
+
+// ErrTooLow is returned (wrapped) by New for budgets that cannot fit one file.
+var ErrTooLow = errors.New("budget below the descriptors needed for one file")
+
+// Budget hands out slots of PerFile descriptors.
+type Budget struct {
+	slots chan struct{}
+}
+
+// New returns a budget of max open descriptors.
+func New(max int) (*Budget, error) {
+	if max < PerFile {
+		return nil, ErrTooLow
+	}
+	return &Budget{slots: make(chan struct{}, max/PerFile)}, nil
+}
+
+// Default returns the budget the process's open file limit allows, minus a
+// reserve for descriptors held for the whole run.
+func Default() int {
+	return max(limit()-reserve, PerFile)
+}
+
+// Files returns how many files can be open at once.
+func (b *Budget) Files() int { return cap(b.slots) }
+
+// Acquire waits until a file may be opened or ctx is done.
+func (b *Budget) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release returns a slot taken by Acquire.
+func (b *Budget) Release() { <-b.slots }
@@ -0,0 +1,42 @@
+package fdlimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudget_Backpressure(t *testing.T) {
+	b, err := New(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Files() != 2 {
+		t.Fatalf("files: got %d want 2", b.Files())
+	}
+	ctx := context.Background()
+	for range 2 {
+		if err := b.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := b.Acquire(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait for a slot, got %v", err)
+	}
+	b.Release()
+	if err := b.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_TooLow(t *testing.T) {
+	if _, err := New(PerFile - 1); !errors.Is(err, ErrTooLow) {
+		t.Fatalf("got %v", err)
+	}
+	if Default() < PerFile {
+		t.Fatalf("default budget %d cannot fit one file", Default())
+	}
+}
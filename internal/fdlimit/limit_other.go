@@ -0,0 +1,6 @@
+//go:build !unix
+
+package fdlimit
+
+// limit returns a conservative default where there is no RLIMIT_NOFILE.
+func limit() int { return 1024 }
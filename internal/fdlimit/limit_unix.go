@@ -0,0 +1,17 @@
+//go:build unix
+
+package fdlimit
+
+import (
+	"math"
+	"syscall"
+)
+
+// limit returns the soft RLIMIT_NOFILE.
+func limit() int {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 256
+	}
+	return int(min(uint64(rl.Cur), math.MaxInt32))
+}
@@ -0,0 +1,49 @@
+// Package gitdiff asks git which files changed, so a run can be limited to
+// the files a branch or pull request touched.
+package gitdiff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ChangedSince returns the absolute paths of files in the work tree of the
+// repository containing dir that differ from ref (committed or not), as
+// reported by git diff --name-only. Deleted files are left out.
+func ChangedSince(ctx context.Context, dir, ref string) ([]string, error) {
+	top, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	out, err := git(ctx, dir, "diff", "--name-only", "-z", "--diff-filter=d", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			paths = append(paths, filepath.Join(root, filepath.FromSlash(string(name))))
+		}
+	}
+	return paths, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
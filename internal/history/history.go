@@ -0,0 +1,96 @@
+// Package history keeps the user-level log of safereplace invocations, one
+// JSON object per line in $XDG_STATE_HOME/safereplace/history (default
+// ~/.local/state/safereplace/history), so past runs can be audited and
+// repeated.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Entry is one recorded invocation.
+type Entry struct {
+	// Time is when the run finished, in RFC 3339.
+	Time string `json:"time"`
+	// Dir is the working directory the run started in.
+	Dir string `json:"dir"`
+	// Args are the command-line arguments, without the program name.
+	Args []string `json:"args"`
+	// Exit is the exit code.
+	Exit  int    `json:"exit"`
+	RunID string `json:"run_id,omitempty"`
+	// Files and Replacements count what the run changed (or would change in
+	// a dry run).
+	Files        int `json:"files,omitempty"`
+	Replacements int `json:"replacements,omitempty"`
+}
+
+// Path returns the history file.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "safereplace", "history"), nil
+}
+
+// Append adds e to the history file at path, creating it (readable by the
+// user only, since arguments may hold sensitive patterns) if needed.
+func Append(path string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// Load reads the history file at path, oldest first. A missing file is an
+// empty history.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("history: %s: line %d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("history: %s: %w", path, err)
+	}
+	return entries, nil
+}
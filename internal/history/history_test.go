@@ -0,0 +1,44 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history")
+	if entries, err := Load(path); err != nil || entries != nil {
+		t.Fatalf("missing file: got %v, %v", entries, err)
+	}
+	want := []Entry{
+		{Time: "2026-01-02T03:04:05Z", Dir: "/src", Args: []string{"--pattern", "a b", "--ext", "go"}, Exit: 1, RunID: "r1", Files: 2, Replacements: 5},
+		{Time: "2026-01-02T03:05:00Z", Dir: "/src", Args: []string{"undo", "--run", "r1"}, Exit: 0},
+	}
+	for _, e := range want {
+		if err := Append(path, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("mode: got %v, %v", info.Mode(), err)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if p, err := Path(); err != nil || p != filepath.Join("/state", "safereplace", "history") {
+		t.Fatalf("got %q, %v", p, err)
+	}
+}
@@ -0,0 +1,239 @@
+package journal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"safereplace/internal/patch"
+)
+
+// Entry is one line of a run journal. Each run writes its own file named
+// <run id>.jsonl, so entries for a run can be found from the run ID alone.
+type Entry struct {
+	Time         string   `json:"time"`
+	RunID        string   `json:"run_id"`
+	Action       string   `json:"action"`
+	Path         string   `json:"path,omitempty"`
+	Backup       string   `json:"backup,omitempty"`
+	Matches      int      `json:"matches,omitempty"`
+	Replacements int      `json:"replacements,omitempty"`
+	Args         []string `json:"args,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	// ArchiveMember names the original inside Backup when it is a run archive.
+	ArchiveMember string `json:"archive_member,omitempty"`
+	// BeforeSHA256 and AfterSHA256 are hex digests of the file content before and
+	// after the replacement; verify compares AfterSHA256 with the file on disk.
+	BeforeSHA256 string `json:"before_sha256,omitempty"`
+	AfterSHA256  string `json:"after_sha256,omitempty"`
+	// ReversePatch turns the applied content back into the original; it is
+	// recorded instead of a backup copy with --backup-diff.
+	ReversePatch *patch.Patch `json:"reverse_patch,omitempty"`
+	// PrevHash and Hash link entries of a chained journal: Hash is the
+	// HMAC-SHA256, under the journal key, of the entry's JSON encoding with
+	// Hash empty and PrevHash set to the previous entry's Hash ("" for the
+	// first entry).
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// Actions recorded in a journal.
+const (
+	ActionRunStart  = "run-start"
+	ActionPreviewed = "previewed"
+	ActionApplied   = "applied"
+	ActionSkipped   = "skipped"
+	ActionError     = "error"
+	// ActionRolledBack restores a file applied earlier in the same run, after
+	// a failed --post-check; undo and verify leave such files alone.
+	ActionRolledBack = "rolled-back"
+)
+
+// Hash returns the hex SHA-256 digest of data as stored in journal entries.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrChainBroken is returned (wrapped) by VerifyChain when entries were
+// modified, removed or reordered after being written, or hashed with another
+// key.
+var ErrChainBroken = errors.New("journal hash chain broken")
+
+// Writer appends entries for a single run. Each entry is written and synced
+// immediately so the journal survives a crash mid-run.
+type Writer struct {
+	f     *os.File
+	runID string
+	// chained writers link every entry to its predecessor's hash, keyed
+	// with key.
+	chained  bool
+	key      []byte
+	lastHash string
+}
+
+// PathFor returns the journal file path for runID inside dir.
+func PathFor(dir, runID string) string {
+	return filepath.Join(dir, runID+".jsonl")
+}
+
+// Create opens a new journal for runID in dir, creating dir if needed.
+// It fails if a journal for the same run already exists.
+func Create(dir, runID string) (*Writer, error) {
+	return create(dir, runID, false)
+}
+
+// CreateChained is like Create but writes a hash chain keyed with key (see
+// LoadKey), which VerifyChain can later check for tampering by anyone without
+// the key. The file is made read-only on Close.
+func CreateChained(dir, runID string, key []byte) (*Writer, error) {
+	if len(key) == 0 {
+		return nil, errors.New("journal: empty chain key")
+	}
+	w, err := create(dir, runID, true)
+	if err == nil {
+		w.key = key
+	}
+	return w, err
+}
+
+func create(dir, runID string, chained bool) (*Writer, error) {
+	if runID == "" {
+		return nil, errors.New("journal: empty run id")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	f, err := os.OpenFile(PathFor(dir, runID), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	return &Writer{f: f, runID: runID, chained: chained}, nil
+}
+
+// Append stamps e with the run ID and current time (if unset) and writes it.
+func (w *Writer) Append(e Entry) error {
+	e.RunID = w.runID
+	if e.Time == "" {
+		e.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	if w.chained {
+		sum, err := chainHash(e, w.lastHash, w.key)
+		if err != nil {
+			return err
+		}
+		e.PrevHash, e.Hash = w.lastHash, sum
+		w.lastHash = sum
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if _, err := w.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("journal: write: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("journal: fsync: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file. Chained journals are then made read-only.
+func (w *Writer) Close() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if w.chained {
+		return os.Chmod(w.f.Name(), 0o444)
+	}
+	return nil
+}
+
+// chainHash computes the Hash for e when it follows an entry hashed prev.
+func chainHash(e Entry, prev string, key []byte) (string, error) {
+	e.PrevHash, e.Hash = prev, ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("journal: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Chained reports whether any of entries carries a chain hash, so clearing
+// the hashes of some entries does not turn verification off.
+func Chained(entries []Entry) bool {
+	for _, e := range entries {
+		if e.Hash != "" || e.PrevHash != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyChain recomputes the hash chain over entries with key and reports
+// the first entry that does not match, including entries left unhashed.
+func VerifyChain(entries []Entry, key []byte) error {
+	prev := ""
+	for i, e := range entries {
+		if e.Hash == "" {
+			return fmt.Errorf("entry %d: %w: not hashed", i+1, ErrChainBroken)
+		}
+		if e.PrevHash != prev {
+			return fmt.Errorf("entry %d: %w: prev_hash mismatch", i+1, ErrChainBroken)
+		}
+		sum, err := chainHash(e, prev, key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(e.Hash), []byte(sum)) {
+			return fmt.Errorf("entry %d: %w: hash mismatch", i+1, ErrChainBroken)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Applied returns the ActionApplied entries, leaving out files a later
+// ActionRolledBack entry restored.
+func Applied(entries []Entry) []Entry {
+	rolledBack := map[string]bool{}
+	for _, e := range entries {
+		if e.Action == ActionRolledBack {
+			rolledBack[e.Path] = true
+		}
+	}
+	var applied []Entry
+	for _, e := range entries {
+		if e.Action == ActionApplied && !rolledBack[e.Path] {
+			applied = append(applied, e)
+		}
+	}
+	return applied
+}
+
+// Read returns all entries of the journal for runID in dir, in write order.
+func Read(dir, runID string) ([]Entry, error) {
+	data, err := os.ReadFile(PathFor(dir, runID))
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	var entries []Entry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return entries, fmt.Errorf("journal: %s: entry %d: %w", runID, len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
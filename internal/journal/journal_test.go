@@ -0,0 +1,152 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal_AppendAndRead(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(dir, "run1")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := w.Append(Entry{Action: ActionRunStart, Args: []string{"--pattern", "a"}}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := w.Append(Entry{Action: ActionApplied, Path: "/x/a.txt", Backup: "/x/a.txt.bak", Matches: 2, Replacements: 2}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	got, err := Read(dir, "run1")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("entries: got %d want 2", len(got))
+	}
+	for _, e := range got {
+		if e.RunID != "run1" || e.Time == "" {
+			t.Fatalf("entry not stamped: %+v", e)
+		}
+	}
+	if got[1].Backup != "/x/a.txt.bak" || got[1].Matches != 2 {
+		t.Fatalf("entry mismatch: %+v", got[1])
+	}
+}
+
+func TestJournal_CreateRefusesExistingRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(PathFor(dir, "dup"), nil, 0o644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if _, err := Create(dir, "dup"); err == nil {
+		t.Fatalf("expected error for existing journal")
+	}
+}
+
+func TestJournal_ReadMissing(t *testing.T) {
+	if _, err := Read(t.TempDir(), "nope"); err == nil {
+		t.Fatalf("expected error for missing journal")
+	}
+}
+
+func TestJournal_ChainedDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("secret")
+	w, err := CreateChained(dir, "run2", key)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		if err := w.Append(Entry{Action: ActionApplied, Path: p, AfterSHA256: Hash([]byte(p))}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	info, err := os.Stat(PathFor(dir, "run2"))
+	if err != nil || info.Mode().Perm()&0o222 != 0 {
+		t.Fatalf("chained journal should be read-only: %v %v", info.Mode(), err)
+	}
+
+	entries, err := Read(dir, "run2")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !Chained(entries) {
+		t.Fatalf("expected chained entries")
+	}
+	if err := VerifyChain(entries, key); err != nil {
+		t.Fatalf("intact chain: %v", err)
+	}
+
+	modified := append([]Entry(nil), entries...)
+	modified[1].Path = "/evil"
+	if err := VerifyChain(modified, key); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("modified entry: expected ErrChainBroken, got %v", err)
+	}
+	removed := append([]Entry{entries[0]}, entries[2:]...)
+	if err := VerifyChain(removed, key); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("removed entry: expected ErrChainBroken, got %v", err)
+	}
+	if err := VerifyChain(entries, []byte("other")); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("other key: expected ErrChainBroken, got %v", err)
+	}
+
+	// Clearing the hashes of the first entry neither hides the chain nor
+	// passes verification.
+	cleared := append([]Entry(nil), entries...)
+	cleared[0].Hash, cleared[0].PrevHash = "", ""
+	if !Chained(cleared) {
+		t.Fatal("partly cleared entries not reported as chained")
+	}
+	if err := VerifyChain(cleared, key); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("cleared entry: expected ErrChainBroken, got %v", err)
+	}
+}
+
+func TestApplied_LeavesOutRolledBack(t *testing.T) {
+	entries := []Entry{
+		{Action: ActionRunStart},
+		{Action: ActionApplied, Path: "a"},
+		{Action: ActionApplied, Path: "b"},
+		{Action: ActionSkipped, Path: "c"},
+		{Action: ActionRolledBack, Path: "a"},
+	}
+	got := Applied(entries)
+	if len(got) != 1 || got[0].Path != "b" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.key")
+	if _, err := LoadKey(path, false); err == nil {
+		t.Fatal("expected error for a missing key without create")
+	}
+	key, err := LoadKey(path, true)
+	if err != nil || len(key) != 32 {
+		t.Fatalf("create: %x, %v", key, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode: %v, %v", info, err)
+	}
+	again, err := LoadKey(path, true)
+	if err != nil || !bytes.Equal(again, key) {
+		t.Fatalf("reload: %x, %v; want %x", again, err, key)
+	}
+	if err := os.WriteFile(path, []byte("not hex\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(path, false); err == nil {
+		t.Fatal("expected error for a malformed key")
+	}
+}
@@ -0,0 +1,69 @@
+package journal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeyPath returns the default file holding the key of chained journals,
+// $XDG_STATE_HOME/safereplace/journal.key (~/.local/state by default). It
+// lives outside journal directories, so whoever can rewrite a journal cannot
+// necessarily recompute its chain.
+func KeyPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "safereplace", "journal.key"), nil
+}
+
+// LoadKey reads the hex-encoded chain key at path. With create, a missing key
+// file is created with a new random key, readable by the user only.
+func LoadKey(path string, create bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		return newKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("journal key: %s: not a hex-encoded key", path)
+	}
+	return key, nil
+}
+
+func newKey(path string) ([]byte, error) {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key) // crypto/rand.Read never returns an error
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("journal key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		// Created concurrently by another run.
+		return LoadKey(path, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal key: %w", err)
+	}
+	_, err = f.WriteString(hex.EncodeToString(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,89 @@
+// Package patch builds and applies compact line patches. A patch records the
+// exact lines it removes, so applying it to content that drifted fails instead
+// of silently corrupting the file.
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"safereplace/internal/diff"
+)
+
+// ErrMismatch is returned (wrapped) when the content does not match the lines
+// the patch expects to replace.
+var ErrMismatch = errors.New("patch does not match content")
+
+// Hunk replaces the Old lines starting at 0-based line Line of the source with New.
+// Lines keep their terminators, so EOL style and a missing final newline round-trip.
+type Hunk struct {
+	Line int      `json:"line"`
+	Old  []string `json:"old,omitempty"`
+	New  []string `json:"new,omitempty"`
+}
+
+// Patch is an ordered list of non-overlapping hunks.
+type Patch struct {
+	Hunks []Hunk `json:"hunks"`
+}
+
+// Make returns the patch that turns from into to.
+func Make(from, to string) Patch {
+	a, b := SplitLines(from), SplitLines(to)
+	var p Patch
+	var cur *Hunk
+	for _, op := range diff.LineOps(a, b) {
+		if op.Kind == diff.OpEqual {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			p.Hunks = append(p.Hunks, Hunk{Line: op.A1})
+			cur = &p.Hunks[len(p.Hunks)-1]
+		}
+		cur.Old = append(cur.Old, a[op.A1:op.A2]...)
+		cur.New = append(cur.New, b[op.B1:op.B2]...)
+	}
+	return p
+}
+
+// Apply applies p to content, verifying every replaced line.
+func (p Patch) Apply(content string) (string, error) {
+	lines := SplitLines(content)
+	var b strings.Builder
+	next := 0
+	for i, h := range p.Hunks {
+		if h.Line < next || h.Line+len(h.Old) > len(lines) {
+			return "", fmt.Errorf("hunk %d at line %d: %w", i+1, h.Line+1, ErrMismatch)
+		}
+		for _, l := range lines[next:h.Line] {
+			b.WriteString(l)
+		}
+		for j, old := range h.Old {
+			if lines[h.Line+j] != old {
+				return "", fmt.Errorf("hunk %d at line %d: %w", i+1, h.Line+j+1, ErrMismatch)
+			}
+		}
+		for _, l := range h.New {
+			b.WriteString(l)
+		}
+		next = h.Line + len(h.Old)
+	}
+	for _, l := range lines[next:] {
+		b.WriteString(l)
+	}
+	return b.String(), nil
+}
+
+// SplitLines splits s after each "\n", keeping terminators. The last line may lack one.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,48 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPatch_RoundTrip(t *testing.T) {
+	cases := []struct{ from, to string }{
+		{"a\nb\nc\n", "a\nB\nc\n"},
+		{"foo\r\nbar\r\n", "baz\r\nbar\r\n"},
+		{"no final newline", "no final newline\n"},
+		{"", "new\n"},
+		{"gone\n", ""},
+		{"x\ny\nz", "x\nz\nw\ny"},
+	}
+	for _, c := range cases {
+		p := Make(c.from, c.to)
+		got, err := p.Apply(c.from)
+		if err != nil {
+			t.Fatalf("apply %q->%q: %v", c.from, c.to, err)
+		}
+		if got != c.to {
+			t.Fatalf("apply %q: got %q want %q", c.from, got, c.to)
+		}
+		back, err := Make(c.to, c.from).Apply(c.to)
+		if err != nil || back != c.from {
+			t.Fatalf("reverse %q: got %q, %v", c.to, back, err)
+		}
+	}
+}
+
+func TestPatch_OnlyStoresChangedLines(t *testing.T) {
+	p := Make("keep\nold\nkeep\n", "keep\nnew\nkeep\n")
+	if len(p.Hunks) != 1 || p.Hunks[0].Line != 1 || len(p.Hunks[0].Old) != 1 || len(p.Hunks[0].New) != 1 {
+		t.Fatalf("unexpected patch: %+v", p)
+	}
+}
+
+func TestPatch_MismatchDetected(t *testing.T) {
+	p := Make("a\nb\n", "a\nc\n")
+	if _, err := p.Apply("a\nX\n"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+	if _, err := p.Apply("a\n"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch for short content, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package plan
+
+import "sort"
+
+// Kinds of FileDiff.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// FileDiff is how the planned change to one file differs between two plans.
+type FileDiff struct {
+	Path string
+	Kind string
+	// Old and New are the file's entries in each plan; one is nil for Added
+	// and Removed.
+	Old, New *File
+	// OnlyOld and OnlyNew are the edits of a Changed file found in only one
+	// of the plans. Both are empty when just the file's content differs.
+	OnlyOld, OnlyNew []Edit
+}
+
+// Diff compares plan from with the later plan to file by file, matching
+// files by path, and returns the files that were added, removed or changed,
+// in path order. A file has changed when its edits or its before or after
+// digest differ.
+func Diff(from, to Plan) []FileDiff {
+	files := map[string]*FileDiff{}
+	for i := range from.Files {
+		f := &from.Files[i]
+		files[f.Path] = &FileDiff{Path: f.Path, Kind: Removed, Old: f}
+	}
+	for i := range to.Files {
+		f := &to.Files[i]
+		d, ok := files[f.Path]
+		if !ok {
+			files[f.Path] = &FileDiff{Path: f.Path, Kind: Added, New: f}
+			continue
+		}
+		d.New = f
+		d.OnlyOld, d.OnlyNew = diffEdits(d.Old.Edits, f.Edits)
+		if len(d.OnlyOld) == 0 && len(d.OnlyNew) == 0 && d.Old.BeforeSHA256 == f.BeforeSHA256 && d.Old.AfterSHA256 == f.AfterSHA256 {
+			delete(files, f.Path)
+			continue
+		}
+		d.Kind = Changed
+	}
+	diffs := make([]FileDiff, 0, len(files))
+	for _, d := range files {
+		diffs = append(diffs, *d)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+// diffEdits returns the edits of a not in b and those of b not in a, each
+// in its original order. Repeated edits are counted.
+func diffEdits(a, b []Edit) (onlyA, onlyB []Edit) {
+	count := map[Edit]int{}
+	for _, e := range b {
+		count[e]++
+	}
+	for _, e := range a {
+		if count[e] > 0 {
+			count[e]--
+			continue
+		}
+		onlyA = append(onlyA, e)
+	}
+	for _, e := range b {
+		if count[e] > 0 {
+			count[e]--
+			onlyB = append(onlyB, e)
+		}
+	}
+	return onlyA, onlyB
+}
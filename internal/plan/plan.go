@@ -0,0 +1,102 @@
+// Package plan reads and writes saved plans: the changes a run planned for
+// each file, recorded as JSON so they can be reviewed, compared or rolled out
+// later.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Version is the plan format version written by Write.
+const Version = 1
+
+// Plan is a saved set of planned changes.
+type Plan struct {
+	Version int    `json:"version"`
+	RunID   string `json:"run_id"`
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+	Files   []File `json:"files"`
+}
+
+// File is the planned change to one file. BeforeSHA256 identifies the content
+// the plan was made against, so a stale plan can be detected.
+type File struct {
+	Path         string `json:"path"`
+	Matches      int    `json:"matches"`
+	Replacements int    `json:"replacements"`
+	BeforeSHA256 string `json:"before_sha256"`
+	AfterSHA256  string `json:"after_sha256"`
+	Edits        []Edit `json:"edits,omitempty"`
+}
+
+// Edit is one replacement, located by 1-based line and byte column in the
+// original content.
+type Edit struct {
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Write saves p to path as indented JSON.
+func Write(path string, p Plan) error {
+	p.Version = Version
+	if p.Files == nil {
+		p.Files = []File{}
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+	return nil
+}
+
+// Load reads a plan saved by Write.
+func Load(path string) (Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, fmt.Errorf("plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Plan{}, fmt.Errorf("plan: %s: %w", path, err)
+	}
+	if p.Version != Version {
+		return Plan{}, fmt.Errorf("plan: %s: unsupported version %d", path, p.Version)
+	}
+	return p, nil
+}
+
+// Offsets returns the byte offset in before, the content f was planned
+// against, of each of its edits. It fails if an edit's old text is not found
+// at its position.
+func (f File) Offsets(before []byte) ([]int, error) {
+	offs := make([]int, 0, len(f.Edits))
+	line, lineStart, off := 1, 0, 0
+	for _, e := range f.Edits {
+		for ; off < len(before) && line < e.Line; off++ {
+			if before[off] == '\n' {
+				line++
+				lineStart = off + 1
+			}
+		}
+		start := lineStart + e.Col - 1
+		if line != e.Line || e.Col < 1 || start < off || start+len(e.Old) > len(before) || string(before[start:start+len(e.Old)]) != e.Old {
+			return nil, fmt.Errorf("edit at %d:%d: %q not found", e.Line, e.Col, e.Old)
+		}
+		offs = append(offs, start)
+		for off = start; off < start+len(e.Old); off++ {
+			if before[off] == '\n' {
+				line++
+				lineStart = off + 1
+			}
+		}
+	}
+	return offs, nil
+}
@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	want := Plan{RunID: "r1", Pattern: "foo", Replace: "bar", Files: []File{{
+		Path: "a.txt", Matches: 1, Replacements: 1, BeforeSHA256: "b", AfterSHA256: "a",
+		Edits: []Edit{{Line: 2, Col: 3, Old: "foo", New: "bar"}},
+	}}}
+	if err := Write(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Version = Version
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"bad.json":     "{",
+		"version.json": `{"version": 99}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing: expected error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	e1 := Edit{Line: 1, Col: 1, Old: "foo", New: "bar"}
+	e2 := Edit{Line: 2, Col: 1, Old: "foo", New: "bar"}
+	e3 := Edit{Line: 2, Col: 1, Old: "foo", New: "baz"}
+	old := Plan{Files: []File{
+		{Path: "same", BeforeSHA256: "s", AfterSHA256: "t", Edits: []Edit{e1}},
+		{Path: "gone", Edits: []Edit{e1}},
+		{Path: "edits", BeforeSHA256: "b", Edits: []Edit{e1, e2}},
+		{Path: "base", BeforeSHA256: "b1", Edits: []Edit{e1}},
+	}}
+	cur := Plan{Files: []File{
+		{Path: "base", BeforeSHA256: "b2", Edits: []Edit{e1}},
+		{Path: "edits", BeforeSHA256: "b", Edits: []Edit{e1, e3}},
+		{Path: "new", Edits: []Edit{e2}},
+		{Path: "same", BeforeSHA256: "s", AfterSHA256: "t", Edits: []Edit{e1}},
+	}}
+	got := Diff(old, cur)
+	var summary []string
+	for _, d := range got {
+		summary = append(summary, d.Kind+" "+d.Path)
+	}
+	want := []string{"changed base", "changed edits", "removed gone", "added new"}
+	if !reflect.DeepEqual(summary, want) {
+		t.Fatalf("got %v want %v", summary, want)
+	}
+	if d := got[1]; !reflect.DeepEqual(d.OnlyOld, []Edit{e2}) || !reflect.DeepEqual(d.OnlyNew, []Edit{e3}) {
+		t.Fatalf("edits: %+v", d)
+	}
+	if d := got[0]; len(d.OnlyOld) != 0 || len(d.OnlyNew) != 0 {
+		t.Fatalf("base only: %+v", d)
+	}
+	if len(Diff(old, old)) != 0 {
+		t.Fatal("identical plans differ")
+	}
+}
+
+func TestFile_Offsets(t *testing.T) {
+	before := []byte("foo foo\nx foo\nbar\n")
+	f := File{Edits: []Edit{
+		{Line: 1, Col: 1, Old: "foo", New: "a"},
+		{Line: 1, Col: 5, Old: "foo\nx", New: "b"},
+		{Line: 2, Col: 3, Old: "foo", New: "c"},
+	}}
+	if offs, err := f.Offsets(before); err != nil || !reflect.DeepEqual(offs, []int{0, 4, 10}) {
+		t.Fatalf("got %v, %v", offs, err)
+	}
+	if offs, err := (File{}).Offsets(before); err != nil || len(offs) != 0 {
+		t.Fatalf("no edits: got %v, %v", offs, err)
+	}
+	for _, e := range []Edit{
+		{Line: 1, Col: 2, Old: "foo", New: "a"},
+		{Line: 4, Col: 1, Old: "foo", New: "a"},
+		{Line: 3, Col: 3, Old: "rx", New: "a"},
+		{Line: 1, Col: 0, Old: "foo", New: "a"},
+	} {
+		if _, err := (File{Edits: []Edit{e}}).Offsets(before); err == nil {
+			t.Errorf("%+v: expected error", e)
+		}
+	}
+}
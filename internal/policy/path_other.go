@@ -0,0 +1,9 @@
+//go:build !windows
+
+package policy
+
+// DefaultPath is the system policy file, which takes precedence over
+// $SAFEREPLACE_POLICY.
+func DefaultPath() string {
+	return "/etc/safereplace/policy.conf"
+}
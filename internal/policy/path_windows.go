@@ -0,0 +1,18 @@
+//go:build windows
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DefaultPath is the system policy file under %ProgramData%, which takes
+// precedence over $SAFEREPLACE_POLICY.
+func DefaultPath() string {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		dir = `C:\ProgramData`
+	}
+	return filepath.Join(dir, "safereplace", "policy.conf")
+}
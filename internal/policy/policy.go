@@ -0,0 +1,169 @@
+// Package policy reads the organization policy: settings a platform team
+// installs on machines and CI runners to enforce safe defaults for every run,
+// which command-line flags cannot override. A policy file holds one
+// key = value setting per line, for example:
+//
+//	# /etc/safereplace/policy.conf
+//	require-backup = true
+//	ci-dry-run-only = true
+//	min-pattern-length = 3
+//	exclude = vendor/*, *.lock
+//
+// DefaultPath is used if it exists, so users cannot opt out of it; otherwise
+// the file named by $SAFEREPLACE_POLICY, if set.
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// EnvVar names the environment variable pointing at the policy file.
+const EnvVar = "SAFEREPLACE_POLICY"
+
+// Policy is a parsed policy file. The zero Policy enforces nothing.
+type Policy struct {
+	// Path is the file the policy was loaded from, for error messages.
+	Path string
+	// RequireBackup refuses to apply changes without a backup of the originals.
+	RequireBackup bool
+	// CIDryRunOnly refuses to apply changes when $CI is set, so CI jobs can
+	// only check for changes.
+	CIDryRunOnly bool
+	// MinPatternLength and MaxPatternLength bound the length of search
+	// patterns in bytes (0 = no bound).
+	MinPatternLength int
+	MaxPatternLength int
+	// Exclude lists globs of files never processed, matched like discovery
+	// excludes.
+	Exclude []string
+}
+
+// Find loads the policy in effect: DefaultPath if it exists, or else the
+// file named by $SAFEREPLACE_POLICY, which must exist. The variable is
+// ignored when DefaultPath exists, so it cannot loosen the system policy.
+func Find() (Policy, error) {
+	return find(DefaultPath())
+}
+
+// find is Find with system in place of DefaultPath.
+func find(system string) (Policy, error) {
+	if _, err := os.Stat(system); !errors.Is(err, fs.ErrNotExist) {
+		return Load(system)
+	}
+	if p := os.Getenv(EnvVar); p != "" {
+		return Load(p)
+	}
+	return Policy{}, nil
+}
+
+// Load parses the policy file at p.
+func Load(p string) (Policy, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return Policy{}, fmt.Errorf("policy: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	pol, err := Parse(f)
+	if err != nil {
+		return Policy{}, fmt.Errorf("policy: %s: %w", p, err)
+	}
+	pol.Path = p
+	return pol, nil
+}
+
+// Parse reads a policy from r. Unknown keys are errors, so a misspelled
+// setting is not silently unenforced.
+func Parse(r io.Reader) (Policy, error) {
+	var pol Policy
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" {
+			return pol, fmt.Errorf("line %d: want key = value, got %q", n, strings.TrimSpace(line))
+		}
+		if err := pol.set(key, val); err != nil {
+			return pol, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return pol, err
+	}
+	if pol.MaxPatternLength > 0 && pol.MinPatternLength > pol.MaxPatternLength {
+		return pol, errors.New("min-pattern-length is greater than max-pattern-length")
+	}
+	return pol, nil
+}
+
+func (pol *Policy) set(key, val string) error {
+	var err error
+	switch key {
+	case "require-backup":
+		pol.RequireBackup, err = strconv.ParseBool(val)
+	case "ci-dry-run-only":
+		pol.CIDryRunOnly, err = strconv.ParseBool(val)
+	case "min-pattern-length":
+		pol.MinPatternLength, err = length(val)
+	case "max-pattern-length":
+		pol.MaxPatternLength, err = length(val)
+	case "exclude":
+		for _, g := range strings.Split(val, ",") {
+			if g = strings.TrimSpace(g); g == "" {
+				continue
+			}
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("%s: %w", g, err)
+			}
+			pol.Exclude = append(pol.Exclude, g)
+		}
+	default:
+		return errors.New("unknown setting")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q", val)
+	}
+	return nil
+}
+
+// length parses a non-negative length.
+func length(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err == nil && n < 0 {
+		err = errors.New("negative")
+	}
+	return n, err
+}
+
+// InCI reports whether the environment looks like a CI job: $CI is set to
+// anything but "false" or "0", as by GitHub Actions, GitLab CI and most others.
+func InCI() bool {
+	v := os.Getenv("CI")
+	return v != "" && v != "false" && v != "0"
+}
+
+// CheckPattern returns an error if the length of pattern is outside the
+// policy's bounds.
+func (pol Policy) CheckPattern(pattern string) error {
+	switch n := len(pattern); {
+	case pol.MinPatternLength > 0 && n < pol.MinPatternLength:
+		return fmt.Errorf("pattern %q is shorter than the minimum of %d bytes", pattern, pol.MinPatternLength)
+	case pol.MaxPatternLength > 0 && n > pol.MaxPatternLength:
+		return fmt.Errorf("pattern of %d bytes is longer than the maximum of %d", n, pol.MaxPatternLength)
+	}
+	return nil
+}
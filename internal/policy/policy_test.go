@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	pol, err := Parse(strings.NewReader(`# org policy
+require-backup = true
+ci-dry-run-only = 1   # CI only checks
+min-pattern-length = 3
+max-pattern-length = 100
+exclude = vendor/*, *.lock
+exclude = node_modules/*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Policy{
+		RequireBackup:    true,
+		CIDryRunOnly:     true,
+		MinPatternLength: 3,
+		MaxPatternLength: 100,
+		Exclude:          []string{"vendor/*", "*.lock", "node_modules/*"},
+	}
+	if !reflect.DeepEqual(pol, want) {
+		t.Fatalf("got %+v want %+v", pol, want)
+	}
+	if err := pol.CheckPattern("ab"); err == nil {
+		t.Error("expected error for short pattern")
+	}
+	if err := pol.CheckPattern(strings.Repeat("a", 101)); err == nil {
+		t.Error("expected error for long pattern")
+	}
+	if err := pol.CheckPattern("abc"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"require-backups = true",
+		"require-backup = maybe",
+		"min-pattern-length = -1",
+		"min-pattern-length = 5\nmax-pattern-length = 4",
+		"exclude = [",
+		"just a line",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestFind_EnvVar(t *testing.T) {
+	t.Setenv(EnvVar, "/nonexistent/policy.conf")
+	if _, err := find(filepath.Join(t.TempDir(), "system.conf")); err == nil {
+		t.Fatal("expected error for missing policy named by " + EnvVar)
+	}
+}
+
+func TestFind_SystemPolicyTakesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "system.conf")
+	user := filepath.Join(dir, "user.conf")
+	if err := os.WriteFile(user, []byte("min-pattern-length = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvVar, user)
+
+	pol, err := find(system)
+	if err != nil || pol.Path != user {
+		t.Fatalf("without a system policy: got %+v, %v; want %s", pol, err, user)
+	}
+
+	if err := os.WriteFile(system, []byte("require-backup = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{user, os.DevNull, "/nonexistent/policy.conf"} {
+		t.Setenv(EnvVar, env)
+		pol, err := find(system)
+		if err != nil || pol.Path != system || !pol.RequireBackup {
+			t.Errorf("%s=%s: got %+v, %v; want the system policy", EnvVar, env, pol, err)
+		}
+	}
+}
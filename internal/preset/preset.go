@@ -0,0 +1,124 @@
+// Package preset is the catalog of built-in recipes for common chores, run by
+// name with parameters that override their defaults.
+package preset
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"safereplace/internal/rules"
+)
+
+// Param is a named parameter of a preset.
+type Param struct {
+	Name    string
+	Default string
+	Help    string
+}
+
+// Recipe is what a preset runs: literal rules, or built-in fixes.
+type Recipe struct {
+	Rules []rules.Rule
+	// TrailingWhitespace trims spaces and tabs at line ends.
+	TrailingWhitespace bool
+}
+
+// Preset is a named recipe.
+type Preset struct {
+	Name   string
+	Help   string
+	Params []Param
+	build  func(params map[string]string) (Recipe, error)
+}
+
+// List returns the catalog, sorted by name. Defaults that depend on the date
+// (such as the copyright years) are computed from now.
+func List(now time.Time) []Preset {
+	year := now.Year()
+	return []Preset{
+		{
+			Name: "copyright-year",
+			Help: `Bump the year written right after "Copyright", "Copyright (c)" or "©"`,
+			Params: []Param{
+				{"from", strconv.Itoa(year - 1), "year to replace"},
+				{"to", strconv.Itoa(year), "new year"},
+			},
+			build: func(p map[string]string) (Recipe, error) {
+				for _, k := range []string{"from", "to"} {
+					if _, err := strconv.Atoi(p[k]); err != nil {
+						return Recipe{}, fmt.Errorf("%s: want a year, got %q", k, p[k])
+					}
+				}
+				var r Recipe
+				for _, prefix := range []string{"Copyright ", "Copyright (c) ", "Copyright (C) ", "Copyright © ", "© "} {
+					r.Rules = append(r.Rules, rules.Rule{Pattern: prefix + p["from"], Replace: prefix + p["to"]})
+				}
+				return r, nil
+			},
+		},
+		{
+			Name:   "http-to-https",
+			Help:   "Rewrite http:// links to https://; XML namespaces are links too, so limit it with host= where they occur",
+			Params: []Param{{"host", "", "only links to this host (all hosts when empty)"}},
+			build: func(p map[string]string) (Recipe, error) {
+				if strings.ContainsAny(p["host"], "/ ") {
+					return Recipe{}, fmt.Errorf("host: want a host name, got %q", p["host"])
+				}
+				return Recipe{Rules: []rules.Rule{{Pattern: "http://" + p["host"], Replace: "https://" + p["host"]}}}, nil
+			},
+		},
+		{
+			Name:   "tabs-to-spaces",
+			Help:   "Replace every tab character with spaces",
+			Params: []Param{{"width", "4", "spaces per tab"}},
+			build: func(p map[string]string) (Recipe, error) {
+				n, err := strconv.Atoi(p["width"])
+				if err != nil || n < 1 || n > 16 {
+					return Recipe{}, fmt.Errorf("width: want 1 to 16, got %q", p["width"])
+				}
+				return Recipe{Rules: []rules.Rule{{Pattern: "\t", Replace: strings.Repeat(" ", n)}}}, nil
+			},
+		},
+		{
+			Name: "trailing-whitespace",
+			Help: "Trim spaces and tabs at the end of every line",
+			build: func(map[string]string) (Recipe, error) {
+				return Recipe{TrailingWhitespace: true}, nil
+			},
+		},
+	}
+}
+
+// Lookup finds the preset called name in the catalog of List(now).
+func Lookup(name string, now time.Time) (Preset, bool) {
+	list := List(now)
+	i := slices.IndexFunc(list, func(p Preset) bool { return p.Name == name })
+	if i < 0 {
+		return Preset{}, false
+	}
+	return list[i], true
+}
+
+// Build returns the recipe of p with params ("name=value") overriding the
+// defaults. Unknown parameters are an error.
+func (p Preset) Build(params []string) (Recipe, error) {
+	values := map[string]string{}
+	for _, d := range p.Params {
+		values[d.Name] = d.Default
+	}
+	for _, kv := range params {
+		k, v, ok := strings.Cut(kv, "=")
+		if _, known := values[k]; !ok || !known {
+			return Recipe{}, fmt.Errorf("preset %s: unknown parameter %q", p.Name, kv)
+		}
+		values[k] = v
+	}
+	r, err := p.build(values)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("preset %s: %w", p.Name, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,59 @@
+package preset
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"safereplace/internal/rules"
+)
+
+var now = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestListSortedAndBuildable(t *testing.T) {
+	list := List(now)
+	for i, p := range list {
+		if i > 0 && list[i-1].Name >= p.Name {
+			t.Errorf("catalog not sorted at %s", p.Name)
+		}
+		if _, err := p.Build(nil); err != nil {
+			t.Errorf("%s: defaults: %v", p.Name, err)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	p, ok := Lookup("tabs-to-spaces", now)
+	if !ok {
+		t.Fatal("tabs-to-spaces not found")
+	}
+	r, err := p.Build([]string{"width=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []rules.Rule{{Pattern: "\t", Replace: "  "}}; !reflect.DeepEqual(r.Rules, want) {
+		t.Fatalf("got %+v", r.Rules)
+	}
+
+	p, _ = Lookup("copyright-year", now)
+	r, _ = p.Build(nil)
+	if r.Rules[0] != (rules.Rule{Pattern: "Copyright 2023", Replace: "Copyright 2024"}) {
+		t.Fatalf("copyright defaults: %+v", r.Rules[0])
+	}
+
+	for _, tc := range []struct{ name, param string }{
+		{"tabs-to-spaces", "width=0"},
+		{"tabs-to-spaces", "colour=red"},
+		{"tabs-to-spaces", "width"},
+		{"copyright-year", "to=next"},
+		{"http-to-https", "host=a/b"},
+	} {
+		p, _ := Lookup(tc.name, now)
+		if _, err := p.Build([]string{tc.param}); err == nil {
+			t.Errorf("%s %s: expected error", tc.name, tc.param)
+		}
+	}
+	if _, ok := Lookup("nope", now); ok {
+		t.Error("unknown preset found")
+	}
+}
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrCommand is returned (wrapped) when a transform command fails or replies
+// with something other than a valid CommandResponse.
+var ErrCommand = errors.New("transform command failed")
+
+// CommandRequest is the JSON object a transform command reads from stdin, one
+// per file. Content is base64-encoded so any bytes survive the round trip.
+type CommandRequest struct {
+	Version int    `json:"version"`
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+	Pattern string `json:"pattern,omitempty"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// CommandResponse is the JSON object a transform command writes to stdout.
+// A transformer returns the new Content; a matcher returns Edits against the
+// original content instead. Error reports a failure for this file.
+type CommandResponse struct {
+	Content []byte        `json:"content,omitempty"`
+	Edits   []CommandEdit `json:"edits,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// CommandEdit replaces content[Start:End] with Text (base64-encoded).
+type CommandEdit struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  []byte `json:"text"`
+}
+
+// CommandProtocolVersion is sent as CommandRequest.Version.
+const CommandProtocolVersion = 1
+
+// A Transformer answers one JSON-encoded CommandRequest with a JSON-encoded
+// CommandResponse.
+type Transformer interface {
+	Transform(ctx context.Context, req []byte) ([]byte, error)
+}
+
+// Command is a transform command, run once per request with the request on
+// stdin and the response read from stdout. A non-zero exit status fails with
+// the command's stderr.
+type Command []string
+
+// Transform runs the command for req.
+func (c Command) Transform(ctx context.Context, req []byte) ([]byte, error) {
+	if len(c) == 0 {
+		return nil, errors.New("empty command")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c[0], c[1:]...)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// SubstituteCommandFile reads the file at path and passes it through the
+// transformer t with SubstituteCommand. It does NOT write changes back to disk.
+func SubstituteCommandFile(ctx context.Context, t Transformer, path, pattern, repl string, opts Options) (Result, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
+	}
+	res, err := SubstituteCommand(ctx, t, path, data, pattern, repl)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
+
+// SubstituteCommand sends t a CommandRequest for data and applies the
+// CommandResponse it answers with.
+func SubstituteCommand(ctx context.Context, t Transformer, path string, data []byte, pattern, repl string) (Result, error) {
+	res := Result{Before: data, After: data, Binary: IsBinary(data)}
+	req, err := json.Marshal(CommandRequest{Version: CommandProtocolVersion, Path: path, Content: data, Pattern: pattern, Replace: repl})
+	if err != nil {
+		return res, err
+	}
+	out, err := t.Transform(ctx, req)
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrCommand, err)
+	}
+	var resp CommandResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return res, fmt.Errorf("%w: bad response: %v", ErrCommand, err)
+	}
+	if resp.Error != "" {
+		return res, fmt.Errorf("%w: %s", ErrCommand, resp.Error)
+	}
+	var edits []Edit
+	switch {
+	case resp.Edits != nil && resp.Content != nil:
+		return res, fmt.Errorf("%w: response has both content and edits", ErrCommand)
+	case resp.Edits != nil:
+		last := 0
+		for _, e := range resp.Edits {
+			if e.Start < last || e.End < e.Start || e.End > len(data) {
+				return res, fmt.Errorf("%w: edit [%d,%d) out of order or out of range", ErrCommand, e.Start, e.End)
+			}
+			edits = append(edits, Edit{Start: e.Start, End: e.End, Text: e.Text})
+			last = e.End
+		}
+	case resp.Content != nil && !bytes.Equal(resp.Content, data):
+		// The whole content is one replacement.
+		edits = []Edit{{Start: 0, End: len(data), Text: resp.Content}}
+	}
+	if len(edits) == 0 {
+		return res, nil
+	}
+	res.After = applyEdits(data, edits)
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = !bytes.Equal(data, res.After)
+	res.Edits = edits
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestHelperTransform is not a real test: run as a subprocess with
+// SAFEREPLACE_HELPER set, it acts as a transform command.
+func TestHelperTransform(t *testing.T) {
+	mode := os.Getenv("SAFEREPLACE_HELPER")
+	if mode == "" {
+		t.Skip("helper process")
+	}
+	var req CommandRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(3)
+	}
+	var resp CommandResponse
+	switch mode {
+	case "upper":
+		resp.Content = make([]byte, len(req.Content))
+		for i, c := range req.Content {
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			resp.Content[i] = c
+		}
+	case "match":
+		if i := bytes.Index(req.Content, []byte(req.Pattern)); i >= 0 {
+			resp.Edits = []CommandEdit{{Start: i, End: i + len(req.Pattern), Text: []byte(req.Replace)}}
+		}
+	case "reject":
+		resp.Error = "cannot parse " + req.Path
+	case "crash":
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+	os.Exit(0)
+}
+
+func helperCommand(t *testing.T, mode string) Command {
+	t.Setenv("SAFEREPLACE_HELPER", mode)
+	return Command{os.Args[0], "-test.run=^TestHelperTransform$"}
+}
+
+func TestSubstituteCommand(t *testing.T) {
+	ctx := context.Background()
+	res, err := SubstituteCommand(ctx, helperCommand(t, "upper"), "a.txt", []byte("abc\x00\xff"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || string(res.After) != "ABC\x00\xff" {
+		t.Fatalf("upper: %+v", res)
+	}
+
+	res, err = SubstituteCommand(ctx, helperCommand(t, "match"), "a.txt", []byte("foo foo"), "foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.After) != "bar foo" || res.Replacements != 1 || res.Edits[0].Start != 0 {
+		t.Fatalf("match: %+v", res)
+	}
+
+	res, err = SubstituteCommand(ctx, helperCommand(t, "match"), "a.txt", []byte("none"), "foo", "bar")
+	if err != nil || res.Changed {
+		t.Fatalf("no match: %+v, %v", res, err)
+	}
+}
+
+func TestSubstituteCommand_Errors(t *testing.T) {
+	ctx := context.Background()
+	for mode, want := range map[string]string{"reject": "cannot parse a.txt", "crash": "boom"} {
+		_, err := SubstituteCommand(ctx, helperCommand(t, mode), "a.txt", []byte("x"), "", "")
+		if !errors.Is(err, ErrCommand) || !bytes.Contains([]byte(err.Error()), []byte(want)) {
+			t.Errorf("%s: got %v", mode, err)
+		}
+	}
+}
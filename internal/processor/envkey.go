@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEnvValue is returned (wrapped) when a value cannot be written in the
+// quoting style of the line it replaces, or the line cannot be edited safely.
+var ErrEnvValue = errors.New("cannot replace value")
+
+// SubstituteEnvKeyFile reads a key-value file at path and replaces the value of
+// key with SubstituteEnvKey. It does NOT write changes back to disk.
+func SubstituteEnvKeyFile(path, key, value string, opts Options) (Result, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
+	}
+	return SubstituteEnvKey(data, key, value)
+}
+
+// SubstituteEnvKey replaces the value of every assignment to key in .env,
+// .properties or .ini style content. Lines look like
+//
+//	[export ]KEY=value    KEY = "value"    KEY: value    key='value' # comment
+//
+// Only the value is rewritten: comments, ordering, spacing around the separator
+// and the quoting style (none, single or double quotes) are kept.
+func SubstituteEnvKey(data []byte, key, value string) (Result, error) {
+	res := Result{Before: data, After: data, Binary: IsBinary(data)}
+	var edits []Edit
+	for off, n := 0, 1; off < len(data); n++ {
+		end := len(data)
+		if i := bytes.IndexByte(data[off:], '\n'); i >= 0 {
+			end = off + i + 1
+		}
+		start, stop, quote, ok := envValue(data[off:end], key)
+		if ok {
+			text, err := quoteEnvValue(value, quote)
+			if err != nil {
+				return res, fmt.Errorf("line %d: %w", n, err)
+			}
+			if quote == 0 && bytes.HasSuffix(bytes.TrimRight(data[off+start:off+stop], " \t"), []byte(`\`)) {
+				return res, fmt.Errorf("line %d: %w: continued value", n, ErrEnvValue)
+			}
+			edits = append(edits, Edit{Start: off + start, End: off + stop, Text: []byte(text)})
+		}
+		off = end
+	}
+	if len(edits) == 0 {
+		return res, nil
+	}
+	res.After = applyEdits(data, edits)
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = !bytes.Equal(data, res.After)
+	res.Edits = edits
+	return res, nil
+}
+
+// envValue locates the value assigned to key on line. start:stop covers the
+// value including its quotes; quote is the quote character or 0.
+func envValue(line []byte, key string) (start, stop int, quote byte, ok bool) {
+	i := skipBlanks(line, 0)
+	if i < len(line) && strings.IndexByte("#;!", line[i]) >= 0 {
+		return 0, 0, 0, false
+	}
+	if bytes.HasPrefix(line[i:], []byte("export ")) {
+		i = skipBlanks(line, i+len("export "))
+	}
+	if !bytes.HasPrefix(line[i:], []byte(key)) {
+		return 0, 0, 0, false
+	}
+	i = skipBlanks(line, i+len(key))
+	if i >= len(line) || (line[i] != '=' && line[i] != ':') {
+		return 0, 0, 0, false
+	}
+	start = skipBlanks(line, i+1)
+	stop = len(bytes.TrimRight(line, "\r\n"))
+	if start >= stop {
+		return stop, stop, 0, true
+	}
+	if q := line[start]; q == '"' || q == '\'' {
+		for j := start + 1; j < stop; j++ {
+			if line[j] == '\\' && q == '"' {
+				j++
+				continue
+			}
+			if line[j] == q {
+				return start, j + 1, q, true
+			}
+		}
+		// Unterminated quote: treat the rest of the line as an unquoted value.
+	}
+	// An unquoted value ends before a " #" comment and trailing blanks.
+	if c := bytes.Index(line[start:stop], []byte(" #")); c >= 0 {
+		stop = start + c
+	}
+	for stop > start && (line[stop-1] == ' ' || line[stop-1] == '\t') {
+		stop--
+	}
+	return start, stop, 0, true
+}
+
+func skipBlanks(line []byte, i int) int {
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// quoteEnvValue renders value in the given quoting style.
+func quoteEnvValue(value string, quote byte) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%w: value contains a newline", ErrEnvValue)
+	}
+	switch quote {
+	case '"':
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		return `"` + r.Replace(value) + `"`, nil
+	case '\'':
+		if strings.Contains(value, "'") {
+			return "", fmt.Errorf("%w: value contains ' but the line uses single quotes", ErrEnvValue)
+		}
+		return "'" + value + "'", nil
+	}
+	if strings.Contains(value, " #") || strings.TrimSpace(value) != value {
+		return "", fmt.Errorf("%w: value needs quoting but the line is unquoted", ErrEnvValue)
+	}
+	return value, nil
+}
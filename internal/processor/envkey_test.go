@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubstituteEnvKey_PreservesStyle(t *testing.T) {
+	src := "# endpoints\nAPI_URL=http://old\nexport API_URL = \"http://old\" # quoted\nAPI_URL_V2=keep\napi.url: 'x'\nAPI_URL: plain # note\r\n"
+	want := "# endpoints\nAPI_URL=https://new\nexport API_URL = \"https://new\" # quoted\nAPI_URL_V2=keep\napi.url: 'x'\nAPI_URL: https://new # note\r\n"
+	res, err := SubstituteEnvKey([]byte(src), "API_URL", "https://new")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(res.After) != want {
+		t.Fatalf("got  %q\nwant %q", res.After, want)
+	}
+	if res.Matches != 3 || !res.Changed {
+		t.Fatalf("matches=%d changed=%v", res.Matches, res.Changed)
+	}
+}
+
+func TestSubstituteEnvKey_QuotingErrors(t *testing.T) {
+	if res, err := SubstituteEnvKey([]byte(`K="a"`), "K", `say "hi"`); err != nil || string(res.After) != `K="say \"hi\""` {
+		t.Fatalf("double quotes should be escaped: %q, %v", res.After, err)
+	}
+	for _, tc := range []struct{ src, value string }{
+		{"K='a'\n", "it's"},
+		{"K=a\n", "two words # not a comment"},
+		{"K=a\n", "line\nbreak"},
+		{"K=a \\\n  b\n", "c"},
+	} {
+		if _, err := SubstituteEnvKey([]byte(tc.src), "K", tc.value); !errors.Is(err, ErrEnvValue) {
+			t.Errorf("%q <- %q: expected ErrEnvValue, got %v", tc.src, tc.value, err)
+		}
+	}
+}
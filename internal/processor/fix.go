@@ -0,0 +1,264 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Fix computes the edits of a built-in cleanup of data, such as trimming
+// trailing whitespace, in place of a pattern.
+type Fix func(data []byte) []Edit
+
+// TrailingWhitespace removes spaces and tabs before each line ending and at
+// the end of data.
+func TrailingWhitespace(data []byte) []Edit {
+	var edits []Edit
+	for off := 0; off <= len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += off
+		}
+		stop := end
+		if stop > off && data[stop-1] == '\r' {
+			stop--
+		}
+		start := stop
+		for start > off && (data[start-1] == ' ' || data[start-1] == '\t') {
+			start--
+		}
+		if start < stop {
+			edits = append(edits, Edit{Start: start, End: stop})
+		}
+		off = end + 1
+	}
+	return edits
+}
+
+// FinalNewline ends non-empty data that lacks one with a line ending, CRLF
+// when data already uses CRLF.
+func FinalNewline(data []byte) []Edit {
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	eol := []byte("\n")
+	if bytes.Contains(data, []byte("\r\n")) {
+		eol = []byte("\r\n")
+	}
+	return []Edit{{Start: len(data), End: len(data), Text: eol}}
+}
+
+// FixFile reads the file and applies fixes with ApplyFixes. It does NOT
+// write changes back to disk.
+func FixFile(path string, fixes []Fix, opts Options) (Result, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
+	}
+	return ApplyFixes(data, fixes), nil
+}
+
+// ApplyFixes applies the edits of all fixes to data. Each edit counts as one
+// match and replacement; an edit overlapping an earlier one is dropped.
+func ApplyFixes(data []byte, fixes []Fix) Result {
+	var all []Edit
+	for _, f := range fixes {
+		all = append(all, f(data)...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Start < all[j].Start })
+	var edits []Edit
+	for _, e := range all {
+		if n := len(edits); n > 0 && e.Start < edits[n-1].End {
+			continue
+		}
+		edits = append(edits, e)
+	}
+	res := Result{Before: data, After: data, Binary: IsBinary(data), Edits: edits}
+	if len(edits) > 0 {
+		res.After = applyEdits(data, edits)
+	}
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = !bytes.Equal(data, res.After)
+	return res
+}
+
+// Retab returns a Fix converting the indentation at the start of each line.
+// Columns are counted with tab stops every width columns. With tabs unset the
+// indentation becomes spaces; otherwise it becomes tabs, with spaces for a
+// remainder narrower than a tab. Lines starting inside a multi-line string
+// (a backtick or triple-quoted literal, by a heuristic that ignores comments and
+// escapes) are left alone.
+func Retab(width int, tabs bool) Fix {
+	return func(data []byte) []Edit {
+		var edits []Edit
+		var open string // delimiter of the multi-line string a line starts in
+		for off := 0; off < len(data); {
+			end := bytes.IndexByte(data[off:], '\n')
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += off
+			}
+			line := data[off:end]
+			if open == "" {
+				n, col := 0, 0
+				for ; n < len(line) && (line[n] == ' ' || line[n] == '\t'); n++ {
+					if line[n] == '\t' {
+						col += width - col%width
+					} else {
+						col++
+					}
+				}
+				// Whitespace-only lines are left to --fix-trailing-whitespace.
+				if n < len(line) && line[n] != '\r' {
+					indent := strings.Repeat(" ", col)
+					if tabs {
+						indent = strings.Repeat("\t", col/width) + strings.Repeat(" ", col%width)
+					}
+					if indent != string(line[:n]) {
+						edits = append(edits, Edit{Start: off, End: off + n, Text: []byte(indent)})
+					}
+				}
+			}
+			open = openString(line, open)
+			off = end + 1
+		}
+		return edits
+	}
+}
+
+// openString scans line for multi-line string delimiters, starting inside
+// the string closed by open (if not empty), and returns the delimiter of the
+// string still open at its end.
+func openString(line []byte, open string) string {
+	for i := 0; i < len(line); {
+		if open != "" {
+			j := bytes.Index(line[i:], []byte(open))
+			if j < 0 {
+				return open
+			}
+			i += j + len(open)
+			open = ""
+			continue
+		}
+		j := bytes.IndexAny(line[i:], "`\"'")
+		if j < 0 {
+			return ""
+		}
+		i += j
+		switch rest := line[i:]; {
+		case bytes.HasPrefix(rest, []byte(`"""`)), bytes.HasPrefix(rest, []byte(`'''`)):
+			open = string(rest[:3])
+		case rest[0] == '`':
+			open = "`"
+		case rest[0] == '"':
+			// A one-line string: skip it so the delimiters inside don't count.
+			k := bytes.IndexByte(rest[1:], '"')
+			if k < 0 {
+				return ""
+			}
+			i += k + 2
+			continue
+		case len(rest) >= 3 && rest[2] == '\'':
+			i += 3 // a character literal such as '`'
+			continue
+		default:
+			i++ // a lone quote, e.g. an apostrophe
+			continue
+		}
+		i += len(open)
+	}
+	return open
+}
+
+// NormalizeLines returns a Fix canonicalizing list-like content. With
+// sorted set, each block of non-blank lines is sorted bytewise; blank lines
+// stay where they are, so commented sections of files like .gitignore keep
+// their heading. With unique set, a non-blank line equal to an earlier one is
+// removed. Each rewritten block is one edit.
+func NormalizeLines(sorted, unique bool) Fix {
+	return func(data []byte) []Edit {
+		var edits []Edit
+		seen := map[string]bool{}
+		for _, b := range lineBlocks(data) {
+			var kept [][]byte
+			for _, l := range b.lines {
+				if unique {
+					if seen[string(l)] {
+						continue
+					}
+					seen[string(l)] = true
+				}
+				kept = append(kept, l)
+			}
+			if sorted {
+				slices.SortStableFunc(kept, bytes.Compare)
+			}
+			if len(kept) == 0 {
+				edits = append(edits, Edit{Start: b.start, End: b.next})
+				continue
+			}
+			text := bytes.Join(kept, b.eol)
+			if !bytes.Equal(text, data[b.start:b.end]) {
+				edits = append(edits, Edit{Start: b.start, End: b.end, Text: text})
+			}
+		}
+		return edits
+	}
+}
+
+// lineBlock is a run of non-blank lines: data[start:end] holds the lines
+// joined by eol, without the last line's ending, and next is the offset after
+// that ending.
+type lineBlock struct {
+	start, end, next int
+	lines            [][]byte
+	eol              []byte
+}
+
+// lineBlocks splits data into blocks of non-blank lines. Lines are stored
+// without their ending; a block uses CRLF if its first line does.
+func lineBlocks(data []byte) []lineBlock {
+	var blocks []lineBlock
+	var cur *lineBlock
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		next := len(data)
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += off
+			next = end + 1
+		}
+		crlf := end > off && data[end-1] == '\r'
+		text := data[off:end]
+		if crlf {
+			text = text[:len(text)-1]
+		}
+		if len(bytes.TrimSpace(text)) == 0 {
+			cur = nil
+			off = next
+			continue
+		}
+		if cur == nil {
+			eol := []byte("\n")
+			if crlf {
+				eol = []byte("\r\n")
+			}
+			blocks = append(blocks, lineBlock{start: off, eol: eol})
+			cur = &blocks[len(blocks)-1]
+		}
+		cur.lines = append(cur.lines, text)
+		cur.end, cur.next = off+len(text), next
+		off = next
+	}
+	return blocks
+}
@@ -0,0 +1,82 @@
+package processor
+
+import "testing"
+
+func TestTrailingWhitespace(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"a  \nb\t\r\nc", "a\nb\r\nc"},
+		{"clean\n", "clean\n"},
+		{"end \t", "end"},
+		{"  \n\n", "\n\n"},
+		{"", ""},
+	} {
+		res := ApplyFixes([]byte(tc.in), []Fix{TrailingWhitespace})
+		if string(res.After) != tc.want || res.Changed != (tc.in != tc.want) {
+			t.Errorf("%q: got %q (changed %v) want %q", tc.in, res.After, res.Changed, tc.want)
+		}
+	}
+	if res := ApplyFixes([]byte("a \nb \n"), []Fix{TrailingWhitespace}); res.Replacements != 2 || len(res.Edits) != 2 {
+		t.Fatalf("counts: %+v", res)
+	}
+}
+
+func TestFinalNewline(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"a", "a\n"},
+		{"a\r\nb", "a\r\nb\r\n"},
+		{"a\n", "a\n"},
+		{"", ""},
+	} {
+		if res := ApplyFixes([]byte(tc.in), []Fix{FinalNewline}); string(res.After) != tc.want {
+			t.Errorf("%q: got %q want %q", tc.in, res.After, tc.want)
+		}
+	}
+	// Both fixes touch the end of the file.
+	if res := ApplyFixes([]byte("a \t"), []Fix{TrailingWhitespace, FinalNewline}); string(res.After) != "a\n" || res.Replacements != 2 {
+		t.Fatalf("combined: got %q (%d)", res.After, res.Replacements)
+	}
+}
+
+func TestRetab(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		tabs bool
+		want string
+	}{
+		{"\tif x {\n\t\treturn\n\t}\n", false, "    if x {\n        return\n    }\n"},
+		{"  \tx\r\n", false, "    x\r\n"},
+		{"        x\n      y\n", true, "\t\tx\n\t  y\n"},
+		{"\t\n", false, "\t\n"},
+		{"a\tb\n", false, "a\tb\n"},
+		{"s := `\n\tkeep\n`\n\tfix()\n", false, "s := `\n\tkeep\n`\n    fix()\n"},
+		{"x = \"\"\"\n\tkeep\n\"\"\"\n", false, "x = \"\"\"\n\tkeep\n\"\"\"\n"},
+		{"r := '`'\n\tfix()\n", false, "r := '`'\n    fix()\n"},
+		{"s := \"`\"\n\tfix()\n", false, "s := \"`\"\n    fix()\n"},
+	} {
+		res := ApplyFixes([]byte(tc.in), []Fix{Retab(4, tc.tabs)})
+		if string(res.After) != tc.want {
+			t.Errorf("%q (tabs %v): got %q want %q", tc.in, tc.tabs, res.After, tc.want)
+		}
+	}
+}
+
+func TestNormalizeLines(t *testing.T) {
+	for _, tc := range []struct {
+		in           string
+		sort, unique bool
+		want         string
+	}{
+		{"b\na\nc\n", true, false, "a\nb\nc\n"},
+		{"# deps\nz\ny\n\n# build\nb\na", true, false, "# deps\ny\nz\n\n# build\na\nb"},
+		{"b\r\na\r\n", true, false, "a\r\nb\r\n"},
+		{"a\nb\na\n\nb\nc\n", false, true, "a\nb\n\nc\n"},
+		{"a\n\na\n\nb\n", false, true, "a\n\n\nb\n"},
+		{"c\na\nc\nb\n", true, true, "a\nb\nc\n"},
+		{"a\nb\n", true, true, "a\nb\n"},
+	} {
+		res := ApplyFixes([]byte(tc.in), []Fix{NormalizeLines(tc.sort, tc.unique)})
+		if string(res.After) != tc.want {
+			t.Errorf("%q (sort %v, unique %v): got %q want %q", tc.in, tc.sort, tc.unique, res.After, tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// ErrNotIdent is returned (wrapped) when a name given for identifier renaming
+// is not a valid Go identifier.
+var ErrNotIdent = errors.New("not a Go identifier")
+
+// SubstituteGoIdentFile reads the Go source file at path and renames identifiers
+// with SubstituteGoIdent. It does NOT write changes back to disk.
+func SubstituteGoIdentFile(path, old, new string, opts Options) (Result, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
+	}
+	return SubstituteGoIdent(path, data, old, new)
+}
+
+// SubstituteGoIdent renames every identifier named old in the Go source data to
+// new, including selector names (x.old) and declarations. Comments, string
+// literals and identifiers that merely contain old are left alone. filename is
+// only used in parse errors; source that does not parse is an error.
+func SubstituteGoIdent(filename string, data []byte, old, new string) (Result, error) {
+	for _, name := range []string{old, new} {
+		if !token.IsIdentifier(name) {
+			return Result{}, fmt.Errorf("%w: %q", ErrNotIdent, name)
+		}
+	}
+	res := Result{Before: data, After: data}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, data, parser.SkipObjectResolution)
+	if err != nil {
+		return res, err
+	}
+	var edits []Edit
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == old {
+			start := fset.Position(id.Pos()).Offset
+			edits = append(edits, Edit{Start: start, End: start + len(old), Text: []byte(new)})
+		}
+		return true
+	})
+	if len(edits) == 0 {
+		return res, nil
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	res.After = applyEdits(data, edits)
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = old != new
+	res.Edits = edits
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubstituteGoIdent_RenamesIdentsOnly(t *testing.T) {
+	src := `package p
+
+// Foo does things; Foo is mentioned here.
+type Foo struct{ Foo int }
+
+func NewFoo() *Foo {
+	f := &Foo{Foo: 1}
+	_ = "Foo"
+	return f
+}
+
+func use(x Foo) int { return x.Foo }
+`
+	want := `package p
+
+// Foo does things; Foo is mentioned here.
+type Bar struct{ Bar int }
+
+func NewFoo() *Bar {
+	f := &Bar{Bar: 1}
+	_ = "Foo"
+	return f
+}
+
+func use(x Bar) int { return x.Bar }
+`
+	res, err := SubstituteGoIdent("p.go", []byte(src), "Foo", "Bar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(res.After) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", res.After, want)
+	}
+	if !res.Changed || res.Matches != 7 || res.Replacements != 7 {
+		t.Fatalf("changed=%v matches=%d replacements=%d", res.Changed, res.Matches, res.Replacements)
+	}
+}
+
+func TestSubstituteGoIdent_Errors(t *testing.T) {
+	if _, err := SubstituteGoIdent("p.go", []byte("package p\n"), "Foo", "not-ident"); !errors.Is(err, ErrNotIdent) {
+		t.Fatalf("expected ErrNotIdent, got %v", err)
+	}
+	if _, err := SubstituteGoIdent("p.go", []byte("package p\nfunc {"), "Foo", "Bar"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// quote is a string literal delimiter of a language.
+type quote struct {
+	delim string
+	// escape is set when a backslash escapes the next byte.
+	escape bool
+	// multiline is set when the literal may span lines; otherwise an
+	// unclosed literal ends at the end of its line.
+	multiline bool
+}
+
+// lexer describes just enough of a language to find its string literals:
+// their delimiters, and its comments, whose quotes open no literal.
+type lexer struct {
+	// quotes are tried in order, so longer delimiters come first.
+	quotes []quote
+	line   []string
+	block  [][2]string
+	// hashWord makes "#" start a comment only at the start of a word, as in
+	// shells, where "$#" is not one.
+	hashWord bool
+}
+
+var (
+	cLexer = lexer{
+		quotes: []quote{{delim: `"`, escape: true}, {delim: `'`, escape: true}},
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+	}
+	goLexer = lexer{
+		quotes: []quote{{delim: `"`, escape: true}, {delim: `'`, escape: true}, {delim: "`", multiline: true}},
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+	}
+	jsLexer = lexer{
+		quotes: []quote{{delim: `"`, escape: true}, {delim: `'`, escape: true}, {delim: "`", escape: true, multiline: true}},
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+	}
+	// Rust uses ' for lifetimes as well as characters.
+	rustLexer = lexer{
+		quotes: []quote{{delim: `"`, escape: true, multiline: true}},
+		line:   []string{"//"},
+		block:  [][2]string{{"/*", "*/"}},
+	}
+	pythonLexer = lexer{
+		quotes: []quote{{delim: `"""`, escape: true, multiline: true}, {delim: `'''`, escape: true, multiline: true}, {delim: `"`, escape: true}, {delim: `'`, escape: true}},
+		line:   []string{"#"},
+	}
+	rubyLexer = lexer{
+		quotes: []quote{{delim: `"`, escape: true, multiline: true}, {delim: `'`, escape: true, multiline: true}},
+		line:   []string{"#"},
+	}
+	shellLexer = lexer{
+		quotes:   []quote{{delim: `"`, escape: true, multiline: true}, {delim: `'`, multiline: true}},
+		line:     []string{"#"},
+		hashWord: true,
+	}
+)
+
+// lexers maps lowercase file extensions to their language.
+var lexers = map[string]*lexer{
+	".go":   &goLexer,
+	".c":    &cLexer,
+	".h":    &cLexer,
+	".cc":   &cLexer,
+	".cpp":  &cLexer,
+	".hpp":  &cLexer,
+	".java": &cLexer,
+	".kt":   &cLexer,
+	".cs":   &cLexer,
+	".js":   &jsLexer,
+	".mjs":  &jsLexer,
+	".cjs":  &jsLexer,
+	".jsx":  &jsLexer,
+	".ts":   &jsLexer,
+	".tsx":  &jsLexer,
+	".rs":   &rustLexer,
+	".py":   &pythonLexer,
+	".rb":   &rubyLexer,
+	".sh":   &shellLexer,
+	".bash": &shellLexer,
+	".zsh":  &shellLexer,
+}
+
+// OutsideStrings returns a scope excluding the string literals (with their
+// quotes) of the language of the file named name, guessed from its
+// extension, or nil when the language is not known. It is a heuristic
+// lexer: it knows quotes, escapes and comments, but not constructs such as
+// raw strings with custom delimiters, heredocs or regular expression
+// literals.
+func OutsideStrings(name string) Scope {
+	lx, ok := lexers[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return nil
+	}
+	return func(data []byte) ([]Span, error) {
+		var out []Span
+		start := 0
+		for off := 0; off < len(data); {
+			if end, ok := lx.comment(data, off); ok {
+				off = end
+				continue
+			}
+			q, ok := lx.quoteAt(data, off)
+			if !ok {
+				off++
+				continue
+			}
+			out = appendSpan(out, start, off)
+			off = q.skip(data, off)
+			start = off
+		}
+		return appendSpan(out, start, len(data)), nil
+	}
+}
+
+// comment returns the end of the comment starting at data[off:], if any.
+func (lx *lexer) comment(data []byte, off int) (int, bool) {
+	for _, c := range lx.line {
+		if !bytes.HasPrefix(data[off:], []byte(c)) {
+			continue
+		}
+		if c == "#" && lx.hashWord && off > 0 && !isSpace(data[off-1]) {
+			continue
+		}
+		if i := bytes.IndexByte(data[off:], '\n'); i >= 0 {
+			return off + i, true
+		}
+		return len(data), true
+	}
+	for _, c := range lx.block {
+		if !bytes.HasPrefix(data[off:], []byte(c[0])) {
+			continue
+		}
+		if i := bytes.Index(data[off+len(c[0]):], []byte(c[1])); i >= 0 {
+			return off + len(c[0]) + i + len(c[1]), true
+		}
+		return len(data), true
+	}
+	return 0, false
+}
+
+// quoteAt returns the delimiter opening a literal at data[off:], if any.
+func (lx *lexer) quoteAt(data []byte, off int) (quote, bool) {
+	for _, q := range lx.quotes {
+		if bytes.HasPrefix(data[off:], []byte(q.delim)) {
+			return q, true
+		}
+	}
+	return quote{}, false
+}
+
+// skip returns the offset just past the literal opening at data[off:]: after
+// its closing delimiter, or at the end of the line (or data) when unclosed.
+func (q quote) skip(data []byte, off int) int {
+	i := off + len(q.delim)
+	for i < len(data) {
+		switch {
+		case q.escape && data[i] == '\\':
+			i += 2
+		case bytes.HasPrefix(data[i:], []byte(q.delim)):
+			return i + len(q.delim)
+		case data[i] == '\n' && !q.multiline:
+			return i
+		default:
+			i++
+		}
+	}
+	return len(data)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
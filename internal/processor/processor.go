@@ -2,46 +2,423 @@ package processor
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Result holds the outcome of processing one file. Content is kept as raw
+// bytes end to end, so NUL bytes and invalid UTF-8 survive unchanged.
 type Result struct {
-	Before       string
-	After        string
+	Before       []byte
+	After        []byte
 	Matches      int
 	Replacements int
 	Changed      bool
+	// Binary is set when the content contains NUL bytes (only processed with Options.AllowBinary).
+	Binary bool
+	// Edits lists the replacements in order of position in Before.
+	Edits []Edit
+}
+
+// Edit is one replacement: Before[Start:End] was replaced by Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  []byte
+}
+
+// Options tune how a file is processed.
+type Options struct {
+	// AllowBinary processes files containing NUL bytes instead of failing with ErrBinary.
+	AllowBinary bool
+	// Scope, if set, restricts replacements to the spans it returns for the content.
+	Scope Scope
+	// IgnoreCase matches literal patterns under Unicode simple case folding,
+	// so "foo" also matches "Foo" and "FOO".
+	IgnoreCase bool
+	// Word matches literal patterns only as whole words: between a non-word
+	// character (anything but a letter, digit or _) or the edge of the
+	// content on either side.
+	Word bool
+	// LineStart and LineEnd match literal patterns only at the start or end
+	// of a line (before "\n" or "\r\n", or at the edge of the content).
+	LineStart bool
+	LineEnd   bool
+}
+
+// ErrBinary is returned (wrapped) for files that look binary.
+var ErrBinary = errors.New("skipping binary file")
+
+// ErrNotRegular is returned (wrapped) for FIFOs, sockets and devices, which
+// are refused before being opened: reading them could block or never end.
+var ErrNotRegular = errors.New("refusing to process non-regular file")
+
+// ErrUnstable is returned (wrapped) for files that kept changing while they
+// were read, so no consistent version of them could be read.
+var ErrUnstable = errors.New("file kept changing while being read")
+
+// maxReadAttempts bounds how often ReadFile reads a file that changes under it.
+const maxReadAttempts = 3
+
+// ReadFile reads a regular file, failing with ErrNotRegular for anything else.
+// A file whose size or modification time changed while it was read is read
+// again, so the content never mixes bytes of two versions; one that keeps
+// changing fails with ErrUnstable.
+func ReadFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrNotRegular, path, fileKind(info.Mode()))
+	}
+	return readStable(path, info, os.ReadFile)
+}
+
+// readStable reads path with read until its size and modification time are
+// the same after reading as before, starting from info.
+func readStable(path string, info os.FileInfo, read func(string) ([]byte, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		data, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		after, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if after.Size() == info.Size() && after.ModTime().Equal(info.ModTime()) {
+			return data, nil
+		}
+		if attempt == maxReadAttempts {
+			return nil, fmt.Errorf("%w: %s (%d attempts)", ErrUnstable, path, attempt)
+		}
+		info = after
+	}
+}
+
+// fileKind names the type of a non-regular file.
+func fileKind(m os.FileMode) string {
+	switch {
+	case m&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case m&os.ModeSocket != 0:
+		return "socket"
+	case m&os.ModeCharDevice != 0:
+		return "character device"
+	case m&os.ModeDevice != 0:
+		return "block device"
+	case m.IsDir():
+		return "directory"
+	}
+	return "irregular file"
 }
 
 // SubstituteLiteralFile reads the file, does in-memory literal replacement,
 // and returns a Result. It does NOT write changes back to disk.
 func SubstituteLiteralFile(path, pattern, repl string) (Result, error) {
-	data, err := os.ReadFile(path)
+	return SubstituteLiteralFileWithOptions(path, pattern, repl, Options{})
+}
+
+// SubstituteLiteralFileWithOptions is SubstituteLiteralFile with explicit Options.
+func SubstituteLiteralFileWithOptions(path, pattern, repl string, opts Options) (Result, error) {
+	return SubstituteLiteralsFile(path, []Replacement{{Pattern: []byte(pattern), Replace: []byte(repl)}}, opts)
+}
+
+// IsBinary reports whether data contains a NUL byte.
+func IsBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0x00) >= 0
+}
+
+// SubstituteLiteral replaces every non-overlapping occurrence of pattern in data,
+// scanning left to right. data is not modified; After is a new slice when changed.
+func SubstituteLiteral(data, pattern, repl []byte) Result {
+	return SubstituteLiteralInSpans(data, pattern, repl, []Span{{Start: 0, End: len(data)}})
+}
+
+// SubstituteLiteralInSpans is SubstituteLiteral restricted to the given sorted,
+// non-overlapping spans: each span is scanned on its own, so a match never
+// crosses a span boundary.
+func SubstituteLiteralInSpans(data, pattern, repl []byte, spans []Span) Result {
+	return SubstituteLiteralsInSpans(data, []Replacement{{Pattern: pattern, Replace: repl}}, spans)
+}
+
+// Replacement pairs a literal pattern with the text replacing it, for
+// replacing several patterns in one pass.
+type Replacement struct {
+	Pattern []byte
+	Replace []byte
+	// Regex, if set, is matched instead of Pattern; $1 or ${name} in Replace
+	// stand for the text of its capture groups, as with SubstituteRegex,
+	// unless NoExpand is set. Of the Options, only Scope applies to it.
+	Regex    *regexp.Regexp
+	NoExpand bool
+}
+
+// SubstituteLiteralsFile reads the file and replaces several literal
+// patterns with SubstituteLiteralsWithOptions. It does NOT write changes back
+// to disk.
+func SubstituteLiteralsFile(path string, reps []Replacement, opts Options) (Result, error) {
+	data, err := ReadFile(path)
 	if err != nil {
 		return Result{}, err
 	}
-	// quick binary check
-	if bytes.IndexByte(data, 0x00) >= 0 {
-		return Result{}, fmt.Errorf("skipping binary file: %s", path)
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
 	}
+	res, err := SubstituteLiteralsWithOptions(data, reps, opts)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
+
+// SubstituteLiterals replaces occurrences of several patterns in a single
+// left-to-right pass: at each position the earliest match wins, ties going to
+// the pattern listed first, and replaced text is never matched again. Empty
+// patterns are ignored.
+func SubstituteLiterals(data []byte, reps []Replacement) Result {
+	return SubstituteLiteralsInSpans(data, reps, []Span{{Start: 0, End: len(data)}})
+}
+
+// SubstituteLiteralsInSpans is SubstituteLiterals restricted to the given
+// sorted, non-overlapping spans, like SubstituteLiteralInSpans.
+func SubstituteLiteralsInSpans(data []byte, reps []Replacement, spans []Span) Result {
+	return substituteLiterals(data, reps, spans, Options{})
+}
+
+// SubstituteLiteralsWithOptions is SubstituteLiterals restricted to the spans
+// of opts.Scope, matching case-insensitively with opts.IgnoreCase, whole
+// words with opts.Word and at line boundaries with opts.LineStart and
+// opts.LineEnd. Case-insensitive matches may differ in length from
+// their pattern (the Kelvin sign K is three bytes), so edits span what was
+// matched. AllowBinary is not checked.
+func SubstituteLiteralsWithOptions(data []byte, reps []Replacement, opts Options) (Result, error) {
+	spans := []Span{{Start: 0, End: len(data)}}
+	if opts.Scope != nil {
+		var err error
+		if spans, err = opts.Scope(data); err != nil {
+			return Result{}, err
+		}
+	}
+	return substituteLiterals(data, reps, spans, opts), nil
+}
+
+func substituteLiterals(data []byte, reps []Replacement, spans []Span, opts Options) Result {
+	res := Result{Before: data, After: data, Binary: IsBinary(data)}
+	var edits []Edit
+	for _, sp := range spans {
+		for _, e := range literalEdits(data[sp.Start:sp.End], reps, opts) {
+			e.Start += sp.Start
+			e.End += sp.Start
+			edits = append(edits, e)
+		}
+	}
+	if len(edits) == 0 {
+		return res
+	}
+	res.After = applyEdits(data, edits)
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = !bytes.Equal(data, res.After)
+	res.Edits = edits
+	return res
+}
+
+// KeepEdits returns res with only the edits keep accepts applied. Matches is
+// unchanged; Replacements counts the remaining edits.
+func KeepEdits(res Result, keep func(Edit) bool) Result {
+	var edits []Edit
+	for _, e := range res.Edits {
+		if keep(e) {
+			edits = append(edits, e)
+		}
+	}
+	res.Edits = edits
+	res.Replacements = len(edits)
+	res.After = res.Before
+	if len(edits) > 0 {
+		res.After = applyEdits(res.Before, edits)
+	}
+	res.Changed = !bytes.Equal(res.Before, res.After)
+	return res
+}
+
+// literalEdits finds non-overlapping occurrences of the patterns from left to
+// right, matched as opts asks. For a single pattern and zero opts these are
+// the occurrences bytes.ReplaceAll would replace.
+func literalEdits(s []byte, reps []Replacement, opts Options) []Edit {
+	var edits []Edit
+	// start[i] and end[i] cache the next occurrence of pattern i at or after
+	// off, with start[i] -1 once there is none; entries before off are stale.
+	// found[i] holds the matches of a Regex not yet passed, the first one
+	// being the cached occurrence.
+	start := make([]int, len(reps))
+	end := make([]int, len(reps))
+	found := make([][][]int, len(reps))
+	for i := range start {
+		start[i] = -2
+	}
+	for off := 0; ; {
+		best := -1
+		for i, r := range reps {
+			if r.Regex == nil && len(r.Pattern) == 0 {
+				continue
+			}
+			if start[i] != -1 && start[i] < off {
+				if r.Regex != nil {
+					if start[i] == -2 {
+						found[i] = r.Regex.FindAllSubmatchIndex(s, -1)
+					}
+					for len(found[i]) > 0 && found[i][0][0] < off {
+						found[i] = found[i][1:]
+					}
+					start[i], end[i] = -1, -1
+					if len(found[i]) > 0 {
+						start[i], end[i] = found[i][0][0], found[i][0][1]
+					}
+				} else {
+					start[i], end[i] = indexLiteral(s, off, r.Pattern, opts)
+				}
+			}
+			if start[i] >= 0 && (best < 0 || start[i] < start[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return edits
+		}
+		r := reps[best]
+		text := r.Replace
+		if r.Regex != nil && !r.NoExpand {
+			text = r.Regex.Expand(nil, r.Replace, s, found[best][0])
+		}
+		edits = append(edits, Edit{Start: start[best], End: end[best], Text: text})
+		off = end[best]
+		if start[best] == end[best] {
+			off++ // an empty match is replaced once
+		}
+	}
+}
+
+// indexLiteral returns the bounds of the first match of pat in s at or after
+// off, or -1, -1.
+func indexLiteral(s []byte, off int, pat []byte, opts Options) (int, int) {
+	for off <= len(s) {
+		var i, j int
+		if opts.IgnoreCase {
+			i, j = indexFold(s[off:], pat)
+		} else {
+			i = bytes.Index(s[off:], pat)
+			j = i + len(pat)
+		}
+		if i < 0 {
+			return -1, -1
+		}
+		i, j = i+off, j+off
+		if (!opts.Word || wholeWord(s, i, j)) && (!opts.LineStart || atLineStart(s, i)) && (!opts.LineEnd || atLineEnd(s, j)) {
+			return i, j
+		}
+		_, size := utf8.DecodeRune(s[i:])
+		off = i + size
+	}
+	return -1, -1
+}
+
+// wholeWord reports whether s[i:j] is bounded by non-word characters or the
+// edges of s.
+func wholeWord(s []byte, i, j int) bool {
+	before, _ := utf8.DecodeLastRune(s[:i])
+	after, _ := utf8.DecodeRune(s[j:])
+	return (i == 0 || !isWordRune(before)) && (j == len(s) || !isWordRune(after))
+}
+
+// atLineStart reports whether i is at the start of a line of s.
+func atLineStart(s []byte, i int) bool {
+	return i == 0 || s[i-1] == '\n'
+}
+
+// atLineEnd reports whether j is at the end of a line of s, before its "\n"
+// or "\r\n".
+func atLineEnd(s []byte, j int) bool {
+	rest := s[j:]
+	return len(rest) == 0 || rest[0] == '\n' || bytes.HasPrefix(rest, []byte("\r\n"))
+}
+
+// isWordRune reports whether r is a letter, digit or underscore.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// indexFold returns the bounds of the first match of pat in s under Unicode
+// simple case folding, or -1, -1. Matches start on rune boundaries; bytes
+// that are not valid UTF-8 only match themselves.
+func indexFold(s, pat []byte) (int, int) {
+	for i := 0; i < len(s); {
+		if n := prefixFold(s[i:], pat); n >= 0 {
+			return i, i + n
+		}
+		_, size := utf8.DecodeRune(s[i:])
+		i += size
+	}
+	return -1, -1
+}
+
+// prefixFold returns the length of the prefix of s matching pat under case
+// folding, or -1.
+func prefixFold(s, pat []byte) int {
+	n := 0
+	for len(pat) > 0 {
+		if n >= len(s) {
+			return -1
+		}
+		pr, psize := utf8.DecodeRune(pat)
+		sr, ssize := utf8.DecodeRune(s[n:])
+		if pr == utf8.RuneError && psize == 1 || sr == utf8.RuneError && ssize == 1 {
+			if psize != 1 || ssize != 1 || pat[0] != s[n] {
+				return -1
+			}
+		} else if !equalFoldRune(pr, sr) {
+			return -1
+		}
+		pat = pat[psize:]
+		n += ssize
+	}
+	return n
+}
+
+// equalFoldRune reports whether a and b are equal under simple case folding.
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	if a < utf8.RuneSelf && b < utf8.RuneSelf {
+		return 'a' <= a|0x20 && a|0x20 <= 'z' && a|0x20 == b|0x20
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
 
-	before := string(data)
-	// Empty pattern must be a no-op; otherwise ReplaceAll would inject `repl` between every rune
-	if pattern == "" {
-		return Result{Before: before, After: before, Matches: 0, Replacements: 0, Changed: false}, nil
+// applyEdits returns a copy of s with the sorted, non-overlapping edits applied.
+func applyEdits(s []byte, edits []Edit) []byte {
+	size := len(s)
+	for _, e := range edits {
+		size += len(e.Text) - (e.End - e.Start)
 	}
-	matches := strings.Count(before, pattern)
-	if matches == 0 {
-		return Result{Before: before, After: before, Matches: 0, Replacements: 0, Changed: false}, nil
+	out := make([]byte, 0, size)
+	last := 0
+	for _, e := range edits {
+		out = append(out, s[last:e.Start]...)
+		out = append(out, e.Text...)
+		last = e.End
 	}
-	after := strings.ReplaceAll(before, pattern, repl)
-	return Result{
-		Before:       before,
-		After:        after,
-		Matches:      matches,
-		Replacements: matches,
-		Changed:      before != after,
-	}, nil
+	return append(out, s[last:]...)
 }
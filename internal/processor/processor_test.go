@@ -1,8 +1,11 @@
 package processor
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"testing"
 )
@@ -29,7 +32,7 @@ func TestLiteral_NoMatches(t *testing.T) {
 	if res.Changed || res.Matches != 0 || res.Replacements != 0 {
 		t.Fatalf("unexpected: %+v", res)
 	}
-	if res.Before != res.After {
+	if !bytes.Equal(res.Before, res.After) {
 		t.Fatalf("before/after mismatch")
 	}
 }
@@ -47,7 +50,7 @@ func TestLiteral_MatchesAndReplace(t *testing.T) {
 	if res.Matches != 2 || res.Replacements != 2 {
 		t.Fatalf("counts wrong: %+v", res)
 	}
-	if want := "baz\nbar baz\n"; res.After != want {
+	if want := "baz\nbar baz\n"; string(res.After) != want {
 		t.Fatalf("after wrong: %q", res.After)
 	}
 }
@@ -61,7 +64,7 @@ func TestLiteral_PreservesCRLF(t *testing.T) {
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
-	if got, want := res.After, "bar\r\nbar\r\n"; got != want {
+	if got, want := string(res.After), "bar\r\nbar\r\n"; got != want {
 		t.Fatalf("eol changed: got %q want %q", got, want)
 	}
 }
@@ -109,7 +112,7 @@ func TestLiteral_PathWithUnicode(t *testing.T) {
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
-	if res.After != "bar" {
+	if string(res.After) != "bar" {
 		t.Fatalf("wrong after: %q", res.After)
 	}
 }
@@ -124,7 +127,7 @@ func TestLiteral_EmptyPattern_NoOp(t *testing.T) {
 	if res.Changed || res.Matches != 0 || res.Replacements != 0 {
 		t.Fatalf("empty pattern should be no-op: %+v", res)
 	}
-	if res.Before != res.After {
+	if !bytes.Equal(res.Before, res.After) {
 		t.Fatalf("before/after mismatch on empty pattern")
 	}
 }
@@ -142,7 +145,7 @@ func TestLiteral_ReplacementSameAsPattern_NoChange(t *testing.T) {
 	if res.Matches == 0 || res.Replacements == 0 {
 		t.Fatalf("expected matches and replacements to count occurrences; got: %+v", res)
 	}
-	if res.Before != res.After {
+	if !bytes.Equal(res.Before, res.After) {
 		t.Fatalf("content should be identical when replacement equals pattern")
 	}
 }
@@ -166,7 +169,207 @@ func TestLiteral_EmptyFile_NoChange(t *testing.T) {
 	if res.Changed || res.Matches != 0 || res.Replacements != 0 {
 		t.Fatalf("empty file should yield no changes: %+v", res)
 	}
-	if res.Before != "" || res.After != "" {
+	if len(res.Before) != 0 || len(res.After) != 0 {
 		t.Fatalf("expected empty before/after")
 	}
 }
+
+func TestLiteral_BinaryAllowed_RecordsEdits(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTemp(t, dir, "bin.dat", "\x00\x01ab\x00ab")
+	_, err := SubstituteLiteralFile(p, "ab", "XYZ")
+	if !errors.Is(err, ErrBinary) {
+		t.Fatalf("expected ErrBinary by default, got %v", err)
+	}
+	res, err := SubstituteLiteralFileWithOptions(p, "ab", "XYZ", Options{AllowBinary: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !res.Binary || !res.Changed || res.Matches != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if string(res.After) != "\x00\x01XYZ\x00XYZ" {
+		t.Fatalf("after wrong: %q", res.After)
+	}
+	if len(res.Edits) != 2 {
+		t.Fatalf("edits: got %+v", res.Edits)
+	}
+	for i, start := range []int{2, 5} {
+		e := res.Edits[i]
+		if e.Start != start || e.End != start+2 || string(e.Text) != "XYZ" {
+			t.Fatalf("edit %d: got %+v", i, e)
+		}
+	}
+}
+
+func TestSubstituteLiteral_NULSafe(t *testing.T) {
+	data := []byte("a\x00b\xffa\x00b")
+	res := SubstituteLiteral(data, []byte("\x00b"), []byte("\x00\x00"))
+	if !res.Binary || res.Matches != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if want := []byte("a\x00\x00\xffa\x00\x00"); !bytes.Equal(res.After, want) {
+		t.Fatalf("after: got %q want %q", res.After, want)
+	}
+	if string(data) != "a\x00b\xffa\x00b" {
+		t.Fatalf("input modified: %q", data)
+	}
+}
+
+func TestSubstituteLiterals(t *testing.T) {
+	reps := []Replacement{
+		{Pattern: []byte("ab"), Replace: []byte("1")},
+		{Pattern: []byte("abc"), Replace: []byte("2")},
+		{Pattern: []byte("c"), Replace: []byte("ab")},
+		{Pattern: nil, Replace: []byte("x")},
+	}
+	res := SubstituteLiterals([]byte("abc c xabcab"), reps)
+	// "ab" wins the tie with "abc" by order; replaced text is not rescanned.
+	if want := "1ab ab x1ab1"; string(res.After) != want {
+		t.Fatalf("got %q want %q", res.After, want)
+	}
+	if res.Matches != 6 || res.Replacements != 6 || !res.Changed || len(res.Edits) != 6 {
+		t.Fatalf("counts: %+v", res)
+	}
+
+	spans := []Span{{Start: 0, End: 3}}
+	if res := SubstituteLiteralsInSpans([]byte("abc c"), reps, spans); string(res.After) != "1ab c" {
+		t.Fatalf("spans: got %q", res.After)
+	}
+}
+
+func TestSubstituteLiterals_RegexRules(t *testing.T) {
+	reps := []Replacement{
+		{Regex: regexp.MustCompile(`id=(\d+)`), Replace: []byte("ID:$1")},
+		{Pattern: []byte("id"), Replace: []byte("key")},
+		{Regex: regexp.MustCompile(`^x`), Replace: []byte("$$"), NoExpand: true},
+	}
+	res := SubstituteLiterals([]byte("x id=12 id x"), reps)
+	// The earliest match wins whichever kind of rule found it, and ^ anchors
+	// at the start of the content only.
+	if want := "$$ ID:12 key x"; string(res.After) != want {
+		t.Fatalf("got %q want %q", res.After, want)
+	}
+	if res.Replacements != 3 {
+		t.Fatalf("replacements: %d", res.Replacements)
+	}
+
+	empty := []Replacement{{Regex: regexp.MustCompile(`b*`), Replace: []byte("-")}}
+	if res := SubstituteLiterals([]byte("abc"), empty); string(res.After) != "-a-c-" {
+		t.Fatalf("empty matches: got %q", res.After)
+	}
+}
+
+func TestSubstituteLiteralsWithOptions_IgnoreCase(t *testing.T) {
+	for _, tc := range []struct {
+		in, pattern, want string
+		matches           int
+	}{
+		{"Foo FOO foo fOo", "foo", "X X X X", 4},
+		{"STRASSE straße", "straße", "STRASSE X", 1},
+		{"ΣΊΣΥΦΟΣ σίσυφος", "σίσυφοσ", "X X", 2},
+		{"Kelvin kelvin", "KELVIN", "X X", 2}, // Kelvin sign is three bytes
+		{"a\xffB a\xfeb", "A\xffb", "X a\xfeb", 1},
+		{"foo@ foo`", "FOO@", "X foo`", 1},
+	} {
+		reps := []Replacement{{Pattern: []byte(tc.pattern), Replace: []byte("X")}}
+		res, err := SubstituteLiteralsWithOptions([]byte(tc.in), reps, Options{IgnoreCase: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.After) != tc.want || res.Matches != tc.matches || res.Replacements != tc.matches {
+			t.Errorf("%q in %q: got %q (%d) want %q (%d)", tc.pattern, tc.in, res.After, res.Matches, tc.want, tc.matches)
+		}
+	}
+	// Without IgnoreCase the match is exact.
+	reps := []Replacement{{Pattern: []byte("foo"), Replace: []byte("X")}}
+	if res, _ := SubstituteLiteralsWithOptions([]byte("Foo foo"), reps, Options{}); string(res.After) != "Foo X" {
+		t.Fatalf("exact: got %q", res.After)
+	}
+}
+
+func TestSubstituteLiteralsWithOptions_Word(t *testing.T) {
+	for _, tc := range []struct {
+		in, pattern, want string
+		fold              bool
+	}{
+		{"id idx _id id_ id.id (id)", "id", "X idx _id id_ X.X (X)", false},
+		{"id\nid", "id", "X\nX", false},
+		{"café cafés", "café", "X cafés", false},
+		{"ID Id idid", "id", "X X idid", true},
+		{"a-b xa-b a-bx", "a-b", "X xa-b a-bx", false},
+		{"foofoo foo", "foo", "foofoo X", false},
+	} {
+		reps := []Replacement{{Pattern: []byte(tc.pattern), Replace: []byte("X")}}
+		res, err := SubstituteLiteralsWithOptions([]byte(tc.in), reps, Options{Word: true, IgnoreCase: tc.fold})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.After) != tc.want {
+			t.Errorf("%q in %q: got %q want %q", tc.pattern, tc.in, res.After, tc.want)
+		}
+	}
+}
+
+func TestSubstituteLiteralsWithOptions_LineAnchors(t *testing.T) {
+	in := "# a # b\r\n  # c\nx # d #\n#"
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{LineStart: true}, "// a # b\r\n  # c\nx # d #\n//"},
+		{Options{LineEnd: true}, "# a # b\r\n  # c\nx # d //\n//"},
+		{Options{LineStart: true, LineEnd: true}, "# a # b\r\n  # c\nx # d #\n//"},
+	} {
+		reps := []Replacement{{Pattern: []byte("#"), Replace: []byte("//")}}
+		res, err := SubstituteLiteralsWithOptions([]byte(in), reps, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.After) != tc.want {
+			t.Errorf("%+v: got %q want %q", tc.opts, res.After, tc.want)
+		}
+	}
+}
+
+func TestReadStable_RereadsChangedFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(p, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := 0
+	// The first read sees a half-written file while a writer replaces it.
+	read := func(path string) ([]byte, error) {
+		reads++
+		if reads == 1 {
+			if err := os.WriteFile(path, []byte("version 2"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return []byte("vers"), nil
+		}
+		return os.ReadFile(path)
+	}
+	data, err := readStable(p, info, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "version 2" || reads != 2 {
+		t.Fatalf("got %q after %d reads", data, reads)
+	}
+
+	// A file that changes on every read gives up after maxReadAttempts.
+	info, _ = os.Stat(p)
+	reads = 0
+	grow := func(path string) ([]byte, error) {
+		reads++
+		data, _ := os.ReadFile(path)
+		return data, os.WriteFile(path, append(data, 'x'), 0o644)
+	}
+	if _, err := readStable(p, info, grow); !errors.Is(err, ErrUnstable) || reads != maxReadAttempts {
+		t.Fatalf("got %v after %d reads, want ErrUnstable after %d", err, reads, maxReadAttempts)
+	}
+}
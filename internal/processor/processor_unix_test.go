@@ -0,0 +1,23 @@
+//go:build unix
+
+package processor
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestReadFile_RefusesFIFO(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pipe.txt")
+	if err := syscall.Mkfifo(p, 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	// Reading would block forever without a writer; ReadFile must not open it.
+	_, err := SubstituteLiteralFile(p, "a", "b")
+	if !errors.Is(err, ErrNotRegular) || !strings.Contains(err.Error(), "named pipe") {
+		t.Fatalf("expected ErrNotRegular for a FIFO, got %v", err)
+	}
+}
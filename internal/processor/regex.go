@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// SubstituteRegexFile reads the file and replaces the matches of re with
+// SubstituteRegex. It does NOT write changes back to disk.
+func SubstituteRegexFile(path string, re *regexp.Regexp, repl string, expand bool, opts Options) (Result, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if IsBinary(data) && !opts.AllowBinary {
+		return Result{}, fmt.Errorf("%w: %s", ErrBinary, path)
+	}
+	if opts.Scope != nil {
+		spans, err := opts.Scope(data)
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: %w", path, err)
+		}
+		return SubstituteRegexInSpans(data, re, []byte(repl), expand, spans), nil
+	}
+	return SubstituteRegex(data, re, []byte(repl), expand), nil
+}
+
+// SubstituteRegex replaces every match of re in data with repl. With expand
+// set, $1 or ${name} in repl stand for the text of a capture group, as with
+// re.ReplaceAll ($$ is a literal $); otherwise repl is taken literally, as
+// with re.ReplaceAllLiteral. Each match counts once, including empty ones.
+func SubstituteRegex(data []byte, re *regexp.Regexp, repl []byte, expand bool) Result {
+	return SubstituteRegexInSpans(data, re, repl, expand, []Span{{Start: 0, End: len(data)}})
+}
+
+// SubstituteRegexInSpans is SubstituteRegex restricted to the given sorted,
+// non-overlapping spans, like SubstituteLiteralInSpans. Each span is matched
+// as a whole text, so ^ and $ anchor at its bounds.
+func SubstituteRegexInSpans(data []byte, re *regexp.Regexp, repl []byte, expand bool, spans []Span) Result {
+	res := Result{Before: data, After: data, Binary: IsBinary(data)}
+	var edits []Edit
+	for _, sp := range spans {
+		src := data[sp.Start:sp.End]
+		for _, m := range re.FindAllSubmatchIndex(src, -1) {
+			text := repl
+			if expand {
+				text = re.Expand(nil, repl, src, m)
+			}
+			edits = append(edits, Edit{Start: sp.Start + m[0], End: sp.Start + m[1], Text: text})
+		}
+	}
+	if len(edits) == 0 {
+		return res
+	}
+	res.After = applyEdits(data, edits)
+	res.Matches = len(edits)
+	res.Replacements = len(edits)
+	res.Changed = !bytes.Equal(data, res.After)
+	res.Edits = edits
+	return res
+}
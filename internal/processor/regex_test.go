@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestSubstituteRegex(t *testing.T) {
+	for _, tc := range []struct {
+		expr, in, repl, want string
+		matches              int
+	}{
+		{`v\d+`, "v1 and v22", "vN", "vN and vN", 2},
+		{`foo`, "bar", "x", "bar", 0},
+		{`^#.*\n`, "# c\nx\n", "", "x\n", 1},
+		{`x*`, "ab", "-", "-a-b-", 3},
+		{`\$1`, "cost $1", "${1}", "cost ${1}", 1},
+	} {
+		re := regexp.MustCompile(tc.expr)
+		res := SubstituteRegex([]byte(tc.in), re, []byte(tc.repl), false)
+		if string(res.After) != tc.want || res.Matches != tc.matches || res.Replacements != tc.matches {
+			t.Errorf("%s on %q: got %q (%d matches) want %q (%d)", tc.expr, tc.in, res.After, res.Matches, tc.want, tc.matches)
+		}
+		if want := re.ReplaceAllLiteral([]byte(tc.in), []byte(tc.repl)); !bytes.Equal(res.After, want) {
+			t.Errorf("%s on %q: differs from ReplaceAllLiteral: %q", tc.expr, tc.in, want)
+		}
+		if res.Changed != (tc.in != tc.want) {
+			t.Errorf("%s on %q: changed %v", tc.expr, tc.in, res.Changed)
+		}
+	}
+}
+
+func TestSubstituteRegexInSpans(t *testing.T) {
+	data := []byte("a1 [b2] c3 [d4]")
+	spans := []Span{{Start: 3, End: 7}, {Start: 11, End: 15}}
+	res := SubstituteRegexInSpans(data, regexp.MustCompile(`^\[(\w)`), []byte("<$1"), true, spans)
+	if want := "a1 <b2] c3 <d4]"; string(res.After) != want || res.Matches != 2 {
+		t.Fatalf("got %q (%d) want %q", res.After, res.Matches, want)
+	}
+}
+
+func TestSubstituteRegex_Expand(t *testing.T) {
+	for _, tc := range []struct{ expr, in, repl, want string }{
+		{`(\w+)@(\w+)`, "me@host", "$2:$1", "host:me"},
+		{`(?P<key>\w+)=(?P<val>\w+)`, "a=1 b=2", "${val}=${key}", "1=a 2=b"},
+		{`(\w+)`, "x", "${1}_suffix", "x_suffix"},
+		{`(\w+)`, "x", "$1_suffix", ""}, // $1_suffix names group "1_suffix"
+		{`\d`, "a1", "$$0", "a$0"},
+	} {
+		re := regexp.MustCompile(tc.expr)
+		res := SubstituteRegex([]byte(tc.in), re, []byte(tc.repl), true)
+		if string(res.After) != tc.want {
+			t.Errorf("%s on %q with %q: got %q want %q", tc.expr, tc.in, tc.repl, res.After, tc.want)
+		}
+		if want := re.ReplaceAll([]byte(tc.in), []byte(tc.repl)); !bytes.Equal(res.After, want) {
+			t.Errorf("%s on %q: differs from ReplaceAll: %q", tc.expr, tc.in, want)
+		}
+	}
+}
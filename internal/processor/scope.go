@@ -0,0 +1,147 @@
+package processor
+
+import "bytes"
+
+// Span is the byte range data[Start:End].
+type Span struct {
+	Start int
+	End   int
+}
+
+// Scope returns the sorted, non-overlapping spans of data that replacements are
+// restricted to. A match must lie entirely inside one span.
+type Scope func(data []byte) ([]Span, error)
+
+// InSpans reports whether e overlaps any of the sorted spans.
+func (e Edit) InSpans(spans []Span) bool {
+	for _, sp := range spans {
+		if e.Start < sp.End && e.End > sp.Start {
+			return true
+		}
+		if sp.Start >= e.End {
+			break
+		}
+	}
+	return false
+}
+
+// MarkdownCode scopes replacements to the contents of fenced code blocks
+// (``` or ~~~), excluding the fence lines themselves.
+func MarkdownCode(data []byte) ([]Span, error) {
+	code, _ := markdownSpans(data)
+	return code, nil
+}
+
+// MarkdownProse scopes replacements to everything outside fenced code blocks.
+func MarkdownProse(data []byte) ([]Span, error) {
+	_, prose := markdownSpans(data)
+	return prose, nil
+}
+
+// markdownSpans splits data into fenced code block contents and the rest
+// (prose, including the fence lines). An unclosed fence runs to the end of data,
+// as in CommonMark.
+func markdownSpans(data []byte) (code, prose []Span) {
+	var fence []byte // opening fence while inside a block
+	proseStart, codeStart := 0, 0
+	for off := 0; off < len(data); {
+		end := len(data)
+		if i := bytes.IndexByte(data[off:], '\n'); i >= 0 {
+			end = off + i + 1
+		}
+		line := data[off:end]
+		if fence == nil {
+			if f := fenceOf(line); f != nil {
+				fence = f
+				prose = appendSpan(prose, proseStart, end)
+				codeStart = end
+			}
+		} else if f := fenceOf(line); f != nil && f[0] == fence[0] && len(f) >= len(fence) && len(bytes.TrimSpace(line)) == len(f) {
+			code = appendSpan(code, codeStart, off)
+			fence = nil
+			proseStart = off
+		}
+		off = end
+	}
+	if fence != nil {
+		code = appendSpan(code, codeStart, len(data))
+	} else {
+		prose = appendSpan(prose, proseStart, len(data))
+	}
+	return code, prose
+}
+
+// fenceOf returns the run of ` or ~ opening line (at least three, indented at
+// most three spaces), or nil when line is not a fence.
+func fenceOf(line []byte) []byte {
+	i := 0
+	for i < len(line) && i < 3 && line[i] == ' ' {
+		i++
+	}
+	if i >= len(line) || (line[i] != '`' && line[i] != '~') {
+		return nil
+	}
+	j := i
+	for j < len(line) && line[j] == line[i] {
+		j++
+	}
+	if j-i < 3 {
+		return nil
+	}
+	// Backtick fences cannot have backticks in their info string.
+	if line[i] == '`' && bytes.IndexByte(line[j:], '`') >= 0 {
+		return nil
+	}
+	return line[i:j]
+}
+
+func appendSpan(spans []Span, start, end int) []Span {
+	if end > start {
+		spans = append(spans, Span{Start: start, End: end})
+	}
+	return spans
+}
+
+// FrontMatter scopes replacements to a leading YAML (---) or TOML (+++) front
+// matter block, excluding its delimiter lines. Files without one have no spans.
+func FrontMatter(data []byte) ([]Span, error) {
+	if fm, _, ok := frontMatter(data); ok {
+		return appendSpan(nil, fm.Start, fm.End), nil
+	}
+	return nil, nil
+}
+
+// Body scopes replacements to everything after the front matter, or the whole
+// content when there is none.
+func Body(data []byte) ([]Span, error) {
+	_, body, _ := frontMatter(data)
+	return appendSpan(nil, body, len(data)), nil
+}
+
+// frontMatter locates the front matter contents and the offset where the body
+// starts. The block must open on the first line and be closed by the same
+// delimiter ("..." also closes YAML).
+func frontMatter(data []byte) (fm Span, body int, ok bool) {
+	var delim string
+	switch {
+	case bytes.HasPrefix(data, []byte("---\n")), bytes.HasPrefix(data, []byte("---\r\n")):
+		delim = "---"
+	case bytes.HasPrefix(data, []byte("+++\n")), bytes.HasPrefix(data, []byte("+++\r\n")):
+		delim = "+++"
+	default:
+		return Span{}, 0, false
+	}
+	start := bytes.IndexByte(data, '\n') + 1
+	for off := start; off < len(data); {
+		end := len(data)
+		if i := bytes.IndexByte(data[off:], '\n'); i >= 0 {
+			end = off + i + 1
+		}
+		line := string(bytes.TrimRight(data[off:end], " \t\r\n"))
+		if line == delim || (delim == "---" && line == "...") {
+			return Span{Start: start, End: off}, end, true
+		}
+		off = end
+	}
+	return Span{}, 0, false
+}
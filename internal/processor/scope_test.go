@@ -0,0 +1,76 @@
+package processor
+
+import "testing"
+
+const markdownDoc = "Use foo here.\n\n```go\nfoo()\n```\n\nMore foo.\n~~~~\nfoo\n```\nfoo\n~~~~\n"
+
+func TestMarkdownScopes(t *testing.T) {
+	spans, _ := MarkdownCode([]byte(markdownDoc))
+	code := SubstituteLiteralInSpans([]byte(markdownDoc), []byte("foo"), []byte("bar"), spans)
+	if want := "Use foo here.\n\n```go\nbar()\n```\n\nMore foo.\n~~~~\nbar\n```\nbar\n~~~~\n"; string(code.After) != want {
+		t.Fatalf("code scope:\ngot  %q\nwant %q", code.After, want)
+	}
+	spans, _ = MarkdownProse([]byte(markdownDoc))
+	prose := SubstituteLiteralInSpans([]byte(markdownDoc), []byte("foo"), []byte("bar"), spans)
+	if want := "Use bar here.\n\n```go\nfoo()\n```\n\nMore bar.\n~~~~\nfoo\n```\nfoo\n~~~~\n"; string(prose.After) != want {
+		t.Fatalf("prose scope:\ngot  %q\nwant %q", prose.After, want)
+	}
+}
+
+func TestMarkdownCode_UnclosedFenceRunsToEnd(t *testing.T) {
+	doc := []byte("text\n```\ncode\n")
+	spans, _ := MarkdownCode(doc)
+	if len(spans) != 1 || string(doc[spans[0].Start:spans[0].End]) != "code\n" {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+}
+
+func TestFrontMatterScopes(t *testing.T) {
+	doc := []byte("---\ntags: [golang]\n---\nWe love golang.\n")
+	fm, _ := FrontMatter(doc)
+	if got := SubstituteLiteralInSpans(doc, []byte("golang"), []byte("go"), fm).After; string(got) != "---\ntags: [go]\n---\nWe love golang.\n" {
+		t.Fatalf("frontmatter: %q", got)
+	}
+	body, _ := Body(doc)
+	if got := SubstituteLiteralInSpans(doc, []byte("golang"), []byte("go"), body).After; string(got) != "---\ntags: [golang]\n---\nWe love go.\n" {
+		t.Fatalf("body: %q", got)
+	}
+
+	toml := []byte("+++\ntitle = \"x\"\n+++\nx\n")
+	if fm, _ := FrontMatter(toml); len(fm) != 1 || string(toml[fm[0].Start:fm[0].End]) != "title = \"x\"\n" {
+		t.Fatalf("toml front matter: %+v", fm)
+	}
+	plain := []byte("no front matter\n---\n")
+	if fm, _ := FrontMatter(plain); len(fm) != 0 {
+		t.Fatalf("expected no front matter, got %+v", fm)
+	}
+	if body, _ := Body(plain); len(body) != 1 || body[0] != (Span{0, len(plain)}) {
+		t.Fatalf("body without front matter: %+v", body)
+	}
+}
+
+func TestOutsideStrings(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"a.go", "msg := \"user name\" // name's\nname = `raw\nname`\n", "msg := \"user name\" // id's\nid = `raw\nname`\n"},
+		{"a.py", "name = 'name' + \"\"\"\nname\"\"\"  # don't\nname\n", "id = 'name' + \"\"\"\nname\"\"\"  # don't\nid\n"},
+		{"a.js", "f(name, `name ${x}`, \"na\\\"me name\") /* 'name */ name\n", "f(id, `name ${x}`, \"na\\\"me name\") /* 'id */ id\n"},
+		{"a.sh", "echo \"$# name\" 'name'; name=1 # it's\nname\n", "echo \"$# name\" 'name'; id=1 # it's\nid\n"},
+		// An unclosed literal ends with its line.
+		{"a.c", "puts(\"name);\nname;\n", "puts(\"name);\nid;\n"},
+	}
+	for _, c := range cases {
+		scope := OutsideStrings(c.name)
+		spans, err := scope([]byte(c.src))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := SubstituteLiteralInSpans([]byte(c.src), []byte("name"), []byte("id"), spans).After; string(got) != c.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", c.name, got, c.want)
+		}
+	}
+	if OutsideStrings("notes.txt") != nil {
+		t.Error("unknown language: expected no scope")
+	}
+}
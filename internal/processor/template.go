@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Delims are the opening and closing markers of a template expression.
+type Delims struct {
+	Open  string
+	Close string
+}
+
+// DefaultTemplateDelims covers Go/Jinja/Handlebars ({{ }}), JavaScript and
+// shell interpolation (${ }) and ERB/EJS/JSP (<% %>).
+var DefaultTemplateDelims = []Delims{{"{{", "}}"}, {"${", "}"}, {"<%", "%>"}}
+
+// ParseDelims parses "OPEN...CLOSE", e.g. "{{...}}".
+func ParseDelims(s string) (Delims, error) {
+	open, close, ok := strings.Cut(s, "...")
+	if !ok || open == "" || close == "" {
+		return Delims{}, fmt.Errorf("template delimiters: want OPEN...CLOSE, got %q", s)
+	}
+	return Delims{Open: open, Close: close}, nil
+}
+
+func (d Delims) String() string { return d.Open + " " + d.Close }
+
+// TemplateSpans returns the spans of data enclosed by any of delims, markers
+// included, in order. Expressions do not nest: each ends at the first closing
+// marker after its opening one. An unclosed expression runs to the end of data.
+func TemplateSpans(data []byte, delims []Delims) []Span {
+	var spans []Span
+	for off := 0; off < len(data); {
+		start, which := -1, Delims{}
+		for _, d := range delims {
+			if i := bytes.Index(data[off:], []byte(d.Open)); i >= 0 && (start < 0 || off+i < start) {
+				start, which = off+i, d
+			}
+		}
+		if start < 0 {
+			break
+		}
+		end := len(data)
+		if i := bytes.Index(data[start+len(which.Open):], []byte(which.Close)); i >= 0 {
+			end = start + len(which.Open) + i + len(which.Close)
+		}
+		spans = append(spans, Span{Start: start, End: end})
+		off = end
+	}
+	return spans
+}
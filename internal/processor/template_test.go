@@ -0,0 +1,42 @@
+package processor
+
+import "testing"
+
+func TestTemplateSpans(t *testing.T) {
+	data := []byte("name {{ .name }} is ${name} <%= name %> {{ name")
+	spans := TemplateSpans(data, DefaultTemplateDelims)
+	var got []string
+	for _, sp := range spans {
+		got = append(got, string(data[sp.Start:sp.End]))
+	}
+	want := []string{"{{ .name }}", "${name}", "<%= name %>", "{{ name"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q want %q", got, want)
+		}
+	}
+}
+
+func TestKeepEdits_SkipsTemplateMatches(t *testing.T) {
+	data := []byte("name {{ name }} name")
+	res := SubstituteLiteral(data, []byte("name"), []byte("title"))
+	spans := TemplateSpans(data, DefaultTemplateDelims)
+	kept := KeepEdits(res, func(e Edit) bool { return !e.InSpans(spans) })
+	if string(kept.After) != "title {{ name }} title" || kept.Matches != 3 || kept.Replacements != 2 {
+		t.Fatalf("after=%q matches=%d replacements=%d", kept.After, kept.Matches, kept.Replacements)
+	}
+}
+
+func TestParseDelims(t *testing.T) {
+	if d, err := ParseDelims("[[...]]"); err != nil || d != (Delims{"[[", "]]"}) {
+		t.Fatalf("got %+v, %v", d, err)
+	}
+	for _, bad := range []string{"{{", "...}}", "{{..."} {
+		if _, err := ParseDelims(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
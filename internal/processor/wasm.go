@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Wasm is a transformer compiled from a WASI command module. Each request
+// runs in a fresh instance inside this process, with the request on stdin
+// and the response read from stdout. The instance gets no preopened
+// directories, environment or sockets, so it cannot reach the file system or
+// the network. It is safe for concurrent use.
+type Wasm struct {
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+}
+
+// NewWasm compiles the WebAssembly binary code. Close releases it.
+func NewWasm(ctx context.Context, code []byte) (*Wasm, error) {
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	m, err := r.CompileModule(ctx, code)
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	return &Wasm{runtime: r, module: m}, nil
+}
+
+// Transform runs the module's _start for req.
+func (w *Wasm) Transform(ctx context.Context, req []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	// An empty name lets instances for concurrent requests coexist.
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs("transform").
+		WithStdin(bytes.NewReader(req)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+	mod, err := w.runtime.InstantiateModule(ctx, w.module, cfg)
+	if mod != nil {
+		_ = mod.Close(ctx)
+	}
+	if exit := (*sys.ExitError)(nil); errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// Close releases the compiled module and its runtime.
+func (w *Wasm) Close(ctx context.Context) error {
+	return w.runtime.Close(ctx)
+}
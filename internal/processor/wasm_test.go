@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"context"
+	"testing"
+)
+
+// helloWasm is a WASI command module that ignores its request and answers
+// {"content":"SEVMTE8K"}, base64("HELLO\n"), with a single fd_write.
+const helloWasm = "\x00\x61\x73\x6d\x01\x00\x00\x00\x01\x0c\x02\x60\x04\x7f\x7f\x7f\x7f\x01\x7f\x60\x00\x00\x02\x23\x01\x16\x77\x61\x73\x69\x5f\x73\x6e\x61\x70\x73\x68\x6f\x74\x5f\x70\x72\x65\x76\x69\x65\x77\x31\x08\x66\x64\x5f\x77\x72\x69\x74\x65\x00\x00\x03\x02\x01\x01\x05\x03\x01\x00\x01\x07\x13\x02\x06\x6d\x65\x6d\x6f\x72\x79\x02\x00\x06\x5f\x73\x74\x61\x72\x74\x00\x01\x0a\x0f\x01\x0d\x00\x41\x01\x41\x00\x41\x01\x41\x08\x10\x00\x1a\x0b\x0b\x29\x02\x00\x41\x00\x0b\x08\x10\x00\x00\x00\x16\x00\x00\x00\x00\x41\x10\x0b\x16\x7b\x22\x63\x6f\x6e\x74\x65\x6e\x74\x22\x3a\x22\x53\x45\x56\x4d\x54\x45\x38\x4b\x22\x7d"
+
+func TestWasm(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewWasm(ctx, []byte(helloWasm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close(ctx)
+	for range 2 {
+		res, err := SubstituteCommand(ctx, w, "a.txt", []byte("hello\n"), "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Changed || string(res.After) != "HELLO\n" {
+			t.Fatalf("got %+v", res)
+		}
+	}
+}
+
+func TestNewWasm_Invalid(t *testing.T) {
+	if _, err := NewWasm(context.Background(), []byte("\x00asm\x01\x00\x00\x00\x01")); err == nil {
+		t.Fatal("expected a compile error")
+	}
+}
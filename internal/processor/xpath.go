@@ -0,0 +1,336 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XPathScope compiles a small XPath subset into a Scope over XML or HTML
+// content. Supported expressions are location paths of element steps:
+//
+//	/root/child   //item   channel/*/title   //a[@rel]   //a[@rel='nofollow']
+//
+// optionally ending in @attr (the attribute's value) or text() (text directly
+// inside the element). A path ending at an element scopes all text inside it.
+// Spans cover the raw bytes, so entity references are matched as written.
+func XPathScope(expr string) (Scope, error) {
+	q, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.spans, nil
+}
+
+// xpathStep matches one element: by name ("*" for any) and optional attribute predicate.
+type xpathStep struct {
+	descendant bool // reached through "//"
+	name       string
+	attr       string // predicate [@attr] or [@attr='value']
+	value      *string
+}
+
+type xpathQuery struct {
+	steps []xpathStep
+	attr  string // final @attr
+	text  bool   // final text()
+}
+
+func parseXPath(expr string) (xpathQuery, error) {
+	var q xpathQuery
+	rest := strings.TrimSpace(expr)
+	if rest == "" {
+		return q, errors.New("xpath: empty expression")
+	}
+	descendant := !strings.HasPrefix(rest, "/")
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			descendant, rest = true, rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		}
+		seg := rest
+		if i := indexOutsideBrackets(rest, '/'); i >= 0 {
+			seg, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		last := rest == ""
+		switch {
+		case seg == "":
+			return q, fmt.Errorf("xpath: %s: empty step", expr)
+		case last && strings.HasPrefix(seg, "@"):
+			q.attr = seg[1:]
+			if !validXMLName(q.attr) {
+				return q, fmt.Errorf("xpath: %s: bad attribute name %q", expr, q.attr)
+			}
+		case last && seg == "text()":
+			q.text = true
+		default:
+			st, err := parseXPathStep(seg)
+			if err != nil {
+				return q, fmt.Errorf("xpath: %s: %w", expr, err)
+			}
+			st.descendant = descendant
+			q.steps = append(q.steps, st)
+		}
+		descendant = false
+	}
+	if len(q.steps) == 0 {
+		return q, fmt.Errorf("xpath: %s: no element step", expr)
+	}
+	return q, nil
+}
+
+func parseXPathStep(seg string) (xpathStep, error) {
+	st := xpathStep{name: seg}
+	if i := strings.IndexByte(seg, '['); i >= 0 {
+		if !strings.HasSuffix(seg, "]") || !strings.HasPrefix(seg[i+1:], "@") {
+			return st, fmt.Errorf("unsupported predicate in %q (want [@attr] or [@attr='value'])", seg)
+		}
+		st.name = seg[:i]
+		pred := seg[i+2 : len(seg)-1]
+		if name, val, ok := strings.Cut(pred, "="); ok {
+			val = strings.TrimSpace(val)
+			if len(val) < 2 || (val[0] != '\'' && val[0] != '"') || val[len(val)-1] != val[0] {
+				return st, fmt.Errorf("predicate value in %q must be quoted", seg)
+			}
+			v := val[1 : len(val)-1]
+			st.value = &v
+			pred = strings.TrimSpace(name)
+		}
+		st.attr = pred
+		if !validXMLName(st.attr) {
+			return st, fmt.Errorf("bad attribute name %q", st.attr)
+		}
+	}
+	if st.name != "*" && !validXMLName(st.name) {
+		return st, fmt.Errorf("bad element name %q", st.name)
+	}
+	return st, nil
+}
+
+func indexOutsideBrackets(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case c:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func validXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '-' || r == '.' || r == ':' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f) {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlElem is an open element while scanning.
+type xmlElem struct {
+	name  string
+	attrs []xml.Attr
+	hit   bool // the element matches the query's steps
+}
+
+func (st xpathStep) matches(e xmlElem) bool {
+	if st.name != "*" && st.name != e.name {
+		return false
+	}
+	if st.attr == "" {
+		return true
+	}
+	for _, a := range e.attrs {
+		if a.Name.Local == st.attr || qualified(a.Name) == st.attr {
+			return st.value == nil || *st.value == a.Value
+		}
+	}
+	return false
+}
+
+func qualified(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// matchPath reports whether the open elements in stack, outermost first, are
+// selected by steps.
+func matchPath(steps []xpathStep, stack []xmlElem) bool {
+	if len(steps) == 0 {
+		return len(stack) == 0
+	}
+	st := steps[0]
+	if !st.descendant {
+		return len(stack) > 0 && st.matches(stack[0]) && matchPath(steps[1:], stack[1:])
+	}
+	for k := range stack {
+		if st.matches(stack[k]) && matchPath(steps[1:], stack[k+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// spans scans data with a non-strict decoder (HTML entities and unclosed void
+// elements are tolerated) and returns the spans the query selects.
+func (q xpathQuery) spans(data []byte) ([]Span, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var stack []xmlElem
+	var spans []Span
+	inside := 0 // open elements that match, for paths ending at an element
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xpath: %w", err)
+		}
+		end := int(d.InputOffset())
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := xmlElem{name: qualified(t.Name), attrs: t.Attr}
+			stack = append(stack, e)
+			e.hit = matchPath(q.steps, stack)
+			stack[len(stack)-1] = e
+			if e.hit && q.attr != "" {
+				if sp, ok := attrValueSpan(data, start, end, q.attr); ok {
+					spans = append(spans, sp)
+				}
+			}
+			if e.hit {
+				inside++
+			}
+			if isVoidElement(e.name) && !bytes.HasSuffix(data[start:end], []byte("/>")) {
+				// HTML void element: no EndElement follows. (Self-closing tags
+				// are followed by a synthesized one.)
+				if e.hit {
+					inside--
+				}
+				stack = stack[:len(stack)-1]
+			}
+		case xml.EndElement:
+			name := qualified(t.Name)
+			// Pop up to the matching element; unmatched end tags are ignored.
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name != name {
+					continue
+				}
+				for _, e := range stack[i:] {
+					if e.hit {
+						inside--
+					}
+				}
+				stack = stack[:i]
+				break
+			}
+		case xml.CharData:
+			switch {
+			case q.attr != "":
+			case q.text:
+				if len(stack) > 0 && stack[len(stack)-1].hit {
+					spans = append(spans, Span{Start: start, End: end})
+				}
+			case inside > 0:
+				spans = append(spans, Span{Start: start, End: end})
+			}
+		}
+	}
+}
+
+// attrValueSpan finds the value of attribute name in the start tag data[start:end],
+// excluding its quotes.
+func attrValueSpan(data []byte, start, end int, name string) (Span, bool) {
+	tag := data[start:end]
+	i := 1
+	for i < len(tag) && !isXMLSpace(tag[i]) && tag[i] != '>' && tag[i] != '/' {
+		i++ // element name
+	}
+	for i < len(tag) {
+		for i < len(tag) && (isXMLSpace(tag[i]) || tag[i] == '/') {
+			i++
+		}
+		n := i
+		for i < len(tag) && !isXMLSpace(tag[i]) && tag[i] != '=' && tag[i] != '>' && tag[i] != '/' {
+			i++
+		}
+		attr := string(tag[n:i])
+		if attr == "" {
+			return Span{}, false
+		}
+		for i < len(tag) && isXMLSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] != '=' {
+			continue // attribute without value (HTML)
+		}
+		i++
+		for i < len(tag) && isXMLSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) {
+			return Span{}, false
+		}
+		var vs, ve int
+		if q := tag[i]; q == '"' || q == '\'' {
+			vs = i + 1
+			ve = bytes.IndexByte(tag[vs:], q)
+			if ve < 0 {
+				return Span{}, false
+			}
+			ve += vs
+			i = ve + 1
+		} else {
+			vs = i
+			for i < len(tag) && !isXMLSpace(tag[i]) && tag[i] != '>' {
+				i++
+			}
+			ve = i
+		}
+		local := attr
+		if j := strings.LastIndexByte(attr, ':'); j >= 0 {
+			local = attr[j+1:]
+		}
+		if attr == name || local == name {
+			return Span{Start: start + vs, End: start + ve}, true
+		}
+	}
+	return Span{}, false
+}
+
+func isXMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// isVoidElement reports whether name is an HTML element without end tag, like <br>.
+func isVoidElement(name string) bool {
+	for _, v := range xml.HTMLAutoClose {
+		if strings.EqualFold(v, name) {
+			return true
+		}
+	}
+	return false
+}
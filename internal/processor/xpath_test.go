@@ -0,0 +1,98 @@
+package processor
+
+import "testing"
+
+func TestXPathScope(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<!-- foo in a comment -->
+<config name="foo">
+  <server host="foo.example" port='80'>foo</server>
+  <server host="bar.example">foo<b>foo</b></server>
+  <link rel="foo" href="foo"/>
+</config>
+`
+	for _, tc := range []struct{ expr, want string }{
+		{"//server/@host", `<?xml version="1.0"?>
+<!-- foo in a comment -->
+<config name="foo">
+  <server host="X.example" port='80'>foo</server>
+  <server host="bar.example">foo<b>foo</b></server>
+  <link rel="foo" href="foo"/>
+</config>
+`},
+		{"/config/server[@host='bar.example']/text()", `<?xml version="1.0"?>
+<!-- foo in a comment -->
+<config name="foo">
+  <server host="foo.example" port='80'>foo</server>
+  <server host="bar.example">X<b>foo</b></server>
+  <link rel="foo" href="foo"/>
+</config>
+`},
+		{"config/server", `<?xml version="1.0"?>
+<!-- foo in a comment -->
+<config name="foo">
+  <server host="foo.example" port='80'>X</server>
+  <server host="bar.example">X<b>X</b></server>
+  <link rel="foo" href="foo"/>
+</config>
+`},
+		{"//link/@href", `<?xml version="1.0"?>
+<!-- foo in a comment -->
+<config name="foo">
+  <server host="foo.example" port='80'>foo</server>
+  <server host="bar.example">foo<b>foo</b></server>
+  <link rel="foo" href="X"/>
+</config>
+`},
+	} {
+		scope, err := XPathScope(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		spans, err := scope([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		res := SubstituteLiteralInSpans([]byte(doc), []byte("foo"), []byte("X"), spans)
+		if string(res.After) != tc.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", tc.expr, res.After, tc.want)
+		}
+	}
+}
+
+func TestXPathScope_HTMLVoidElements(t *testing.T) {
+	doc := "<p>foo<br>foo &amp; <img src='foo.png'>foo</p>"
+	scope, err := XPathScope("//p/text()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans, err := scope([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := SubstituteLiteralInSpans([]byte(doc), []byte("foo"), []byte("X"), spans)
+	if want := "<p>X<br>X &amp; <img src='foo.png'>X</p>"; string(res.After) != want {
+		t.Fatalf("got %s want %s", res.After, want)
+	}
+}
+
+func TestXPathScope_BadExpressions(t *testing.T) {
+	for _, expr := range []string{"", "//", "/a/@", "//a[1]", "//a[@b=c]", "/@id"} {
+		if _, err := XPathScope(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestXPathScope_NestedSelfClosing(t *testing.T) {
+	doc := "<a>foo<a/>foo</a>"
+	scope, _ := XPathScope("/a/text()")
+	spans, err := scope([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := SubstituteLiteralInSpans([]byte(doc), []byte("foo"), []byte("X"), spans)
+	if want := "<a>X<a/>X</a>"; string(res.After) != want {
+		t.Fatalf("got %s want %s", res.After, want)
+	}
+}
@@ -0,0 +1,44 @@
+// Package retry re-runs file operations that fail with transient errors, such
+// as EAGAIN/EBUSY on network filesystems or sharing violations on Windows.
+package retry
+
+import (
+	"errors"
+	"time"
+)
+
+// Policy controls how often and how long to wait before retrying.
+type Policy struct {
+	// Retries is the number of attempts after the first; 0 disables retrying.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles for every further one.
+	Backoff time.Duration
+}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Do calls fn until it succeeds, fails with an error that is not Transient, or
+// the policy's retries are used up. It returns fn's last error.
+func Do(p Policy, fn func() error) error {
+	delay := p.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Retries || !Transient(err) {
+			return err
+		}
+		sleep(delay)
+		delay *= 2
+	}
+}
+
+// Transient reports whether err (or an error it wraps) is a temporary
+// condition worth retrying.
+func Transient(err error) bool {
+	for _, t := range transientErrors {
+		if errors.Is(err, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package retry
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDo_RetriesTransientWithBackoff(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	calls := 0
+	err := Do(Policy{Retries: 3, Backoff: 10 * time.Millisecond}, func() error {
+		calls++
+		if calls < 3 {
+			return &fs.PathError{Op: "open", Path: "x", Err: syscall.EBUSY}
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err=%v calls=%d", err, calls)
+	}
+	if len(waits) != 2 || waits[0] != 10*time.Millisecond || waits[1] != 20*time.Millisecond {
+		t.Fatalf("unexpected backoff: %v", waits)
+	}
+}
+
+func TestDo_GivesUpAndSkipsPermanentErrors(t *testing.T) {
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	calls := 0
+	err := Do(Policy{Retries: 2}, func() error {
+		calls++
+		return syscall.EAGAIN
+	})
+	if !errors.Is(err, syscall.EAGAIN) || calls != 3 {
+		t.Fatalf("err=%v calls=%d", err, calls)
+	}
+
+	calls = 0
+	err = Do(Policy{Retries: 5}, func() error {
+		calls++
+		return fs.ErrNotExist
+	})
+	if !errors.Is(err, fs.ErrNotExist) || calls != 1 {
+		t.Fatalf("permanent error retried: err=%v calls=%d", err, calls)
+	}
+}
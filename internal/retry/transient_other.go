@@ -0,0 +1,5 @@
+//go:build !unix && !windows
+
+package retry
+
+var transientErrors []error
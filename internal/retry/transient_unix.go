@@ -0,0 +1,8 @@
+//go:build unix
+
+package retry
+
+import "syscall"
+
+// EMFILE and ENFILE clear up once other descriptors are closed.
+var transientErrors = []error{syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.EMFILE, syscall.ENFILE}
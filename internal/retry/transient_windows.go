@@ -0,0 +1,15 @@
+//go:build windows
+
+package retry
+
+import "syscall"
+
+// Sharing and lock violations are reported while another process (often an
+// editor, indexer or virus scanner) holds the file open. Access denied is
+// not retried: it is almost always a real permission problem. Too many open
+// files clears up once other handles are closed.
+var transientErrors = []error{
+	syscall.Errno(32), // ERROR_SHARING_VIOLATION
+	syscall.Errno(33), // ERROR_LOCK_VIOLATION
+	syscall.Errno(4),  // ERROR_TOO_MANY_OPEN_FILES
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxRemoteSize bounds the size of a fetched rule file.
+const maxRemoteSize = 4 << 20
+
+// ErrOffline is returned (wrapped) when a remote rule file is not cached and
+// fetching is disabled.
+var ErrOffline = errors.New("not in the cache and fetching is disabled")
+
+// IsRemote reports whether src names a rule file by URL rather than path.
+func IsRemote(src string) bool {
+	return strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "http://")
+}
+
+// Remote is a rule file published at a URL and pinned to the SHA-256 of its
+// content, written URL@sha256:HEX.
+type Remote struct {
+	URL    string
+	SHA256 string
+}
+
+// ParseRemote splits src into its URL and checksum. Only https URLs are
+// accepted, and the checksum is required.
+func ParseRemote(src string) (Remote, error) {
+	u, sum, ok := cutLast(src, "@sha256:")
+	if !ok {
+		return Remote{}, fmt.Errorf("rules: %s: remote rule files must be pinned with @sha256:<checksum>", src)
+	}
+	if !strings.HasPrefix(u, "https://") {
+		return Remote{}, fmt.Errorf("rules: %s: remote rule files must be fetched over https", u)
+	}
+	sum = strings.ToLower(sum)
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return Remote{}, fmt.Errorf("rules: %s: want a 64-digit hex sha256 checksum, got %q", u, sum)
+	}
+	return Remote{URL: u, SHA256: sum}, nil
+}
+
+// cutLast is strings.Cut around the last occurrence of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// Fetcher loads remote rule files through a cache of verified copies, kept
+// under CacheDir by checksum. A cached copy is used without touching the
+// network, so pinned rule sets keep working offline once fetched.
+type Fetcher struct {
+	// Client fetches files; nil means a client with a 30 second timeout.
+	Client *http.Client
+	// CacheDir holds the cached files; empty means DefaultCacheDir.
+	CacheDir string
+	// Offline fails with ErrOffline instead of fetching uncached files.
+	Offline bool
+}
+
+// DefaultCacheDir returns the cache directory for remote rule files,
+// safereplace/rules under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "safereplace", "rules"), nil
+}
+
+// Load parses the rule file src names: a path, or a pinned URL (see
+// ParseRemote) whose content must match its checksum.
+func (f Fetcher) Load(ctx context.Context, src string) ([]Rule, error) {
+	if !IsRemote(src) {
+		return Load(src)
+	}
+	r, err := ParseRemote(src)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.fetch(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("rules: %s: %w", r.URL, err)
+	}
+	rs, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("rules: %s: %w", r.URL, err)
+	}
+	return rs, nil
+}
+
+// fetch returns the verified content of r, from the cache if possible.
+func (f Fetcher) fetch(ctx context.Context, r Remote) ([]byte, error) {
+	dir := f.CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	cached := filepath.Join(dir, r.SHA256+".yaml")
+	if data, err := os.ReadFile(cached); err == nil && checksum(data) == r.SHA256 {
+		return data, nil
+	}
+	if f.Offline {
+		return nil, ErrOffline
+	}
+	data, err := f.download(ctx, r.URL)
+	if err != nil {
+		return nil, err
+	}
+	if got := checksum(data); got != r.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch: got sha256:%s, want sha256:%s", got, r.SHA256)
+	}
+	// A cache that cannot be written only costs a fetch next time.
+	if err := os.MkdirAll(dir, 0o755); err == nil {
+		tmp := cached + ".tmp"
+		if os.WriteFile(tmp, data, 0o644) == nil && os.Rename(tmp, cached) != nil {
+			_ = os.Remove(tmp)
+		}
+	}
+	return data, nil
+}
+
+// download GETs url, refusing responses larger than maxRemoteSize.
+func (f Fetcher) download(ctx context.Context, url string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteSize {
+		return nil, fmt.Errorf("fetch: larger than %d bytes", maxRemoteSize)
+	}
+	return data, nil
+}
+
+// checksum returns the hex SHA-256 of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const remoteRules = "rules:\n  - pattern: OldClient\n    replace: NewClient\n"
+
+func TestParseRemote(t *testing.T) {
+	sum := checksum([]byte(remoteRules))
+	r, err := ParseRemote("https://example.com/a@b/rules.yaml@sha256:" + strings.ToUpper(sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Remote{URL: "https://example.com/a@b/rules.yaml", SHA256: sum}); r != want {
+		t.Fatalf("got %+v want %+v", r, want)
+	}
+	for _, src := range []string{
+		"https://example.com/rules.yaml",
+		"http://example.com/rules.yaml@sha256:" + sum,
+		"https://example.com/rules.yaml@sha256:abc",
+	} {
+		if _, err := ParseRemote(src); err == nil {
+			t.Errorf("%s: expected error", src)
+		}
+	}
+}
+
+func TestFetcherLoad_CachesVerifiedCopy(t *testing.T) {
+	hits := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(remoteRules))
+	}))
+	defer srv.Close()
+	src := srv.URL + "/rules.yaml@sha256:" + checksum([]byte(remoteRules))
+	want := []Rule{{Pattern: "OldClient", Replace: "NewClient"}}
+
+	f := Fetcher{Client: srv.Client(), CacheDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		rs, err := f.Load(context.Background(), src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rs, want) {
+			t.Fatalf("got %+v want %+v", rs, want)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("fetched %d times, want 1", hits)
+	}
+
+	srv.Close()
+	f.Offline = true
+	if _, err := f.Load(context.Background(), src); err != nil {
+		t.Fatalf("offline with cached copy: %v", err)
+	}
+	f.CacheDir = t.TempDir()
+	if _, err := f.Load(context.Background(), src); !errors.Is(err, ErrOffline) {
+		t.Fatalf("offline without cached copy: got %v, want ErrOffline", err)
+	}
+}
+
+func TestFetcherLoad_ChecksumMismatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(remoteRules + "  - pattern: x\n"))
+	}))
+	defer srv.Close()
+	f := Fetcher{Client: srv.Client(), CacheDir: t.TempDir()}
+	_, err := f.Load(context.Background(), srv.URL+"/rules.yaml@sha256:"+checksum([]byte(remoteRules)))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got %v, want checksum mismatch", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package rules reads and writes rule sets: lists of replacements applied
+// together in one run. Rule files use a small subset of YAML:
+//
+//	# rename the client
+//	rules:
+//	  - pattern: OldClient
+//	    replace: NewClient
+//	  - pattern: "old-client: "
+//	    replace: 'new-client: '
+//
+// Values are plain scalars (a " #" starts a comment), single-quoted (a quote
+// inside is written twice) or double-quoted with backslash escapes. The
+// "rules:" line is optional.
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rule is one replacement of a rule set.
+type Rule struct {
+	Pattern string
+	Replace string
+	// Regex marks Pattern as a regular expression.
+	Regex bool
+}
+
+// Load parses the rule file at path.
+func Load(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("rules: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	rs, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("rules: %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Parse reads a rule set from r. Every rule needs a non-empty pattern.
+func Parse(r io.Reader) ([]Rule, error) {
+	var rs []Rule
+	var cur *Rule
+	seen := map[string]bool{}
+	finish := func(n int) error {
+		if cur != nil && !seen["pattern"] {
+			return fmt.Errorf("line %d: rule without pattern", n)
+		}
+		return nil
+	}
+	sc := bufio.NewScanner(r)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (n == 1 && trimmed == "---") {
+			continue
+		}
+		if trimmed == "rules:" && line == trimmed {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed+" ", "- "); ok {
+			if err := finish(n); err != nil {
+				return nil, err
+			}
+			rs = append(rs, Rule{})
+			cur, seen = &rs[len(rs)-1], map[string]bool{}
+			trimmed = strings.TrimSpace(item)
+			if trimmed == "" {
+				continue
+			}
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: want a \"- pattern: ...\" list item, got %q", n, trimmed)
+		}
+		key, val, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value, got %q", n, trimmed)
+		}
+		key = strings.TrimSpace(key)
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		seen[key] = true
+		v, err := scalar(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		switch key {
+		case "pattern":
+			if v == "" {
+				return nil, fmt.Errorf("line %d: empty pattern", n)
+			}
+			cur.Pattern = v
+		case "replace":
+			cur.Replace = v
+		case "regex":
+			if cur.Regex, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("line %d: regex: want true or false, got %q", n, v)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", n, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(n); err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("no rules")
+	}
+	return rs, nil
+}
+
+// scalar decodes a plain, single-quoted or double-quoted YAML scalar.
+func scalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", errors.New("unterminated double-quoted string")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			if rest := strings.TrimSpace(s[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after string", rest)
+			}
+			return b.String(), nil
+		}
+		return "", errors.New("unterminated single-quoted string")
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// closingQuote returns the index of the quote ending the double-quoted string
+// s, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// Write writes rs in the format Parse reads, with every value double-quoted,
+// preceded by header as comment lines.
+func Write(w io.Writer, rs []Rule, header ...string) error {
+	var b bytes.Buffer
+	for _, h := range header {
+		fmt.Fprintf(&b, "# %s\n", h)
+	}
+	b.WriteString("rules:\n")
+	for _, r := range rs {
+		fmt.Fprintf(&b, "  - pattern: %s\n    replace: %s\n", strconv.Quote(r.Pattern), strconv.Quote(r.Replace))
+		if r.Regex {
+			b.WriteString("    regex: true\n")
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Invert returns the rule set undoing rs: each replacement turned back into
+// its pattern, in reverse order. Rules that cannot be inverted are left out
+// and described in skipped: regex rules, deletions (an empty replacement
+// leaves nothing to match) and replacements shared by several rules (which
+// pattern to restore is ambiguous). The inverse also rewrites occurrences of
+// a replacement that predate the migration; callers should say so.
+func Invert(rs []Rule) (inv []Rule, skipped []string) {
+	shared := map[string]int{}
+	for _, r := range rs {
+		if !r.Regex {
+			shared[r.Replace]++
+		}
+	}
+	for i := len(rs) - 1; i >= 0; i-- {
+		r := rs[i]
+		switch {
+		case r.Regex:
+			skipped = append(skipped, fmt.Sprintf("rule %d (%q): regex rules are not invertible", i+1, r.Pattern))
+		case r.Replace == "":
+			skipped = append(skipped, fmt.Sprintf("rule %d (%q): deletes its matches", i+1, r.Pattern))
+		case shared[r.Replace] > 1:
+			skipped = append(skipped, fmt.Sprintf("rule %d (%q): replacement %q is shared with another rule", i+1, r.Pattern, r.Replace))
+		default:
+			inv = append(inv, Rule{Pattern: r.Replace, Replace: r.Pattern})
+		}
+	}
+	return inv, skipped
+}
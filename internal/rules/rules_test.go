@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	rs, err := Parse(strings.NewReader(`---
+# header
+rules:
+  - pattern: OldClient   # trailing comment
+    replace: NewClient
+  - pattern: "old: \"x\"\n"
+    replace: 'it''s # not a comment'
+  -
+    pattern: a.b
+    replace: ""
+    regex: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{Pattern: "OldClient", Replace: "NewClient"},
+		{Pattern: "old: \"x\"\n", Replace: "it's # not a comment"},
+		{Pattern: "a.b", Replace: "", Regex: true},
+	}
+	if !reflect.DeepEqual(rs, want) {
+		t.Fatalf("got %+v want %+v", rs, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"rules:\n",
+		"pattern: a\n",
+		"- replace: b\n",
+		"- pattern: \"\"\n",
+		"- pattern: a\n  pattern: b\n",
+		"- pattern: a\n  colour: red\n",
+		"- pattern: \"a\n",
+		"- pattern: 'a\n",
+		"- pattern: 'a' b\n",
+		"- pattern: a\n  regex: maybe\n",
+		"- pattern\n",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	rs := []Rule{{Pattern: "a: #b", Replace: "'c'\t\"d\""}, {Pattern: "x", Replace: "", Regex: true}}
+	var buf bytes.Buffer
+	if err := Write(&buf, rs, "generated"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "# generated\nrules:\n") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rs) {
+		t.Fatalf("got %+v want %+v", got, rs)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	inv, skipped := Invert([]Rule{
+		{Pattern: "foo", Replace: "bar"},
+		{Pattern: "a+", Replace: "b", Regex: true},
+		{Pattern: "debug", Replace: ""},
+		{Pattern: "x", Replace: "z"},
+		{Pattern: "y", Replace: "z"},
+		{Pattern: "old", Replace: "new"},
+	})
+	want := []Rule{{Pattern: "new", Replace: "old"}, {Pattern: "bar", Replace: "foo"}}
+	if !reflect.DeepEqual(inv, want) {
+		t.Fatalf("got %+v want %+v", inv, want)
+	}
+	if len(skipped) != 4 {
+		t.Fatalf("expected 4 skipped rules, got %q", skipped)
+	}
+}
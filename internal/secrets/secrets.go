@@ -0,0 +1,76 @@
+// Package secrets recognizes strings that look like credentials. It is a
+// lightweight heuristic, not a scanner: it knows the shapes of common tokens
+// and flags long random-looking strings, and may miss or misjudge either way.
+package secrets
+
+import (
+	"math"
+	"regexp"
+)
+
+// kinds lists recognizable token formats, most specific first.
+var kinds = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[0-9A-Za-z]{36,}|github_pat_[0-9A-Za-z_]{22,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[0-9A-Za-z_-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[sr]k_(live|test)_[0-9A-Za-z]{16,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[0-9A-Za-z_-]{8,}\.eyJ[0-9A-Za-z_-]{8,}\.[0-9A-Za-z_-]{8,}`)},
+	{"credentials in URL", regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+// token matches runs of characters used by base64, hex and similar encodings.
+var token = regexp.MustCompile(`[0-9A-Za-z+/=_-]{20,}`)
+
+// minEntropy is the Shannon entropy, in bits per character, above which a
+// long token is considered random rather than a word or identifier.
+const minEntropy = 4.0
+
+// Detect reports what kind of credential s looks like, if any.
+func Detect(s string) (kind string, ok bool) {
+	for _, k := range kinds {
+		if k.re.MatchString(s) {
+			return k.name, true
+		}
+	}
+	for _, t := range token.FindAllString(s, -1) {
+		if hasDigitAndLetter(t) && entropy(t) >= minEntropy {
+			return "high-entropy string", true
+		}
+	}
+	return "", false
+}
+
+func hasDigitAndLetter(s string) bool {
+	var digit, letter bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			letter = true
+		}
+	}
+	return digit && letter
+}
+
+// entropy returns the Shannon entropy of s in bits per byte.
+func entropy(s string) float64 {
+	var freq [256]int
+	for i := 0; i < len(s); i++ {
+		freq[s[i]]++
+	}
+	var h float64
+	for _, n := range freq {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
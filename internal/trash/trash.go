@@ -0,0 +1,80 @@
+// Package trash copies files into the platform's trash (XDG Trash on Linux and
+// BSDs, ~/.Trash on macOS, the Recycle Bin on Windows) so originals can be
+// recovered with the desktop's own tools. Files are copied, never moved.
+package trash
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Put copies the regular file at path into the trash and returns the location
+// of the trashed copy (or a description of it where the OS keeps it opaque).
+func Put(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("trash: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("trash: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("trash: %s: not a regular file", path)
+	}
+	dst, err := put(abs, info)
+	if err != nil {
+		return "", fmt.Errorf("trash: %w", err)
+	}
+	return dst, nil
+}
+
+// Dir returns the directory holding the trash, which need not exist yet, so
+// callers can tell which filesystem trashed copies use. On Windows copies are
+// staged in the temp directory before they are handed to the Recycle Bin.
+func Dir() (string, error) {
+	return dir()
+}
+
+// uniqueName returns dir/name, or dir/name.N when that already exists.
+// create is called with each candidate and must fail with os.ErrExist on clashes.
+func uniqueName(dir, name string, create func(string) error) (string, error) {
+	for i := 1; i < 10000; i++ {
+		cand := name
+		if i > 1 {
+			cand = fmt.Sprintf("%s.%d", name, i)
+		}
+		err := create(filepath.Join(dir, cand))
+		if err == nil {
+			return cand, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("too many trashed files named %s", name)
+}
+
+// copyInto copies src to a newly created dst, failing if dst exists.
+func copyInto(src, dst string, mode os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
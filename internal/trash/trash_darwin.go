@@ -0,0 +1,36 @@
+//go:build darwin
+
+package trash
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dir returns ~/.Trash.
+func dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".Trash"), nil
+}
+
+// put copies into ~/.Trash, where Finder shows the file. macOS keeps "Put Back"
+// metadata private to Finder, so the copy cannot be restored to its origin from there.
+func put(abs string, info os.FileInfo) (string, error) {
+	root, err := dir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return "", err
+	}
+	name, err := uniqueName(root, filepath.Base(abs), func(cand string) error {
+		return copyInto(abs, cand, info.Mode())
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, name), nil
+}
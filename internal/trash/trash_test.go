@@ -0,0 +1,74 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPut_XDGCopiesAndWritesInfo(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("XDG trash layout only")
+	}
+	data := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", data)
+	src := filepath.Join(t.TempDir(), "a b.txt")
+	if err := os.WriteFile(src, []byte("orig"), 0o644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	for i, wantName := range []string{"a b.txt", "a b.txt.2"} {
+		dst, err := Put(src)
+		if err != nil {
+			t.Fatalf("Put #%d: %v", i, err)
+		}
+		if want := filepath.Join(data, "Trash", "files", wantName); dst != want {
+			t.Fatalf("Put #%d: got %q want %q", i, dst, want)
+		}
+		got, _ := os.ReadFile(dst)
+		if string(got) != "orig" {
+			t.Fatalf("trashed content: %q", got)
+		}
+		info, err := os.ReadFile(filepath.Join(data, "Trash", "info", wantName+".trashinfo"))
+		if err != nil {
+			t.Fatalf("trashinfo: %v", err)
+		}
+		if !strings.Contains(string(info), "Path="+strings.ReplaceAll(filepath.ToSlash(src), " ", "%20")) {
+			t.Fatalf("trashinfo path not escaped: %s", info)
+		}
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Fatalf("original must stay in place: %v", err)
+	}
+}
+
+func TestPut_RejectsMissing(t *testing.T) {
+	if _, err := Put(filepath.Join(t.TempDir(), "nope")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestDir_XDG(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("XDG trash layout only")
+	}
+	data := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", data)
+	src := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(src, []byte("orig"), 0o644); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := Put(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dst, dir+string(filepath.Separator)) {
+		t.Fatalf("trashed copy %q is outside %q", dst, dir)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build windows
+
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	shell32              = syscall.NewLazyDLL("shell32.dll")
+	procSHFileOperationW = shell32.NewProc("SHFileOperationW")
+)
+
+// SHFILEOPSTRUCTW; the natural Go layout matches the 64-bit ABI only
+// (the 32-bit header packs it to 1 byte), see put.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+const (
+	foDelete          = 0x0003
+	fofSilent         = 0x0004
+	fofNoConfirmation = 0x0010
+	fofAllowUndo      = 0x0040
+	fofNoErrorUI      = 0x0400
+)
+
+// dir returns the temp directory, where copies are staged for the Recycle Bin.
+func dir() (string, error) {
+	return os.TempDir(), nil
+}
+
+// put copies the file to a private temp directory under its original name and
+// sends that copy to the Recycle Bin, leaving the original in place.
+func put(abs string, info os.FileInfo) (string, error) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		return "", errors.New("recycle bin is only supported on 64-bit Windows")
+	}
+	tmp, err := os.MkdirTemp("", "safereplace-trash-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	cp := filepath.Join(tmp, filepath.Base(abs))
+	if err := copyInto(abs, cp, info.Mode()); err != nil {
+		return "", err
+	}
+
+	// pFrom is a list of NUL-terminated names ending with an extra NUL.
+	from, err := syscall.UTF16FromString(cp)
+	if err != nil {
+		return "", err
+	}
+	from = append(from, 0)
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	if rc, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op))); rc != 0 {
+		return "", syscall.Errno(rc)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return "", errors.New("recycle bin operation aborted")
+	}
+	return "Recycle Bin: " + filepath.Base(abs), nil
+}
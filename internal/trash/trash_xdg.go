@@ -0,0 +1,75 @@
+//go:build !darwin && !windows
+
+package trash
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dir returns the XDG home trash, $XDG_DATA_HOME/Trash (default ~/.local/share/Trash).
+func dir() (string, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash"), nil
+}
+
+// put follows the FreeDesktop.org Trash specification: the .trashinfo file is
+// created exclusively first to reserve the name, then the copy is written.
+func put(abs string, info os.FileInfo) (string, error) {
+	root, err := dir()
+	if err != nil {
+		return "", err
+	}
+	filesDir := filepath.Join(root, "files")
+	infoDir := filepath.Join(root, "info")
+	for _, d := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return "", err
+		}
+	}
+
+	body := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		escapePath(abs), time.Now().Format("2006-01-02T15:04:05"))
+	name, err := uniqueName(infoDir, filepath.Base(abs), func(cand string) error {
+		f, err := os.OpenFile(cand+".trashinfo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(body); err != nil {
+			_ = f.Close()
+			_ = os.Remove(cand + ".trashinfo")
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(filesDir, name)
+	if err := copyInto(abs, dst, info.Mode()); err != nil {
+		_ = os.Remove(filepath.Join(infoDir, name+".trashinfo"))
+		return "", err
+	}
+	return dst, nil
+}
+
+// escapePath URL-escapes each path segment as the spec requires, keeping separators.
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, s := range parts {
+		parts[i] = url.PathEscape(s)
+	}
+	return strings.Join(parts, "/")
+}